@@ -10,12 +10,22 @@ import (
 	"github.com/jackc/pgx/v4/log/zapadapter"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/roman-wb/crud-products/internal/jobs"
+	"github.com/roman-wb/crud-products/internal/jwt"
+	"github.com/roman-wb/crud-products/internal/outbox"
+	"github.com/roman-wb/crud-products/internal/ratelimit"
 	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/requestctx"
 	"github.com/roman-wb/crud-products/internal/server"
+	"github.com/roman-wb/crud-products/internal/stream"
+	"github.com/roman-wb/crud-products/internal/webhooks"
 	"go.uber.org/zap"
 )
 
 const ShutdownTimeout = 5 * time.Second
+const CleanupInterval = time.Hour
+const EventRetention = 7 * 24 * time.Hour
+const KeysReloadInterval = 10 * time.Second
 
 func main() {
 	// Setup logger
@@ -31,6 +41,12 @@ func main() {
 		logger.Sugar().Warn(err)
 	}
 
+	// Load config
+	config, err := server.NewConfig(os.Getenv)
+	if err != nil {
+		logger.Sugar().Fatal(err)
+	}
+
 	// Connect DB
 	poolConfig, err := pgxpool.ParseConfig(os.Getenv("DATABASE_URL"))
 	if err != nil {
@@ -38,6 +54,8 @@ func main() {
 		return
 	}
 	poolConfig.ConnConfig.Logger = zapadapter.NewLogger(logger)
+	// Connections of requests are scoped to the tenant (row level security)
+	poolConfig.BeforeAcquire = repos.SetTenant
 
 	db, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
 	if err != nil {
@@ -48,8 +66,98 @@ func main() {
 	// Dependends
 	repos := repos.NewRepos(db)
 
+	// Run background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go jobs.Every(jobsCtx, logger, "idempotency_cleanup", CleanupInterval, func(ctx context.Context) error {
+		deleted, err := repos.Idempotency.DeleteExpired(ctx)
+		if err == nil && deleted > 0 {
+			logger.Sugar().Infof("deleted %d expired idempotency keys", deleted)
+		}
+		return err
+	})
+
+	go jobs.Every(jobsCtx, logger, "products_purge", CleanupInterval, func(ctx context.Context) error {
+		deleted, err := repos.Product.PurgeTrashed(requestctx.WithActor(ctx, requestctx.SystemActor), config.TrashRetention)
+		if err == nil && deleted > 0 {
+			logger.Sugar().Infof("purged %d trashed products", deleted)
+		}
+		return err
+	})
+
+	go jobs.Every(jobsCtx, logger, "product_events_cleanup", CleanupInterval, func(ctx context.Context) error {
+		deleted, err := repos.ProductEvent.DeletePublished(ctx, EventRetention)
+		if err == nil && deleted > 0 {
+			logger.Sugar().Infof("deleted %d published product events", deleted)
+		}
+		return err
+	})
+
+	// Publish product events, webhook deliveries are saved by the relay and
+	// sent by the dispatcher
+	target, err := outbox.NewPublisher(config.OutboxPublisher)
+	if err != nil {
+		logger.Sugar().Fatalf("outbox publisher: %v", err)
+	}
+	publisher := outbox.NewMultiPublisher(target, webhooks.NewFanout(repos.Webhook))
+	defer publisher.Close() //nolint:errcheck
+	relay := outbox.NewRelay(logger, repos.ProductEvent, publisher)
+	go jobs.Every(jobsCtx, logger, "outbox_relay", config.OutboxInterval, relay.Publish)
+
+	dispatcher := webhooks.NewDispatcher(logger, repos.Webhook, webhooks.NewClient())
+	go jobs.Every(jobsCtx, logger, "webhook_delivery", config.WebhookInterval, dispatcher.Deliver)
+
+	// Stream product events committed by all server instances
+	hub := stream.NewHub(config.StreamReplaySize)
+	go stream.NewListener(logger, repos.ProductEvent, hub).Run(jobsCtx)
+
+	// Verify JWTs by keys of the file, the file is reloaded on change
+	var verifier *jwt.Verifier
+	if config.JWTKeysFile != "" {
+		keyFile, err := jwt.NewKeyFile(config.JWTKeysFile)
+		if err != nil {
+			logger.Sugar().Fatalf("jwt keys: %v", err)
+		}
+		verifier = jwt.NewVerifier(keyFile)
+		verifier.Issuer = config.JWTIssuer
+		verifier.Audience = config.JWTAudience
+		verifier.ClockSkew = config.JWTClockSkew
+		go jobs.Every(jobsCtx, logger, "jwt_keys_reload", KeysReloadInterval, func(ctx context.Context) error {
+			reloaded, err := keyFile.Reload()
+			if err == nil && reloaded {
+				logger.Sugar().Infof("reloaded jwt keys of %s", config.JWTKeysFile)
+			}
+			return err
+		})
+	}
+
+	// Check roles of principals against the policy
+	var policy *server.Policy
+	if config.PolicyFile != "" {
+		policy, err = server.LoadPolicy(config.PolicyFile)
+		if err != nil {
+			logger.Sugar().Fatalf("policy: %v", err)
+		}
+	}
+
+	// Limit requests of clients, the postgres store is shared by instances
+	var limiter server.RateLimitStore
+	if config.RateLimits.Enabled() {
+		limiter = ratelimit.NewMemoryStore()
+		if config.RateLimitStore == server.RateLimitStorePostgres {
+			limiter = repos.RateLimit
+		}
+		go jobs.Every(jobsCtx, logger, "rate_limits_cleanup", CleanupInterval, func(ctx context.Context) error {
+			deleted, err := limiter.DeleteIdle(ctx, config.RateLimits.MaxPeriod())
+			if err == nil && deleted > 0 {
+				logger.Sugar().Infof("deleted %d idle rate limit buckets", deleted)
+			}
+			return err
+		})
+	}
+
 	// Run server
-	server := server.Run(logger, repos)
+	server := server.Run(logger, config, repos, hub, verifier, policy, limiter)
 
 	// Graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -3,12 +3,16 @@ module github.com/roman-wb/crud-products
 go 1.16
 
 require (
+	github.com/evanphx/json-patch/v5 v5.6.0
 	github.com/felixge/httpsnoop v1.0.2 // indirect
 	github.com/georgysavva/scany v0.2.9
 	github.com/golang/mock v1.6.0
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.4.2
+	github.com/jackc/pgconn v1.9.0
 	github.com/jackc/pgx/v4 v4.12.0
+	github.com/jackc/puddle v1.1.3
 	github.com/joho/godotenv v1.3.0
 	github.com/purini-to/zapmw v1.1.0
 	github.com/stretchr/objx v0.3.0 // indirect
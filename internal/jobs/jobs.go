@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job is a periodic background task
+type Job func(ctx context.Context) error
+
+// Every runs the job each interval until ctx is done, errors are logged and
+// the job is run again on the next tick
+func Every(ctx context.Context, logger *zap.Logger, name string, interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				logger.Sugar().Errorw("job failed", "job", name, "error", err)
+			}
+		}
+	}
+}
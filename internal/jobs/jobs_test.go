@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_Every(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var runs int32
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, zaptest.NewLogger(t), "test", time.Millisecond, func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 3 {
+				cancel()
+			}
+			return errors.New("some error...")
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job is not stopped")
+	}
+	require.GreaterOrEqual(t, atomic.LoadInt32(&runs), int32(3))
+}
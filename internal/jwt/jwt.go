@@ -0,0 +1,292 @@
+// Package jwt verifies JSON Web Tokens (RFC 7519) signed with RS256, ES256
+// or HS256 by keys of a local JWKS or PEM file
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const AlgRS256 = "RS256"
+const AlgES256 = "ES256"
+const AlgHS256 = "HS256"
+
+const DefaultClockSkew = time.Minute
+
+var (
+	ErrMalformed    = errors.New("token is malformed")
+	ErrAlgorithm    = errors.New("token algorithm is not supported")
+	ErrSignature    = errors.New("token signature is invalid")
+	ErrExpired      = errors.New("token is expired")
+	ErrNotYetValid  = errors.New("token is not valid yet")
+	ErrIssuer       = errors.New("token issuer is not accepted")
+	ErrAudience     = errors.New("token audience is not accepted")
+	ErrMissingClaim = errors.New("token has no exp claim")
+)
+
+// KeySource returns current verification keys, e.g. *KeyFile
+type KeySource interface {
+	KeySet() *KeySet
+}
+
+// Claims are claims of a verified token, Raw has all of them
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	Tenant    string
+	Scopes    []string
+	Roles     []string
+	Raw       map[string]interface{}
+}
+
+// Verifier checks signature and `exp`/`nbf` of tokens with ClockSkew
+// tolerance, `iss` and `aud` are checked when Issuer and Audience are set
+type Verifier struct {
+	keys      KeySource
+	Issuer    string
+	Audience  string
+	ClockSkew time.Duration
+
+	now func() time.Time
+}
+
+func NewVerifier(keys KeySource) *Verifier {
+	return &Verifier{
+		keys:      keys,
+		ClockSkew: DefaultClockSkew,
+		now:       time.Now,
+	}
+}
+
+// IsToken tells JWTs from other bearer credentials (e.g. API keys)
+func IsToken(value string) bool {
+	return strings.Count(value, ".") == 2
+}
+
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if header.Alg != AlgRS256 && header.Alg != AlgES256 && header.Alg != AlgHS256 {
+		return nil, ErrAlgorithm
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	if !v.verifySignature(header.Alg, header.Kid, signed, signature) {
+		return nil, ErrSignature
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, err
+	}
+	claims, err := parseClaims(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature tries keys matching kid and alg, the key type must fit
+// alg so a public key is never used as HMAC secret
+func (v *Verifier) verifySignature(alg string, kid string, signed []byte, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	for _, key := range v.keys.KeySet().Keys {
+		if (key.Kid != "" && kid != "" && key.Kid != kid) || (key.Alg != "" && key.Alg != alg) {
+			continue
+		}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			if alg == AlgRS256 && rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if alg == AlgES256 && public.Curve == elliptic.P256() && len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(public, digest[:], r, s) {
+					return true
+				}
+			}
+		case []byte:
+			if alg == AlgHS256 {
+				mac := hmac.New(sha256.New, public)
+				mac.Write(signed)
+				if hmac.Equal(mac.Sum(nil), signature) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.now()
+	if claims.ExpiresAt.IsZero() {
+		return ErrMissingClaim
+	}
+	if !now.Before(claims.ExpiresAt.Add(v.ClockSkew)) {
+		return ErrExpired
+	}
+	if nbf, ok := claims.Raw["nbf"]; ok {
+		notBefore, err := numericDate(nbf)
+		if err != nil {
+			return err
+		}
+		if now.Add(v.ClockSkew).Before(notBefore) {
+			return ErrNotYetValid
+		}
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return ErrIssuer
+	}
+	if v.Audience != "" && !contains(claims.Audience, v.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// parseClaims reads registered claims, scopes of `scope` (space separated,
+// RFC 8693) or `scp` (list or string), roles of `roles` (list or string)
+// and the tenant of `tenant_id`
+func parseClaims(raw map[string]interface{}) (*Claims, error) {
+	claims := &Claims{Raw: raw}
+	var ok bool
+	if value, exists := raw["sub"]; exists {
+		if claims.Subject, ok = value.(string); !ok {
+			return nil, ErrMalformed
+		}
+	}
+	if value, exists := raw["iss"]; exists {
+		if claims.Issuer, ok = value.(string); !ok {
+			return nil, ErrMalformed
+		}
+	}
+	if value, exists := raw["aud"]; exists {
+		audience, err := stringList(value)
+		if err != nil {
+			return nil, err
+		}
+		claims.Audience = audience
+	}
+	if value, exists := raw["exp"]; exists {
+		expiresAt, err := numericDate(value)
+		if err != nil {
+			return nil, err
+		}
+		claims.ExpiresAt = expiresAt
+	}
+	if value, exists := raw["scope"]; exists {
+		scope, ok := value.(string)
+		if !ok {
+			return nil, ErrMalformed
+		}
+		claims.Scopes = strings.Fields(scope)
+	} else if value, exists := raw["scp"]; exists {
+		scopes, err := stringList(value)
+		if err != nil {
+			return nil, err
+		}
+		claims.Scopes = scopes
+	}
+	if value, exists := raw["tenant_id"]; exists {
+		if claims.Tenant, ok = value.(string); !ok {
+			return nil, ErrMalformed
+		}
+	}
+	if value, exists := raw["roles"]; exists {
+		roles, err := stringList(value)
+		if err != nil {
+			return nil, err
+		}
+		claims.Roles = roles
+	}
+	return claims, nil
+}
+
+func stringList(value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, ErrMalformed
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, ErrMalformed
+	}
+}
+
+// numericDate converts seconds since epoch (may be fractional) to time
+func numericDate(value interface{}) (time.Time, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, ErrMalformed
+	}
+	seconds, err := number.Float64()
+	if err != nil || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return time.Time{}, ErrMalformed
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)), nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2021, 7, 31, 10, 0, 0, 0, time.UTC)
+
+// signToken signs claims with *rsa.PrivateKey, *ecdsa.PrivateKey or []byte
+// secret like a token issuer
+func signToken(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	require.NoError(t, err)
+	claimsJSON, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "user-1",
+		"iss":   "https://auth.example.com",
+		"aud":   []string{"crud-products", "other"},
+		"exp":   testNow.Add(time.Hour).Unix(),
+		"nbf":   testNow.Add(-time.Minute).Unix(),
+		"scope": "products:read products:write",
+	}
+}
+
+func newTestVerifier(keys *KeySet) *Verifier {
+	verifier := NewVerifier(keys)
+	verifier.Issuer = "https://auth.example.com"
+	verifier.Audience = "crud-products"
+	verifier.now = func() time.Time { return testNow }
+	return verifier
+}
+
+func Test_Verifier_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	verifier := newTestVerifier(&KeySet{Keys: []Key{
+		{Kid: "rsa", Public: &rsaKey.PublicKey},
+		{Kid: "ec", Public: &ecKey.PublicKey},
+		{Kid: "hmac", Alg: AlgHS256, Public: secret},
+	}})
+
+	testCases := []struct {
+		name    string
+		alg     string
+		kid     string
+		key     interface{}
+		wantErr error
+	}{
+		{name: "RS256", alg: AlgRS256, kid: "rsa", key: rsaKey},
+		{name: "ES256", alg: AlgES256, kid: "ec", key: ecKey},
+		{name: "HS256", alg: AlgHS256, kid: "hmac", key: secret},
+		{name: "without kid", alg: AlgES256, key: ecKey},
+		{name: "wrong kid", alg: AlgRS256, kid: "ec", key: rsaKey, wantErr: ErrSignature},
+		{name: "unknown key", alg: AlgHS256, kid: "hmac", key: []byte("guess"), wantErr: ErrSignature},
+		{name: "unsupported alg", alg: "HS512", kid: "hmac", key: secret, wantErr: ErrAlgorithm},
+		{name: "none", alg: "none", key: []byte{}, wantErr: ErrAlgorithm},
+	}
+
+	for _, tc := range testCases {
+		token := signToken(t, tc.alg, tc.kid, tc.key, validClaims())
+
+		claims, err := verifier.Verify(token)
+
+		require.Equal(t, tc.wantErr, err, tc.name)
+		if tc.wantErr == nil {
+			require.Equal(t, "user-1", claims.Subject, tc.name)
+			require.Equal(t, []string{"products:read", "products:write"}, claims.Scopes, tc.name)
+		}
+	}
+}
+
+func Test_Verifier_AlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pemKeys, err := ParseKeys(publicKeyPEM(t, &rsaKey.PublicKey))
+	require.NoError(t, err)
+	verifier := newTestVerifier(pemKeys)
+
+	// HS256 token signed with the public key as secret
+	token := signToken(t, AlgHS256, "", pemKeys.Keys[0].Public.(*rsa.PublicKey).N.Bytes(), validClaims())
+	_, err = verifier.Verify(token)
+	require.Equal(t, ErrSignature, err)
+
+	_, err = verifier.Verify(signToken(t, AlgRS256, "any", rsaKey, validClaims()))
+	require.NoError(t, err)
+}
+
+func Test_Verifier_Claims(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier := newTestVerifier(&KeySet{Keys: []Key{{Public: secret}}})
+
+	testCases := []struct {
+		name    string
+		change  func(claims map[string]interface{})
+		wantErr error
+	}{
+		{name: "valid", change: func(map[string]interface{}) {}},
+		{name: "expired", change: func(c map[string]interface{}) { c["exp"] = testNow.Add(-2 * time.Minute).Unix() }, wantErr: ErrExpired},
+		{name: "expired in skew", change: func(c map[string]interface{}) { c["exp"] = testNow.Add(-30 * time.Second).Unix() }},
+		{name: "missing exp", change: func(c map[string]interface{}) { delete(c, "exp") }, wantErr: ErrMissingClaim},
+		{name: "fractional exp", change: func(c map[string]interface{}) { c["exp"] = float64(testNow.Unix()) + 0.5 }},
+		{name: "not yet valid", change: func(c map[string]interface{}) { c["nbf"] = testNow.Add(2 * time.Minute).Unix() }, wantErr: ErrNotYetValid},
+		{name: "nbf in skew", change: func(c map[string]interface{}) { c["nbf"] = testNow.Add(30 * time.Second).Unix() }},
+		{name: "wrong issuer", change: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, wantErr: ErrIssuer},
+		{name: "wrong audience", change: func(c map[string]interface{}) { c["aud"] = "other" }, wantErr: ErrAudience},
+		{name: "audience string", change: func(c map[string]interface{}) { c["aud"] = "crud-products" }},
+		{name: "invalid exp", change: func(c map[string]interface{}) { c["exp"] = "tomorrow" }, wantErr: ErrMalformed},
+		{name: "invalid sub", change: func(c map[string]interface{}) { c["sub"] = 1 }, wantErr: ErrMalformed},
+		{name: "invalid aud", change: func(c map[string]interface{}) { c["aud"] = []int{1} }, wantErr: ErrMalformed},
+		{name: "invalid roles", change: func(c map[string]interface{}) { c["roles"] = map[string]int{} }, wantErr: ErrMalformed},
+		{name: "invalid tenant", change: func(c map[string]interface{}) { c["tenant_id"] = 1 }, wantErr: ErrMalformed},
+	}
+
+	for _, tc := range testCases {
+		claims := validClaims()
+		tc.change(claims)
+
+		_, err := verifier.Verify(signToken(t, AlgHS256, "", secret, claims))
+
+		require.Equal(t, tc.wantErr, err, tc.name)
+	}
+}
+
+func Test_Verifier_OptionalChecks(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier := NewVerifier(&KeySet{Keys: []Key{{Public: secret}}})
+	verifier.now = func() time.Time { return testNow }
+
+	claims := map[string]interface{}{
+		"sub": "user-1", "exp": testNow.Add(time.Minute).Unix(), "scp": []string{"products:read"}, "roles": "editor",
+		"tenant_id": "acme",
+	}
+	got, err := verifier.Verify(signToken(t, AlgHS256, "", secret, claims))
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"products:read"}, got.Scopes)
+	require.Equal(t, []string{"editor"}, got.Roles)
+	require.Equal(t, "acme", got.Tenant)
+	require.Equal(t, "user-1", got.Raw["sub"])
+}
+
+func Test_Verifier_Malformed(t *testing.T) {
+	verifier := newTestVerifier(&KeySet{})
+
+	for _, token := range []string{"", "a.b", "a.b.c.d", "!.e30.", "e30.e30.!", "bm90IGpzb24.e30."} {
+		_, err := verifier.Verify(token)
+		require.Equal(t, ErrMalformed, err, token)
+	}
+}
+
+func Test_IsToken(t *testing.T) {
+	require.True(t, IsToken("a.b.c"))
+	require.False(t, IsToken("cp_0123456789abcdef"))
+}
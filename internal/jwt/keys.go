@@ -0,0 +1,230 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// Key verifies signatures: *rsa.PublicKey (RS256), *ecdsa.PublicKey (ES256)
+// or []byte secret (HS256). Kid and Alg are blank for PEM keys, such keys
+// match any kid.
+type Key struct {
+	Kid    string
+	Alg    string
+	Public crypto.PublicKey
+}
+
+// KeySet is a KeySource of fixed keys
+type KeySet struct {
+	Keys []Key
+}
+
+func (s *KeySet) KeySet() *KeySet {
+	return s
+}
+
+// jwk is a JSON Web Key (RFC 7517) of RSA, EC or oct type
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseKeys reads a JWKS document (`{"keys": [...]}`) or PEM public keys and
+// certificates. Keys of other use than `sig` and unknown types are skipped.
+func ParseKeys(data []byte) (*KeySet, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		return parseJWKS(data)
+	}
+	return parsePEM(data)
+}
+
+func parseJWKS(data []byte) (*KeySet, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	set := &KeySet{}
+	for i, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		public, err := k.public()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %d: %w", i, err)
+		}
+		if public != nil {
+			set.Keys = append(set.Keys, Key{Kid: k.Kid, Alg: k.Alg, Public: public})
+		}
+	}
+	return set, nil
+}
+
+func (k jwk) public() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("e: too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("crv: unsupported %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("k: invalid")
+		}
+		return secret, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func parsePEM(data []byte) (*KeySet, error) {
+	set := &KeySet{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var public crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				public = cert.PublicKey
+			}
+		default:
+			err = fmt.Errorf("unsupported block %q", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pem: %w", err)
+		}
+		set.Keys = append(set.Keys, Key{Public: public})
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		return nil, errors.New("pem: invalid data")
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("pem: no keys")
+	}
+	return set, nil
+}
+
+// KeyFile keeps keys of a JWKS or PEM file, Reload reads the file again
+// when it's changed
+type KeyFile struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    *KeySet
+	modTime time.Time
+	size    int64
+}
+
+// NewKeyFile reads keys of the file, it fails on invalid file
+func NewKeyFile(path string) (*KeyFile, error) {
+	file := &KeyFile{path: path}
+	if _, err := file.Reload(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *KeyFile) KeySet() *KeySet {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.keys
+}
+
+// Reload reads keys when modification time or size of the file is changed
+// and reports whether they're replaced. Current keys are kept on errors.
+func (f *KeyFile) Reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.RLock()
+	changed := f.keys == nil || !info.ModTime().Equal(f.modTime) || info.Size() != f.size
+	f.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	keys, err := ParseKeys(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys, f.modTime, f.size = keys, info.ModTime(), info.Size()
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+)
+
+// API key scopes
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	// ScopeProductsPurge allows to permanently delete trashed products
+	ScopeProductsPurge = "products:purge"
+	ScopeWebhooksRead  = "webhooks:read"
+	ScopeWebhooksWrite = "webhooks:write"
+	ScopeAdmin         = "admin"
+)
+
+// Scopes are all scopes keys can have
+var Scopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeProductsPurge, ScopeWebhooksRead, ScopeWebhooksWrite, ScopeAdmin}
+
+// APIKeyPrefix starts every key, so leaked keys are easy to find
+const APIKeyPrefix = "cp_"
+const APIKeyBytes = 32
+const APIKeyPrefixLength = len(APIKeyPrefix) + 8
+const APIKeyNameMaxLength = 250
+
+const APIKeyValidationNameRequired = "The Name field is required."
+const APIKeyValidationNameMaxLength = "The Name may not be greater than 250 characters."
+const APIKeyValidationScopes = "The Scopes must be a non-empty list of products:read, products:write, products:purge, webhooks:read, webhooks:write, admin."
+const APIKeyValidationRoles = "The Roles must be a list of lowercase role names."
+const APIKeyValidationTenant = "The Tenant must be 1-64 lowercase letters, digits, '-' or '_'."
+
+// rolePattern is the format of role names of the policy
+var rolePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// APIKey authenticates requests with `Authorization: Bearer <key>`. Only
+// the hash of the key is stored, Key is set when the key is created or
+// rotated. Revoked keys are kept to show their usage. Keys without TenantId
+// choose the tenant of the request.
+type APIKey struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Roles      []string   `json:"roles"`
+	TenantId   *string    `json:"tenant_id"`
+	Key        string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// APIKeyParams are writable key fields of a request body
+type APIKeyParams struct {
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+	Roles    []string `json:"roles"`
+	TenantId *string  `json:"tenant_id"`
+}
+
+func (k APIKey) Validate() []utils.FieldError {
+	return validation.Validate(
+		validation.Field("name", k.Name,
+			validation.Required().WithMessage(APIKeyValidationNameRequired),
+			validation.MaxLength(APIKeyNameMaxLength).WithMessage(APIKeyValidationNameMaxLength),
+		),
+		validation.Field("scopes", k.Scopes,
+			validation.Func(validation.CodeRequired, APIKeyValidationScopes, isScopes),
+		),
+		validation.Field("roles", k.Roles,
+			validation.Func(validation.CodePattern, APIKeyValidationRoles, isRoles),
+		),
+		validation.Field("tenant_id", k.TenantId,
+			validation.Func(validation.CodePattern, APIKeyValidationTenant, isTenant),
+		),
+	)
+}
+
+// HasScope checks that the key is allowed to use routes of the scope
+func (k APIKey) HasScope(scope string) bool {
+	return contains(k.Scopes, scope)
+}
+
+// Generate sets a new random Key and its Prefix, the hash of the key is
+// returned to be stored
+func (k *APIKey) Generate() (string, error) {
+	secret := make([]byte, APIKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	k.Key = APIKeyPrefix + hex.EncodeToString(secret)
+	k.Prefix = k.Key[:APIKeyPrefixLength]
+	return HashAPIKey(k.Key), nil
+}
+
+// HashAPIKey returns the stored hash of the key, keys are random so a fast
+// hash is enough
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func isScopes(value interface{}) bool {
+	scopes, _ := value.([]string)
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !contains(Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func isRoles(value interface{}) bool {
+	roles, _ := value.([]string)
+	for _, role := range roles {
+		if !rolePattern.MatchString(role) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTenant(value interface{}) bool {
+	tenant, _ := value.(string)
+	return IsTenant(tenant)
+}
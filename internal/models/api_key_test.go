@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_APIKey_Validate(t *testing.T) {
+	tenant, invalidTenant := "acme", "Acme Inc"
+	testCases := []struct {
+		name       string
+		key        APIKey
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:       "valid",
+			key:        APIKey{Name: "CI", Scopes: []string{ScopeProductsRead, ScopeAdmin}, Roles: []string{"pricing-manager"}, TenantId: &tenant},
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name: "blank",
+			key:  APIKey{},
+			wantErrors: []utils.FieldError{
+				{Field: "name", Code: validation.CodeRequired, Message: APIKeyValidationNameRequired},
+				{Field: "scopes", Code: validation.CodeRequired, Message: APIKeyValidationScopes},
+			},
+		},
+		{
+			name: "invalid",
+			key: APIKey{
+				Name:     strings.Repeat("n", APIKeyNameMaxLength+1),
+				Scopes:   []string{"products:delete"},
+				Roles:    []string{"editor", "Pricing Manager"},
+				TenantId: &invalidTenant,
+			},
+			wantErrors: []utils.FieldError{
+				{Field: "name", Code: validation.CodeMaxLength, Message: APIKeyValidationNameMaxLength},
+				{Field: "scopes", Code: validation.CodeRequired, Message: APIKeyValidationScopes},
+				{Field: "roles", Code: validation.CodePattern, Message: APIKeyValidationRoles},
+				{Field: "tenant_id", Code: validation.CodePattern, Message: APIKeyValidationTenant},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantErrors, tc.key.Validate())
+		})
+	}
+}
+
+func Test_APIKey_HasScope(t *testing.T) {
+	key := APIKey{Scopes: []string{ScopeProductsRead}}
+
+	require.True(t, key.HasScope(ScopeProductsRead))
+	require.False(t, key.HasScope(ScopeProductsWrite))
+}
+
+func Test_APIKey_Generate(t *testing.T) {
+	var key APIKey
+
+	hash, err := key.Generate()
+
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key.Key, APIKeyPrefix))
+	require.Len(t, key.Key, len(APIKeyPrefix)+2*APIKeyBytes)
+	require.Equal(t, key.Key[:APIKeyPrefixLength], key.Prefix)
+	require.Equal(t, HashAPIKey(key.Key), hash)
+	require.Len(t, hash, 64)
+
+	other := APIKey{}
+	_, err = other.Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, key.Key, other.Key)
+}
+
+func Test_HashAPIKey(t *testing.T) {
+	require.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", HashAPIKey("hello"))
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey keeps the response of a request sent with Idempotency-Key
+// header, Status is nil while the request is in flight
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	Status      *int
+	Headers     http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
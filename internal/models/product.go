@@ -1,23 +1,63 @@
 package models
 
+import (
+	"time"
+
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+)
+
+const ProductNameMaxLength = 250
+const ProductPriceScale = 2
+
 const ProductValidationNameRequired = "The Name field is required."
+const ProductValidationNameMaxLength = "The Name may not be greater than 250 characters."
+const ProductValidationPriceRequired = "The Price field is required."
 const ProductValidationPriceGte = "The Price must be greater than or equal 0."
+const ProductValidationPriceDecimal = "The Price may not have more than 2 decimal places."
 
+// Product Version is incremented on every update, it is sent as ETag.
+// DeletedAt is set for soft deleted products.
 type Product struct {
-	Id    int     `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Id        int        `json:"id"`
+	Name      string     `json:"name"`
+	Price     float64    `json:"price"`
+	Version   int        `json:"-"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
-func (p Product) Validate() []string {
-	messages := []string{}
-	if p.Name == "" {
-		messages = append(messages, ProductValidationNameRequired)
-	}
-	if p.Price < 0 {
-		messages = append(messages, ProductValidationPriceGte)
-	}
-	return messages
+// ProductParams are writable product fields of a request body, nil means
+// the field is missing
+type ProductParams struct {
+	Name  *string  `json:"name"`
+	Price *float64 `json:"price"`
+}
+
+// ProductWritableFields are fields a request may set
+var ProductWritableFields = []string{"name", "price"}
+
+// ProductQuerySchema is a whitelist of fields for sorting and filtering lists
+var ProductQuerySchema = query.Schema{
+	Fields: []query.Field{
+		{Name: "id", Type: query.TypeInt, Sortable: true, Filters: []query.Op{query.OpEq}},
+		{Name: "name", Type: query.TypeString, Sortable: true, Filters: []query.Op{query.OpEq, query.OpContains}},
+		{Name: "price", Type: query.TypeFloat, Sortable: true, Filters: []query.Op{query.OpEq, query.OpMin, query.OpMax}},
+	},
+	DefaultSort: []query.Sort{{Field: "id"}},
+}
+
+func (p Product) Validate() []utils.FieldError {
+	return validation.Validate(
+		validation.Field("name", p.Name,
+			validation.Required().WithMessage(ProductValidationNameRequired),
+			validation.MaxLength(ProductNameMaxLength).WithMessage(ProductValidationNameMaxLength),
+		),
+		validation.Field("price", p.Price,
+			validation.Min(0).WithMessage(ProductValidationPriceGte),
+			validation.Decimal(ProductPriceScale).WithMessage(ProductValidationPriceDecimal),
+		),
+	)
 }
 
 func (p *Product) Fill(params struct {
@@ -27,3 +67,60 @@ func (p *Product) Fill(params struct {
 	p.Name = params.Name
 	p.Price = params.Price
 }
+
+// ValidatePresence checks that all fields are present (full replace), values
+// are checked by Validate after Apply
+func (p ProductParams) ValidatePresence() []utils.FieldError {
+	errors := []utils.FieldError{}
+	if p.Name == nil {
+		errors = append(errors, utils.FieldError{
+			Field:   "name",
+			Code:    validation.CodeRequired,
+			Message: ProductValidationNameRequired,
+		})
+	}
+	if p.Price == nil {
+		errors = append(errors, utils.FieldError{
+			Field:   "price",
+			Code:    validation.CodeRequired,
+			Message: ProductValidationPriceRequired,
+		})
+	}
+	return errors
+}
+
+// Apply sets present params, missing params keep current values
+func (p *Product) Apply(params ProductParams) {
+	if params.Name != nil {
+		p.Name = *params.Name
+	}
+	if params.Price != nil {
+		p.Price = *params.Price
+	}
+}
+
+// ChangedFields lists writable fields which differ from the other product
+func (p Product) ChangedFields(other Product) []string {
+	fields := []string{}
+	if p.Name != other.Name {
+		fields = append(fields, "name")
+	}
+	if p.Price != other.Price {
+		fields = append(fields, "price")
+	}
+	return fields
+}
+
+// Batch operations
+const (
+	ProductOpCreate = "create"
+	ProductOpUpdate = "update"
+	ProductOpDelete = "delete"
+)
+
+// ProductOperation is an item of a batch write. Product Id and Version are
+// used by update and delete, Version 0 skips the version check.
+type ProductOperation struct {
+	Op      string
+	Product Product
+}
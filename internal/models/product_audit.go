@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// Product audit actions
+const (
+	ProductAuditCreate  = "create"
+	ProductAuditUpdate  = "update"
+	ProductAuditDelete  = "delete"
+	ProductAuditRestore = "restore"
+	ProductAuditPurge   = "purge"
+)
+
+// FieldChange keeps values of a changed field, nil is a missing value
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ProductAudit is a change of a product made by Actor in the request with
+// RequestId, Changes are keyed by field name. TenantId is the tenant of the
+// product, blank is the tenant of the connection.
+type ProductAudit struct {
+	Id        int64                  `json:"id"`
+	ProductId int                    `json:"product_id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	RequestId string                 `json:"request_id"`
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+	TenantId  string                 `json:"-"`
+}
+
+// ProductChanges returns changed fields between two states of a product, nil
+// before is a created product and nil after is a purged one
+func ProductChanges(before *Product, after *Product) map[string]FieldChange {
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+	changes := map[string]FieldChange{}
+	for i, field := range productAuditFields {
+		if !equalValues(beforeFields[i], afterFields[i]) {
+			changes[field] = FieldChange{Before: beforeFields[i], After: afterFields[i]}
+		}
+	}
+	return changes
+}
+
+var productAuditFields = []string{"name", "price", "deleted_at"}
+
+func auditFields(p *Product) []interface{} {
+	if p == nil {
+		return make([]interface{}, len(productAuditFields))
+	}
+	var deletedAt interface{}
+	if p.DeletedAt != nil {
+		deletedAt = p.DeletedAt.UTC()
+	}
+	return []interface{}{p.Name, p.Price, deletedAt}
+}
+
+func equalValues(a interface{}, b interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return a == b
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ProductChanges(t *testing.T) {
+	deletedAt := time.Date(2021, 7, 24, 10, 0, 0, 0, time.UTC)
+	sameDeletedAt := deletedAt.In(time.FixedZone("MSK", 3*60*60))
+
+	testCases := []struct {
+		name   string
+		before *Product
+		after  *Product
+		want   map[string]FieldChange
+	}{
+		{
+			name:   "create",
+			before: nil,
+			after:  &Product{Id: 1, Name: "Name", Price: 0, Version: 1},
+			want: map[string]FieldChange{
+				"name":  {Before: nil, After: "Name"},
+				"price": {Before: nil, After: 0.0},
+			},
+		},
+		{
+			name:   "update",
+			before: &Product{Id: 1, Name: "Name", Price: 1, Version: 1},
+			after:  &Product{Id: 1, Name: "Name", Price: 2, Version: 2},
+			want: map[string]FieldChange{
+				"price": {Before: 1.0, After: 2.0},
+			},
+		},
+		{
+			name:   "not changed",
+			before: &Product{Id: 1, Name: "Name", Price: 1, DeletedAt: &deletedAt},
+			after:  &Product{Id: 1, Name: "Name", Price: 1, DeletedAt: &sameDeletedAt},
+			want:   map[string]FieldChange{},
+		},
+		{
+			name:   "delete",
+			before: &Product{Id: 1, Name: "Name", Price: 1},
+			after:  &Product{Id: 1, Name: "Name", Price: 1, DeletedAt: &sameDeletedAt},
+			want: map[string]FieldChange{
+				"deleted_at": {Before: nil, After: deletedAt},
+			},
+		},
+		{
+			name:   "purge",
+			before: &Product{Id: 1, Name: "Name", Price: 1, DeletedAt: &deletedAt},
+			after:  nil,
+			want: map[string]FieldChange{
+				"name":       {Before: "Name", After: nil},
+				"price":      {Before: 1.0, After: nil},
+				"deleted_at": {Before: deletedAt, After: nil},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, ProductChanges(tc.before, tc.after))
+		})
+	}
+}
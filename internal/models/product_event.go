@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Product event types
+const (
+	ProductEventCreated = "product.created"
+	ProductEventUpdated = "product.updated"
+	ProductEventDeleted = "product.deleted"
+)
+
+// ProductEvent is a change of a product published to downstream systems.
+// Data is the product after the change, nil for deleted products. Changes
+// are changed fields like in the audit. Events are delivered at least once,
+// consumers dedupe them by Id. TenantId is used to route events and isn't
+// published.
+type ProductEvent struct {
+	Id        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	ProductId int                    `json:"product_id"`
+	Version   int                    `json:"version"`
+	Data      *Product               `json:"data"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	RequestId string                 `json:"request_id"`
+	CreatedAt time.Time              `json:"created_at"`
+	TenantId  string                 `json:"-"`
+
+	// Delivery state
+	Attempts      int       `json:"-"`
+	NextAttemptAt time.Time `json:"-"`
+}
+
+// DeadProductEvent is an event which failed to publish MaxAttempts times
+// (see outbox.Relay), it's published again only when redelivered
+type DeadProductEvent struct {
+	ProductEvent
+	LastError *string   `json:"last_error"`
+	DeadAt    time.Time `json:"dead_at"`
+}
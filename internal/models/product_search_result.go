@@ -0,0 +1,10 @@
+package models
+
+// ProductSearchResult is a product found by full-text or fuzzy search,
+// Snippet is the HTML escaped name with matched words wrapped in
+// <mark></mark>
+type ProductSearchResult struct {
+	Product
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
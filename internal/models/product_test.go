@@ -1,52 +1,79 @@
 package models
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/roman-wb/crud-products/pkg/utils"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_Product_Const(t *testing.T) {
 	require.Equal(t, "The Name field is required.", ProductValidationNameRequired)
+	require.Equal(t, "The Name may not be greater than 250 characters.", ProductValidationNameMaxLength)
 	require.Equal(t, "The Price must be greater than or equal 0.", ProductValidationPriceGte)
+	require.Equal(t, "The Price may not have more than 2 decimal places.", ProductValidationPriceDecimal)
 }
 
 func Test_Product_Validate(t *testing.T) {
+	nameRequired := utils.FieldError{Field: "name", Code: "required", Message: ProductValidationNameRequired}
+	priceGte := utils.FieldError{Field: "price", Code: "min", Message: ProductValidationPriceGte}
+	nameMaxLength := utils.FieldError{Field: "name", Code: "max_length", Message: ProductValidationNameMaxLength}
+	priceDecimal := utils.FieldError{Field: "price", Code: "decimal", Message: ProductValidationPriceDecimal}
+
 	testCases := []struct {
 		name         string
 		product      Product
 		wantLen      int
-		wantMessages []string
+		wantMessages []utils.FieldError
 	}{
 		{
 			name:         "valid with price 0",
 			product:      Product{Name: "Name", Price: 0},
 			wantLen:      0,
-			wantMessages: []string{},
+			wantMessages: []utils.FieldError{},
 		},
 		{
 			name:         "valid with price gt 1",
 			product:      Product{Name: "Name", Price: 1},
 			wantLen:      0,
-			wantMessages: []string{},
+			wantMessages: []utils.FieldError{},
 		},
 		{
 			name:         "invalid all",
 			product:      Product{Name: "", Price: -1},
 			wantLen:      2,
-			wantMessages: []string{ProductValidationNameRequired, ProductValidationPriceGte},
+			wantMessages: []utils.FieldError{nameRequired, priceGte},
 		},
 		{
 			name:         "invalid name",
 			product:      Product{Name: "", Price: 0},
 			wantLen:      1,
-			wantMessages: []string{ProductValidationNameRequired},
+			wantMessages: []utils.FieldError{nameRequired},
+		},
+		{
+			name:         "valid with max length and cents",
+			product:      Product{Name: strings.Repeat("я", 250), Price: 100.99},
+			wantLen:      0,
+			wantMessages: []utils.FieldError{},
+		},
+		{
+			name:         "blank name",
+			product:      Product{Name: "   ", Price: 1},
+			wantLen:      1,
+			wantMessages: []utils.FieldError{nameRequired},
+		},
+		{
+			name:         "too long name and too precise price",
+			product:      Product{Name: strings.Repeat("a", 251), Price: 1.001},
+			wantLen:      2,
+			wantMessages: []utils.FieldError{nameMaxLength, priceDecimal},
 		},
 		{
 			name:         "invalid price",
 			product:      Product{Name: "Name", Price: -1},
 			wantLen:      1,
-			wantMessages: []string{ProductValidationPriceGte},
+			wantMessages: []utils.FieldError{priceGte},
 		},
 	}
 
@@ -99,3 +126,73 @@ func Test_Product_Fill(t *testing.T) {
 		})
 	}
 }
+
+func Test_ProductParams_ValidatePresence(t *testing.T) {
+	name := "Name 1"
+	blank := ""
+	price := 0.0
+
+	testCases := []struct {
+		name       string
+		params     ProductParams
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:       "all present",
+			params:     ProductParams{Name: &name, Price: &price},
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name:   "all missing",
+			params: ProductParams{},
+			wantErrors: []utils.FieldError{
+				{Field: "name", Code: "required", Message: ProductValidationNameRequired},
+				{Field: "price", Code: "required", Message: ProductValidationPriceRequired},
+			},
+		},
+		{
+			name:       "blank name is present",
+			params:     ProductParams{Name: &blank, Price: &price},
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name:   "missing price",
+			params: ProductParams{Name: &name},
+			wantErrors: []utils.FieldError{
+				{Field: "price", Code: "required", Message: ProductValidationPriceRequired},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantErrors, tc.params.ValidatePresence())
+		})
+	}
+}
+
+func Test_Product_Apply(t *testing.T) {
+	name := "Name 2"
+	price := 200.0
+
+	product := Product{Id: 1, Name: "Name 1", Price: 100}
+	product.Apply(ProductParams{Price: &price})
+	require.Equal(t, Product{Id: 1, Name: "Name 1", Price: 200}, product)
+
+	product.Apply(ProductParams{Name: &name})
+	require.Equal(t, Product{Id: 1, Name: "Name 2", Price: 200}, product)
+
+	product.Apply(ProductParams{})
+	require.Equal(t, Product{Id: 1, Name: "Name 2", Price: 200}, product)
+}
+
+func Test_Product_ChangedFields(t *testing.T) {
+	product := Product{Id: 1, Name: "Name 1", Price: 100}
+
+	require.Equal(t, []string{}, product.ChangedFields(product))
+	require.Equal(t, []string{"price"}, Product{Id: 1, Name: "Name 1", Price: 200}.ChangedFields(product))
+	require.Equal(t, []string{"name", "price"}, Product{Id: 1, Name: "Name 2", Price: 200}.ChangedFields(product))
+}
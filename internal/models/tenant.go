@@ -0,0 +1,11 @@
+package models
+
+import "regexp"
+
+// tenantPattern is the format of tenant ids, they're stored as varchar(64)
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// IsTenant checks the format of the tenant id
+func IsTenant(id string) bool {
+	return tenantPattern.MatchString(id)
+}
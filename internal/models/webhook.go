@@ -0,0 +1,139 @@
+package models
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+)
+
+const WebhookURLMaxLength = 2048
+const WebhookSecretMinLength = 16
+const WebhookSecretMaxLength = 255
+
+const WebhookValidationURL = "The URL must be an absolute http(s) URL."
+const WebhookValidationEvents = "The Events must be a non-empty list of product.created, product.updated, product.deleted."
+
+// Webhook delivery statuses, dead deliveries ran out of attempts
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookEventTypes are event types available for subscriptions
+var WebhookEventTypes = []string{ProductEventCreated, ProductEventUpdated, ProductEventDeleted}
+
+// WebhookSubscription sends product events of Events types to URL. Secret
+// signs deliveries, it is returned only when it's set.
+type WebhookSubscription struct {
+	Id        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookSubscriptionParams are writable fields of a request body, nil means
+// the field is missing
+type WebhookSubscriptionParams struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Secret *string  `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+// Validate checks the secret only if it's set, the stored secret is not read
+func (w WebhookSubscription) Validate() []utils.FieldError {
+	var secret *string
+	if w.Secret != "" {
+		secret = &w.Secret
+	}
+	return validation.Validate(
+		validation.Field("url", w.URL,
+			validation.Required().WithMessage(WebhookValidationURL),
+			validation.MaxLength(WebhookURLMaxLength),
+			validation.Func(validation.CodePattern, WebhookValidationURL, isWebhookURL),
+		),
+		validation.Field("events", w.Events,
+			validation.Func(validation.CodeRequired, WebhookValidationEvents, isWebhookEvents),
+		),
+		validation.Field("secret", secret,
+			validation.MinLength(WebhookSecretMinLength),
+			validation.MaxLength(WebhookSecretMaxLength),
+		),
+	)
+}
+
+// Apply sets present params, missing params keep current values
+func (w *WebhookSubscription) Apply(params WebhookSubscriptionParams) {
+	if params.URL != nil {
+		w.URL = *params.URL
+	}
+	if params.Events != nil {
+		w.Events = params.Events
+	}
+	if params.Secret != nil {
+		w.Secret = *params.Secret
+	}
+	if params.Active != nil {
+		w.Active = *params.Active
+	}
+}
+
+// Subscribed checks that the subscription wants events of the type
+func (w WebhookSubscription) Subscribed(eventType string) bool {
+	return contains(w.Events, eventType)
+}
+
+func isWebhookURL(value interface{}) bool {
+	raw, _ := value.(string)
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isWebhookEvents(value interface{}) bool {
+	events, _ := value.([]string)
+	if len(events) == 0 {
+		return false
+	}
+	for _, event := range events {
+		if !contains(WebhookEventTypes, event) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery sends the event (Payload) to the subscription, failed
+// deliveries are retried until they are dead
+type WebhookDelivery struct {
+	Id             int64        `json:"id"`
+	SubscriptionId int          `json:"subscription_id"`
+	EventId        int64        `json:"event_id"`
+	EventType      string       `json:"event_type"`
+	Payload        ProductEvent `json:"payload"`
+	Status         string       `json:"status"`
+	Attempts       int          `json:"attempts"`
+	NextAttemptAt  time.Time    `json:"next_attempt_at"`
+	LastStatusCode *int         `json:"last_status_code"`
+	LastError      *string      `json:"last_error"`
+	CreatedAt      time.Time    `json:"created_at"`
+	DeliveredAt    *time.Time   `json:"delivered_at"`
+
+	// Target of the delivery, it is set for claimed deliveries
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
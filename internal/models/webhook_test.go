@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WebhookSubscription_Validate(t *testing.T) {
+	valid := WebhookSubscription{URL: "https://example.com/hooks", Events: []string{ProductEventCreated}}
+
+	testCases := []struct {
+		name         string
+		subscription func(w WebhookSubscription) WebhookSubscription
+		wantErrors   []utils.FieldError
+	}{
+		{
+			name:         "valid without secret",
+			subscription: func(w WebhookSubscription) WebhookSubscription { return w },
+			wantErrors:   []utils.FieldError{},
+		},
+		{
+			name: "valid with secret",
+			subscription: func(w WebhookSubscription) WebhookSubscription {
+				w.Secret = strings.Repeat("s", WebhookSecretMinLength)
+				return w
+			},
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name: "blank",
+			subscription: func(w WebhookSubscription) WebhookSubscription {
+				return WebhookSubscription{}
+			},
+			wantErrors: []utils.FieldError{
+				{Field: "url", Code: validation.CodeRequired, Message: WebhookValidationURL},
+				{Field: "events", Code: validation.CodeRequired, Message: WebhookValidationEvents},
+			},
+		},
+		{
+			name: "invalid",
+			subscription: func(w WebhookSubscription) WebhookSubscription {
+				return WebhookSubscription{URL: "ftp://example.com", Events: []string{"product.viewed"}, Secret: "short"}
+			},
+			wantErrors: []utils.FieldError{
+				{Field: "url", Code: validation.CodePattern, Message: WebhookValidationURL},
+				{Field: "events", Code: validation.CodeRequired, Message: WebhookValidationEvents},
+				{Field: "secret", Code: validation.CodeMinLength, Message: "must be at least 16 characters"},
+			},
+		},
+		{
+			name: "relative url",
+			subscription: func(w WebhookSubscription) WebhookSubscription {
+				w.URL = "/hooks"
+				return w
+			},
+			wantErrors: []utils.FieldError{{Field: "url", Code: validation.CodePattern, Message: WebhookValidationURL}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantErrors, tc.subscription(valid).Validate())
+		})
+	}
+}
+
+func Test_WebhookSubscription_Apply(t *testing.T) {
+	url, secret, active := "https://example.com/new", "new-secret-new-secret", false
+	subscription := WebhookSubscription{Id: 1, URL: "https://example.com", Events: []string{ProductEventCreated}, Active: true}
+
+	subscription.Apply(WebhookSubscriptionParams{Events: []string{ProductEventDeleted}})
+	require.Equal(t, WebhookSubscription{Id: 1, URL: "https://example.com", Events: []string{ProductEventDeleted}, Active: true}, subscription)
+
+	subscription.Apply(WebhookSubscriptionParams{URL: &url, Secret: &secret, Active: &active})
+	require.Equal(t, WebhookSubscription{Id: 1, URL: url, Events: []string{ProductEventDeleted}, Secret: secret}, subscription)
+
+	require.True(t, subscription.Subscribed(ProductEventDeleted))
+	require.False(t, subscription.Subscribed(ProductEventCreated))
+}
@@ -0,0 +1,133 @@
+// Package openapi embeds the OpenAPI 3 document of the API and the docs page
+// rendering it, requests and responses are validated against the document
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+)
+
+// Spec is the OpenAPI 3 document served at /openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Docs is the HTML page rendering Spec with Swagger UI
+//
+//go:embed docs.html
+var Docs []byte
+
+// Document is the part of an OpenAPI document used by the validator, paths
+// are keyed by template and lower case method
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+	Responses  map[string]*Response  `json:"responses"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	AllOf                []*Schema          `json:"allOf"`
+	OneOf                []*Schema          `json:"oneOf"`
+}
+
+// Load parses Spec
+func Load() (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(Spec, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// MustLoad parses Spec and panics on error, Spec is checked by tests
+func MustLoad() *Document {
+	doc, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// Operation returns the operation of the path template and method, nil if
+// it's not documented
+func (d *Document) Operation(path string, method string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+func (d *Document) schema(schema *Schema) *Schema {
+	if schema != nil && schema.Ref != "" {
+		return d.Components.Schemas[refName(schema.Ref)]
+	}
+	return schema
+}
+
+func (d *Document) parameter(parameter *Parameter) *Parameter {
+	if parameter.Ref != "" {
+		return d.Components.Parameters[refName(parameter.Ref)]
+	}
+	return parameter
+}
+
+func (d *Document) response(response *Response) *Response {
+	if response.Ref != "" {
+		return d.Components.Responses[refName(response.Ref)]
+	}
+	return response
+}
+
+// refName returns the component name of a local reference, e.g.
+// #/components/schemas/Product
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
@@ -0,0 +1,306 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+)
+
+// FieldBody is the field of errors of the whole body
+const FieldBody = "body"
+
+const MessageRequired = "is required"
+const MessageInvalidTime = "must be RFC 3339 time, e.g. 2021-07-24T00:00:00Z"
+const MessageOneOf = "doesn't match any of the schemas"
+
+var typeMessages = map[string]string{
+	"object":  "must be an object",
+	"array":   "must be an array",
+	"string":  "must be a string",
+	"integer": "must be an integer",
+	"number":  "must be a number",
+	"boolean": "must be a boolean",
+}
+
+// ValidateParams checks path, query and header params of the request,
+// pathParams are the values of path variables. Blank values are missing.
+func (d *Document) ValidateParams(operation *Operation, req *http.Request, pathParams map[string]string) []utils.FieldError {
+	errors := []utils.FieldError{}
+	values := req.URL.Query()
+	for _, parameter := range operation.Parameters {
+		parameter = d.parameter(parameter)
+
+		var raw string
+		switch parameter.In {
+		case "path":
+			raw = pathParams[parameter.Name]
+		case "query":
+			raw = values.Get(parameter.Name)
+		case "header":
+			raw = req.Header.Get(parameter.Name)
+		}
+		if raw == "" {
+			if parameter.Required {
+				errors = append(errors, utils.FieldError{Field: parameter.Name, Code: validation.CodeRequired, Message: MessageRequired})
+			}
+			continue
+		}
+
+		errors = append(errors, d.Validate(parameter.Schema, paramValue(d.schema(parameter.Schema), raw), parameter.Name)...)
+	}
+	return errors
+}
+
+// RequestSchema returns the body schema of the media type (without
+// parameters), false if the operation doesn't accept it
+func (d *Document) RequestSchema(operation *Operation, contentType string) (*Schema, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	content, ok := operation.RequestBody.Content[mediaType]
+	return content.Schema, ok
+}
+
+// ValidateResponse checks that the status is documented and the JSON body
+// matches its schema, bodies of responses without content aren't checked
+func (d *Document) ValidateResponse(operation *Operation, status int, contentType string, body []byte) []utils.FieldError {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return []utils.FieldError{{Field: "status", Code: query.CodeUnknown, Message: fmt.Sprintf("%d is not documented", status)}}
+	}
+	response = d.response(response)
+	if len(response.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return []utils.FieldError{{Field: utils.HeaderContentType, Code: query.CodeUnknown, Message: fmt.Sprintf("%q is not documented", contentType)}}
+	}
+	if content.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	value, err := DecodeJSON(body)
+	if err != nil {
+		return []utils.FieldError{{Field: FieldBody, Code: query.CodeInvalid, Message: err.Error()}}
+	}
+	return d.Validate(content.Schema, value, "")
+}
+
+// DecodeJSON decodes the first JSON value of data like handlers do, numbers
+// are kept as json.Number
+func DecodeJSON(data []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Validate checks a decoded JSON value against the schema, field is the path
+// of the value in errors (e.g. operations[0].data.name), blank for the body.
+// oneOf passes if any schema matches.
+func (d *Document) Validate(schema *Schema, value interface{}, field string) []utils.FieldError {
+	schema = d.schema(schema)
+	if schema == nil {
+		return nil
+	}
+	if value == nil {
+		if schema.Nullable || (schema.Type == "" && len(schema.AllOf) == 0 && len(schema.OneOf) == 0) {
+			return nil
+		}
+		return []utils.FieldError{{Field: fieldName(field), Code: query.CodeInvalid, Message: "must not be null"}}
+	}
+
+	errors := []utils.FieldError{}
+	for _, sub := range schema.AllOf {
+		errors = append(errors, d.Validate(sub, value, field)...)
+	}
+	if len(schema.OneOf) > 0 && !d.matchesAny(schema.OneOf, value, field) {
+		errors = append(errors, utils.FieldError{Field: fieldName(field), Code: query.CodeInvalid, Message: MessageOneOf})
+	}
+	if schema.Type != "" && !hasType(value, schema.Type) {
+		return append(errors, utils.FieldError{Field: fieldName(field), Code: query.CodeInvalid, Message: typeMessages[schema.Type]})
+	}
+
+	errors = append(errors, validation.Validate(validation.Field(fieldName(field), value, rules(schema)...))...)
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				errors = append(errors, utils.FieldError{Field: join(field, name), Code: validation.CodeRequired, Message: MessageRequired})
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			item := value[name]
+			if property, ok := schema.Properties[name]; ok {
+				errors = append(errors, d.Validate(property, item, join(field, name))...)
+			} else if schema.AdditionalProperties != nil {
+				errors = append(errors, d.Validate(schema.AdditionalProperties, item, join(field, name))...)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			errors = append(errors, d.Validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	}
+	return errors
+}
+
+func (d *Document) matchesAny(schemas []*Schema, value interface{}, field string) bool {
+	for _, schema := range schemas {
+		if len(d.Validate(schema, value, field)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns the value rules of the schema, the type is already checked
+func rules(schema *Schema) []validation.Rule {
+	var rules []validation.Rule
+	if len(schema.Enum) > 0 {
+		rules = append(rules, enumRule(schema.Enum))
+	}
+	if schema.Format == "date-time" {
+		rules = append(rules, validation.Func(query.CodeInvalid, MessageInvalidTime, func(value interface{}) bool {
+			_, err := time.Parse(time.RFC3339, value.(string))
+			return err == nil
+		}))
+	}
+	if schema.MinLength != nil {
+		rules = append(rules, validation.MinLength(*schema.MinLength))
+	}
+	if schema.MaxLength != nil {
+		rules = append(rules, validation.MaxLength(*schema.MaxLength))
+	}
+	if schema.Minimum != nil {
+		rules = append(rules, validation.Min(*schema.Minimum))
+	}
+	if schema.Maximum != nil {
+		rules = append(rules, validation.Max(*schema.Maximum))
+	}
+	if schema.MinItems != nil || schema.MaxItems != nil {
+		rules = append(rules, itemsRule(schema.MinItems, schema.MaxItems))
+	}
+	return rules
+}
+
+func enumRule(enum []interface{}) validation.Rule {
+	values := make([]string, len(enum))
+	for i, value := range enum {
+		values[i] = fmt.Sprint(value)
+	}
+	return validation.Func(query.CodeInvalid, "must be one of "+strings.Join(values, ", "), func(value interface{}) bool {
+		for _, allowed := range values {
+			if fmt.Sprint(value) == allowed {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func itemsRule(min *int, max *int) validation.Rule {
+	var message string
+	switch {
+	case min != nil && max != nil:
+		message = fmt.Sprintf("must have %d-%d items", *min, *max)
+	case min != nil:
+		message = fmt.Sprintf("must have at least %d items", *min)
+	default:
+		message = fmt.Sprintf("must have at most %d items", *max)
+	}
+	return validation.Func(query.CodeOutOfRange, message, func(value interface{}) bool {
+		items := value.([]interface{})
+		return (min == nil || len(items) >= *min) && (max == nil || len(items) <= *max)
+	})
+}
+
+func hasType(value interface{}, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := number.Int64()
+		return err == nil
+	case "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := number.Float64()
+		return err == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	}
+	return true
+}
+
+// paramValue converts the raw param to the JSON value of the schema type,
+// unparsable values are checked as strings and fail the type check
+func paramValue(schema *Schema, raw string) interface{} {
+	if schema == nil {
+		return raw
+	}
+	switch schema.Type {
+	case "integer", "number":
+		return json.Number(raw)
+	case "boolean":
+		if value, err := strconv.ParseBool(raw); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == utils.ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+func join(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func fieldName(field string) string {
+	if field == "" {
+		return FieldBody
+	}
+	return field
+}
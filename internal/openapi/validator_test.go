@@ -0,0 +1,260 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Load(t *testing.T) {
+	doc, err := Load()
+
+	require.NoError(t, err)
+	require.Equal(t, "3.0.3", doc.OpenAPI)
+	require.NotNil(t, doc.Operation("/products/{id}", "GET"))
+	require.Nil(t, doc.Operation("/products/{id}", "HEAD"))
+}
+
+func Test_Document_ValidateParams(t *testing.T) {
+	doc := MustLoad()
+
+	testCases := []struct {
+		name       string
+		path       string
+		method     string
+		target     string
+		header     map[string]string
+		pathParams map[string]string
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:       "valid",
+			path:       "/products",
+			method:     "GET",
+			target:     "/products?page=2&per_page=50&price_min=1.5&as_of=2021-07-24T00:00:00Z",
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name:       "blank is missing",
+			path:       "/products",
+			method:     "GET",
+			target:     "/products?page=",
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name:       "path param",
+			path:       "/products/{id}",
+			method:     "GET",
+			target:     "/products/abc",
+			pathParams: map[string]string{"id": "abc"},
+			wantErrors: []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: "must be an integer"}},
+		},
+		{
+			name:   "query params",
+			path:   "/products",
+			method: "GET",
+			target: "/products?page=0&per_page=x&as_of=yesterday",
+			wantErrors: []utils.FieldError{
+				{Field: "page", Code: validation.CodeMin, Message: "must be greater than or equal 1"},
+				{Field: "per_page", Code: query.CodeInvalid, Message: "must be an integer"},
+				{Field: "as_of", Code: query.CodeInvalid, Message: MessageInvalidTime},
+			},
+		},
+		{
+			name:       "required query param",
+			path:       "/products/history",
+			method:     "GET",
+			target:     "/products/history",
+			wantErrors: []utils.FieldError{{Field: "from", Code: validation.CodeRequired, Message: MessageRequired}},
+		},
+		{
+			name:       "header",
+			path:       "/products/stream",
+			method:     "GET",
+			target:     "/products/stream",
+			header:     map[string]string{"Last-Event-ID": "last"},
+			wantErrors: []utils.FieldError{{Field: "Last-Event-ID", Code: query.CodeInvalid, Message: "must be an integer"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			for name, value := range tc.header {
+				req.Header.Set(name, value)
+			}
+
+			got := doc.ValidateParams(doc.Operation(tc.path, tc.method), req, tc.pathParams)
+
+			require.Equal(t, tc.wantErrors, got)
+		})
+	}
+}
+
+func Test_Document_Validate(t *testing.T) {
+	doc := MustLoad()
+
+	testCases := []struct {
+		name       string
+		schema     string
+		body       string
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:       "valid",
+			schema:     "ProductInput",
+			body:       `{"name": "Apple", "price": 10.5}`,
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name:   "types",
+			schema: "ProductInput",
+			body:   `{"name": 1, "price": "10"}`,
+			wantErrors: []utils.FieldError{
+				{Field: "name", Code: query.CodeInvalid, Message: "must be a string"},
+				{Field: "price", Code: query.CodeInvalid, Message: "must be a number"},
+			},
+		},
+		{
+			name:       "body type",
+			schema:     "ProductInput",
+			body:       `[]`,
+			wantErrors: []utils.FieldError{{Field: FieldBody, Code: query.CodeInvalid, Message: "must be an object"}},
+		},
+		{
+			name:       "null",
+			schema:     "ProductInput",
+			body:       `{"name": null}`,
+			wantErrors: []utils.FieldError{{Field: "name", Code: query.CodeInvalid, Message: "must not be null"}},
+		},
+		{
+			name:       "nullable",
+			schema:     "ProductPatch",
+			body:       `{"name": null}`,
+			wantErrors: []utils.FieldError{},
+		},
+		{
+			name:   "nested",
+			schema: "BatchRequest",
+			body:   `{"mode": "all", "operations": [{"op": "create", "data": {"price": "free"}}, {"id": 1.5}]}`,
+			wantErrors: []utils.FieldError{
+				{Field: "mode", Code: query.CodeInvalid, Message: "must be one of atomic, partial"},
+				{Field: "operations[0].data.price", Code: query.CodeInvalid, Message: "must be a number"},
+				{Field: "operations[1].op", Code: validation.CodeRequired, Message: MessageRequired},
+				{Field: "operations[1].id", Code: query.CodeInvalid, Message: "must be an integer"},
+			},
+		},
+		{
+			name:       "items",
+			schema:     "BatchRequest",
+			body:       `{"operations": []}`,
+			wantErrors: []utils.FieldError{{Field: "operations", Code: query.CodeOutOfRange, Message: "must have 1-1000 items"}},
+		},
+		{
+			name:       "additional properties",
+			schema:     "ProductAudit",
+			body:       `{"id": 1, "product_id": 1, "action": "update", "actor": "anonymous", "request_id": "1", "changes": {"price": []}, "created_at": "2021-07-24T00:00:00Z"}`,
+			wantErrors: []utils.FieldError{{Field: "changes.price", Code: query.CodeInvalid, Message: "must be an object"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := DecodeJSON([]byte(tc.body))
+			require.NoError(t, err)
+
+			got := doc.Validate(doc.Components.Schemas[tc.schema], value, "")
+
+			require.Equal(t, tc.wantErrors, got)
+		})
+	}
+}
+
+func Test_Document_RequestSchema(t *testing.T) {
+	doc := MustLoad()
+	operation := doc.Operation("/products/{id}", "PATCH")
+
+	schema, ok := doc.RequestSchema(operation, "application/merge-patch+json; charset=utf-8")
+	require.True(t, ok)
+	require.Equal(t, "#/components/schemas/ProductPatch", schema.Ref)
+
+	_, ok = doc.RequestSchema(operation, "text/plain")
+	require.False(t, ok)
+
+	_, ok = doc.RequestSchema(operation, "")
+	require.False(t, ok)
+}
+
+func Test_Document_ValidateResponse(t *testing.T) {
+	doc := MustLoad()
+	operation := doc.Operation("/products/{id}", "GET")
+
+	testCases := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantErrors  []utils.FieldError
+	}{
+		{
+			name:        "valid",
+			status:      http.StatusOK,
+			contentType: utils.ContentTypeJSON,
+			body:        `{"id": 1, "name": "Apple", "price": 10}`,
+			wantErrors:  []utils.FieldError{},
+		},
+		{
+			name:        "invalid body",
+			status:      http.StatusOK,
+			contentType: utils.ContentTypeJSON,
+			body:        `{"id": 1, "name": "Apple", "price": -1}`,
+			wantErrors:  []utils.FieldError{{Field: "price", Code: validation.CodeMin, Message: "must be greater than or equal 0"}},
+		},
+		{
+			name:       "no content",
+			status:     http.StatusNotModified,
+			wantErrors: nil,
+		},
+		{
+			name:        "problem",
+			status:      http.StatusNotFound,
+			contentType: utils.ContentTypeProblemJSON,
+			body:        utils.DataToJson(utils.NewProblem(nil, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound)),
+			wantErrors:  []utils.FieldError{},
+		},
+		{
+			name:        "status",
+			status:      http.StatusTeapot,
+			contentType: utils.ContentTypeJSON,
+			wantErrors:  []utils.FieldError{{Field: "status", Code: query.CodeUnknown, Message: "418 is not documented"}},
+		},
+		{
+			name:        "content type",
+			status:      http.StatusNotFound,
+			contentType: utils.ContentTypeJSON,
+			wantErrors:  []utils.FieldError{{Field: utils.HeaderContentType, Code: query.CodeUnknown, Message: `"application/json" is not documented`}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := doc.ValidateResponse(operation, tc.status, tc.contentType, []byte(tc.body))
+
+			require.Equal(t, tc.wantErrors, got)
+		})
+	}
+}
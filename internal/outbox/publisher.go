@@ -0,0 +1,165 @@
+//go:generate mockgen -destination mock_outbox/publisher.go . Publisher
+
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+const HeaderEventID = "X-Event-Id"
+const HeaderEventType = "X-Event-Type"
+
+// HTTPTimeout limits a request of HTTPPublisher
+const HTTPTimeout = 10 * time.Second
+
+// Publisher delivers product events to downstream systems, an error means
+// the event is not delivered and it is retried later
+type Publisher interface {
+	Publish(ctx context.Context, event models.ProductEvent) error
+	Close() error
+}
+
+// NewPublisher builds the publisher by target: `stdout`, `file://<path>`
+// (JSON lines) or `http(s)://<url>` (webhook)
+func NewPublisher(target string) (Publisher, error) {
+	switch {
+	case target == "stdout":
+		return NewWriterPublisher(os.Stdout), nil
+	case strings.HasPrefix(target, "file://"):
+		file, err := os.OpenFile(strings.TrimPrefix(target, "file://"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriterPublisher(file), nil
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return NewHTTPPublisher(target, &http.Client{Timeout: HTTPTimeout}), nil
+	default:
+		return nil, fmt.Errorf("unknown publisher %q", target)
+	}
+}
+
+// WriterPublisher writes events as JSON lines
+type WriterPublisher struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterPublisher(writer io.Writer) *WriterPublisher {
+	return &WriterPublisher{
+		writer: writer,
+	}
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event models.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.writer.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the writer, stdout is kept open
+func (p *WriterPublisher) Close() error {
+	if closer, ok := p.writer.(io.Closer); ok && p.writer != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+// HTTPPublisher posts events as JSON to the webhook URL, any status but 2xx
+// is an error
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, client *http.Client) *HTTPPublisher {
+	return &HTTPPublisher{
+		url:    url,
+		client: client,
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event models.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(utils.HeaderContentType, utils.ContentTypeJSON)
+	req.Header.Set(HeaderEventID, strconv.FormatInt(event.Id, 10))
+	req.Header.Set(HeaderEventType, event.Type)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16)) //nolint:errcheck
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (p *HTTPPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// MultiPublisher publishes events to every publisher in order, an event is
+// delivered when all of them succeed. Publishers must tolerate duplicates,
+// a retry publishes the event to all of them again.
+type MultiPublisher struct {
+	publishers []Publisher
+}
+
+func NewMultiPublisher(publishers ...Publisher) *MultiPublisher {
+	return &MultiPublisher{
+		publishers: publishers,
+	}
+}
+
+func (p *MultiPublisher) Publish(ctx context.Context, event models.ProductEvent) error {
+	for _, publisher := range p.publishers {
+		err := publisher.Publish(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all publishers and returns the first error
+func (p *MultiPublisher) Close() error {
+	var first error
+	for _, publisher := range p.publishers {
+		err := publisher.Close()
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/outbox/mock_outbox"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent() models.ProductEvent {
+	return models.ProductEvent{
+		Id:        10,
+		Type:      models.ProductEventUpdated,
+		ProductId: 1,
+		Version:   2,
+		Data:      &models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 2},
+		RequestId: "request-1",
+		CreatedAt: time.Date(2021, 7, 26, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+const eventJSON = `{"id":10,"type":"product.updated","product_id":1,"version":2,` +
+	`"data":{"id":1,"name":"Name 1","price":1},"request_id":"request-1","created_at":"2021-07-26T10:00:00Z"}`
+
+func Test_NewPublisher(t *testing.T) {
+	publisher, err := NewPublisher("stdout")
+	require.Nil(t, err)
+	require.IsType(t, &WriterPublisher{}, publisher)
+	require.Nil(t, publisher.Close())
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher, err = NewPublisher("file://" + path)
+	require.Nil(t, err)
+	require.Nil(t, publisher.Publish(context.Background(), newEvent()))
+	require.Nil(t, publisher.Close())
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, eventJSON+"\n", string(data))
+
+	publisher, err = NewPublisher("https://example.com/events")
+	require.Nil(t, err)
+	require.IsType(t, &HTTPPublisher{}, publisher)
+
+	_, err = NewPublisher("kafka://localhost")
+	require.EqualError(t, err, `unknown publisher "kafka://localhost"`)
+}
+
+func Test_WriterPublisher(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	publisher := NewWriterPublisher(buffer)
+
+	require.Nil(t, publisher.Publish(context.Background(), newEvent()))
+	require.Nil(t, publisher.Publish(context.Background(), newEvent()))
+
+	require.Equal(t, eventJSON+"\n"+eventJSON+"\n", buffer.String())
+}
+
+func Test_HTTPPublisher(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusNoContent},
+		{name: "error status", status: http.StatusBadGateway, wantErr: "webhook responded with status 502"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotReq *http.Request
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				gotReq = req
+				gotBody, _ = io.ReadAll(req.Body)
+				res.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			publisher := NewHTTPPublisher(server.URL, server.Client())
+			err := publisher.Publish(context.Background(), newEvent())
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+			} else {
+				require.Nil(t, err)
+			}
+
+			require.Equal(t, http.MethodPost, gotReq.Method)
+			require.Equal(t, utils.ContentTypeJSON, gotReq.Header.Get(utils.HeaderContentType))
+			require.Equal(t, "10", gotReq.Header.Get(HeaderEventID))
+			require.Equal(t, models.ProductEventUpdated, gotReq.Header.Get(HeaderEventType))
+			require.Equal(t, eventJSON, string(gotBody))
+			require.Nil(t, publisher.Close())
+		})
+	}
+}
+
+func Test_HTTPPublisher_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	publisher := NewHTTPPublisher(server.URL, server.Client())
+
+	require.NotNil(t, publisher.Publish(context.Background(), newEvent()))
+}
+
+func Test_MultiPublisher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	first := mock_outbox.NewMockPublisher(ctrl)
+	second := mock_outbox.NewMockPublisher(ctrl)
+	publisher := NewMultiPublisher(first, second)
+	event := newEvent()
+
+	gomock.InOrder(
+		first.EXPECT().Publish(gomock.Any(), event).Return(nil),
+		second.EXPECT().Publish(gomock.Any(), event).Return(nil),
+		first.EXPECT().Publish(gomock.Any(), event).Return(errors.New("broken pipe")),
+	)
+	require.Nil(t, publisher.Publish(context.Background(), event))
+	require.EqualError(t, publisher.Publish(context.Background(), event), "broken pipe")
+
+	first.EXPECT().Close().Return(errors.New("close failed"))
+	second.EXPECT().Close().Return(nil)
+	require.EqualError(t, publisher.Close(), "close failed")
+}
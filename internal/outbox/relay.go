@@ -0,0 +1,113 @@
+//go:generate mockgen -destination mock_outbox/repo.go . Repo
+
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"go.uber.org/zap"
+)
+
+const DefaultBatchSize = 100
+const DefaultMaxAttempts = 20
+const DefaultMinBackoff = time.Second
+const DefaultMaxBackoff = 10 * time.Minute
+
+type Repo interface {
+	Lock(ctx context.Context) (func(), bool, error)
+	Pending(ctx context.Context, limit int) ([]models.ProductEvent, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, nextAttemptAt *time.Time, lastError string) error
+}
+
+// Relay publishes events of the outbox. Delivery is at least once: an event
+// is marked as published after the publisher succeeds, failed events are
+// retried with exponential backoff until MaxAttempts, then the event is
+// dead. Events of a product are published in order, a failed event holds
+// back later events of the same product until it's published or dead.
+type Relay struct {
+	logger    *zap.Logger
+	repo      Repo
+	publisher Publisher
+
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+
+	now func() time.Time
+}
+
+func NewRelay(logger *zap.Logger, repo Repo, publisher Publisher) *Relay {
+	return &Relay{
+		logger:      logger,
+		repo:        repo,
+		publisher:   publisher,
+		BatchSize:   DefaultBatchSize,
+		MaxAttempts: DefaultMaxAttempts,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		now:         time.Now,
+	}
+}
+
+// Publish publishes a batch of pending events, it is run periodically (see
+// jobs.Every). Only one server instance publishes at a time.
+func (r *Relay) Publish(ctx context.Context) error {
+	unlock, locked, err := r.repo.Lock(ctx)
+	if err != nil || !locked {
+		return err
+	}
+	defer unlock()
+
+	events, err := r.repo.Pending(ctx, r.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	now := r.now()
+	blocked := map[int]bool{}
+	for _, event := range events {
+		if blocked[event.ProductId] {
+			continue
+		}
+		if event.NextAttemptAt.After(now) {
+			blocked[event.ProductId] = true
+			continue
+		}
+
+		err := r.publisher.Publish(ctx, event)
+		if err != nil {
+			attempts := event.Attempts + 1
+			var nextAttemptAt *time.Time
+			if attempts < r.MaxAttempts {
+				next := now.Add(Backoff(attempts, r.MinBackoff, r.MaxBackoff))
+				nextAttemptAt = &next
+				blocked[event.ProductId] = true
+			}
+			r.logger.Sugar().Warnw("publish event failed", "event_id", event.Id, "attempts", attempts,
+				"dead", nextAttemptAt == nil, "error", err)
+			err = r.repo.MarkFailed(ctx, event.Id, nextAttemptAt, err.Error())
+		} else {
+			err = r.repo.MarkPublished(ctx, event.Id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backoff returns the delay before the attempt: min, 2*min, 4*min... up to max
+func Backoff(attempt int, min time.Duration, max time.Duration) time.Duration {
+	delay := min
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
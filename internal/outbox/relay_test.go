@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/outbox/mock_outbox"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_Backoff(t *testing.T) {
+	require.Equal(t, time.Second, Backoff(1, time.Second, time.Minute))
+	require.Equal(t, 2*time.Second, Backoff(2, time.Second, time.Minute))
+	require.Equal(t, 32*time.Second, Backoff(6, time.Second, time.Minute))
+	require.Equal(t, time.Minute, Backoff(7, time.Second, time.Minute))
+	require.Equal(t, time.Minute, Backoff(1000, time.Second, time.Minute))
+}
+
+func Test_Relay_Publish_Case1_Locked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_outbox.NewMockRepo(ctrl)
+	publisher := mock_outbox.NewMockPublisher(ctrl)
+	relay := NewRelay(zaptest.NewLogger(t), repo, publisher)
+
+	repo.EXPECT().Lock(gomock.Any()).Return(nil, false, nil)
+
+	require.Nil(t, relay.Publish(context.Background()))
+}
+
+func Test_Relay_Publish_Case2_OrderPerProduct(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_outbox.NewMockRepo(ctrl)
+	publisher := mock_outbox.NewMockPublisher(ctrl)
+	relay := NewRelay(zaptest.NewLogger(t), repo, publisher)
+	now := time.Date(2021, 7, 26, 10, 0, 0, 0, time.UTC)
+	relay.now = func() time.Time { return now }
+
+	events := []models.ProductEvent{
+		{Id: 1, ProductId: 1, Type: models.ProductEventCreated},
+		{Id: 2, ProductId: 2, Type: models.ProductEventCreated, Attempts: 2},
+		{Id: 3, ProductId: 3, Type: models.ProductEventCreated, NextAttemptAt: now.Add(time.Second)},
+		{Id: 4, ProductId: 1, Type: models.ProductEventUpdated},
+		{Id: 5, ProductId: 2, Type: models.ProductEventUpdated},
+		{Id: 6, ProductId: 3, Type: models.ProductEventUpdated},
+	}
+
+	unlocked := false
+	repo.EXPECT().Lock(gomock.Any()).Return(func() { unlocked = true }, true, nil)
+	repo.EXPECT().Pending(gomock.Any(), DefaultBatchSize).Return(events, nil)
+	nextAttemptAt := now.Add(4 * time.Second)
+	gomock.InOrder(
+		publisher.EXPECT().Publish(gomock.Any(), events[0]).Return(nil),
+		repo.EXPECT().MarkPublished(gomock.Any(), int64(1)).Return(nil),
+		publisher.EXPECT().Publish(gomock.Any(), events[1]).Return(errors.New("connection refused")),
+		repo.EXPECT().MarkFailed(gomock.Any(), int64(2), &nextAttemptAt, "connection refused").Return(nil),
+		publisher.EXPECT().Publish(gomock.Any(), events[3]).Return(nil),
+		repo.EXPECT().MarkPublished(gomock.Any(), int64(4)).Return(nil),
+	)
+
+	require.Nil(t, relay.Publish(context.Background()))
+	require.True(t, unlocked)
+}
+
+func Test_Relay_Publish_Case3_RepoError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_outbox.NewMockRepo(ctrl)
+	publisher := mock_outbox.NewMockPublisher(ctrl)
+	relay := NewRelay(zaptest.NewLogger(t), repo, publisher)
+
+	events := []models.ProductEvent{{Id: 1, ProductId: 1}, {Id: 2, ProductId: 2}}
+	repo.EXPECT().Lock(gomock.Any()).Return(func() {}, true, nil)
+	repo.EXPECT().Pending(gomock.Any(), DefaultBatchSize).Return(events, nil)
+	publisher.EXPECT().Publish(gomock.Any(), events[0]).Return(nil)
+	repo.EXPECT().MarkPublished(gomock.Any(), int64(1)).Return(errors.New("conn closed"))
+
+	require.EqualError(t, relay.Publish(context.Background()), "conn closed")
+}
+
+func Test_Relay_Publish_Case4_Dead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_outbox.NewMockRepo(ctrl)
+	publisher := mock_outbox.NewMockPublisher(ctrl)
+	relay := NewRelay(zaptest.NewLogger(t), repo, publisher)
+
+	// The dead event doesn't hold back later events of the product
+	events := []models.ProductEvent{
+		{Id: 1, ProductId: 1, Type: models.ProductEventCreated, Attempts: DefaultMaxAttempts - 1},
+		{Id: 2, ProductId: 1, Type: models.ProductEventUpdated},
+	}
+	repo.EXPECT().Lock(gomock.Any()).Return(func() {}, true, nil)
+	repo.EXPECT().Pending(gomock.Any(), DefaultBatchSize).Return(events, nil)
+	gomock.InOrder(
+		publisher.EXPECT().Publish(gomock.Any(), events[0]).Return(errors.New("invalid event")),
+		repo.EXPECT().MarkFailed(gomock.Any(), int64(1), nil, "invalid event").Return(nil),
+		publisher.EXPECT().Publish(gomock.Any(), events[1]).Return(nil),
+		repo.EXPECT().MarkPublished(gomock.Any(), int64(2)).Return(nil),
+	)
+
+	require.Nil(t, relay.Publish(context.Background()))
+}
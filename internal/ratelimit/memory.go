@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in memory, so every server instance has its
+// own limits
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from the bucket of the key, a new bucket is full
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updatedAt: now}
+		s.buckets[key] = b
+	}
+	tokens, allowed := limit.Take(b.tokens, now.Sub(b.updatedAt))
+	b.tokens, b.updatedAt = tokens, now
+	return limit.Result(allowed, tokens), nil
+}
+
+// DeleteIdle forgets buckets not used for idle, buckets idle for the period
+// of their limit are full so forgetting them changes nothing
+func (s *MemoryStore) DeleteIdle(_ context.Context, idle time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, b := range s.buckets {
+		if s.now().Sub(b.updatedAt) >= idle {
+			delete(s.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	now := time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Period: time.Minute}
+	ctx := context.Background()
+
+	for _, wantRemaining := range []int{1, 0} {
+		result, err := store.Take(ctx, "a", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, wantRemaining, result.Remaining)
+	}
+
+	result, err := store.Take(ctx, "a", limit)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 30*time.Second, result.RetryAfter)
+
+	result, err = store.Take(ctx, "b", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	now = now.Add(30 * time.Second)
+	result, err = store.Take(ctx, "a", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	deleted, err := store.DeleteIdle(ctx, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), deleted)
+
+	now = now.Add(time.Minute)
+	deleted, err = store.DeleteIdle(ctx, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+	require.Empty(t, store.buckets)
+}
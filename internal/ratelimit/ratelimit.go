@@ -0,0 +1,84 @@
+// Package ratelimit implements token buckets: a bucket holds up to
+// Limit.Requests tokens, a request takes one and the bucket is refilled
+// evenly over Limit.Period. Stores keep buckets by key (see MemoryStore and
+// repos.RateLimitRepo).
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows bursts of Requests and Requests per Period on average
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses `<requests>/<period>`, e.g. `60/1m`
+func ParseLimit(raw string) (Limit, error) {
+	parts := strings.SplitN(raw, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("%q is not <requests>/<period>", raw)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("requests of %q must be a positive integer", raw)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("period of %q must be a positive duration", raw)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Period.String()
+}
+
+// Rate is the number of tokens refilled per second
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Take refills the bucket for the elapsed time and takes a token if there
+// is one, it returns tokens left
+func (l Limit) Take(tokens float64, elapsed time.Duration) (float64, bool) {
+	tokens = math.Min(float64(l.Requests), tokens+math.Max(elapsed.Seconds(), 0)*l.Rate())
+	if tokens < 1 {
+		return tokens, false
+	}
+	return tokens - 1, true
+}
+
+// Result of a request, Reset is the time until the bucket is full and
+// RetryAfter until the next token of a rejected request
+type Result struct {
+	Limit      Limit
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Result describes the bucket with tokens left after the request
+func (l Limit) Result(allowed bool, tokens float64) Result {
+	result := Result{
+		Limit:     l,
+		Allowed:   allowed,
+		Remaining: int(tokens),
+		Reset:     l.refill(float64(l.Requests) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = l.refill(1 - tokens)
+	}
+	return result
+}
+
+// refill returns the time to refill the tokens
+func (l Limit) refill(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate() * float64(time.Second))
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/1m")
+	require.NoError(t, err)
+	require.Equal(t, Limit{Requests: 60, Period: time.Minute}, limit)
+	require.Equal(t, "60/1m0s", limit.String())
+	require.Equal(t, 1.0, limit.Rate())
+
+	for _, invalid := range []string{"", "60", "60/", "/1m", "0/1m", "-1/1m", "a/1m", "60/0s", "60/m"} {
+		_, err := ParseLimit(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func Test_Limit_Take(t *testing.T) {
+	limit := Limit{Requests: 2, Period: 2 * time.Second}
+
+	testCases := []struct {
+		name        string
+		tokens      float64
+		elapsed     time.Duration
+		wantTokens  float64
+		wantAllowed bool
+	}{
+		{name: "full", tokens: 2, wantTokens: 1, wantAllowed: true},
+		{name: "last", tokens: 1, wantTokens: 0, wantAllowed: true},
+		{name: "empty", tokens: 0.5, wantTokens: 0.5, wantAllowed: false},
+		{name: "refilled", tokens: 0.5, elapsed: 500 * time.Millisecond, wantTokens: 0, wantAllowed: true},
+		{name: "refilled up to burst", tokens: 0, elapsed: time.Hour, wantTokens: 1, wantAllowed: true},
+		{name: "clock skew", tokens: 0, elapsed: -time.Hour, wantTokens: 0, wantAllowed: false},
+	}
+
+	for _, tc := range testCases {
+		tokens, allowed := limit.Take(tc.tokens, tc.elapsed)
+		require.Equal(t, tc.wantTokens, tokens, tc.name)
+		require.Equal(t, tc.wantAllowed, allowed, tc.name)
+	}
+}
+
+func Test_Limit_Result(t *testing.T) {
+	limit := Limit{Requests: 10, Period: 10 * time.Second}
+
+	require.Equal(t, Result{Limit: limit, Allowed: true, Remaining: 7, Reset: 2500 * time.Millisecond},
+		limit.Result(true, 7.5))
+	require.Equal(t, Result{Limit: limit, Allowed: false, Remaining: 0, Reset: 9750 * time.Millisecond, RetryAfter: 750 * time.Millisecond},
+		limit.Result(false, 0.25))
+}
@@ -0,0 +1,101 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+)
+
+// APIKeyTouchInterval limits writes of last_used_at of busy keys
+const APIKeyTouchInterval = time.Minute
+
+// Hash is never read back
+const apiKeyColumns = `id, name, prefix, scopes, roles, tenant_id, created_at, rotated_at, last_used_at, revoked_at`
+
+type APIKeyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepo(db *pgxpool.Pool) *APIKeyRepo {
+	return &APIKeyRepo{
+		db: db,
+	}
+}
+
+func (s *APIKeyRepo) All(ctx context.Context, list *query.List) (*[]models.APIKey, int, error) {
+	var total int
+	err := pgxscan.Get(ctx, s.db, &total, `SELECT count(*) FROM api_keys`)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	builder := newSQLBuilder(nil)
+	keys := []models.APIKey{}
+	sql := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id` + builder.limit(list.Limit, list.Offset)
+	err = pgxscan.Select(ctx, s.db, &keys, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return &keys, total, nil
+}
+
+func (s *APIKeyRepo) Find(ctx context.Context, id int) (*models.APIKey, error) {
+	var key models.APIKey
+	sql := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	err := pgxscan.Get(ctx, s.db, &key, sql, id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+// Create saves the key with the hash of models.APIKey.Generate
+func (s *APIKeyRepo) Create(ctx context.Context, key *models.APIKey, hash string) error {
+	sql := `INSERT INTO api_keys (name, prefix, key_hash, scopes, roles, tenant_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := s.db.QueryRow(ctx, sql, key.Name, key.Prefix, hash, key.Scopes, key.Roles, key.TenantId).Scan(&key.Id, &key.CreatedAt)
+	return translateError(err)
+}
+
+// Rotate replaces the key of the active key by key.Prefix and hash, the old
+// key stops working right away. Revoked keys are not found.
+func (s *APIKeyRepo) Rotate(ctx context.Context, key *models.APIKey, hash string) error {
+	sql := `UPDATE api_keys SET prefix = $2, key_hash = $3, rotated_at = now()
+		WHERE id = $1 AND revoked_at IS NULL RETURNING ` + apiKeyColumns
+	err := pgxscan.Get(ctx, s.db, key, sql, key.Id, key.Prefix, hash)
+	return translateError(err)
+}
+
+// Revoke disables the key, revoking twice keeps the first time
+func (s *APIKeyRepo) Revoke(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`, id)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return &Error{ErrNotFound, pgx.ErrNoRows}
+	}
+	return nil
+}
+
+// Authenticate finds the active key by hash and saves the time it's used
+// (at most once per APIKeyTouchInterval), unknown keys are not found
+func (s *APIKeyRepo) Authenticate(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	sql := `WITH key AS (
+			SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE api_keys SET last_used_at = now()
+			WHERE id IN (SELECT id FROM key) AND (last_used_at IS NULL OR last_used_at < now() - $2 * interval '1 second')
+		)
+		SELECT ` + apiKeyColumns + ` FROM key`
+	err := pgxscan.Get(ctx, s.db, &key, sql, hash, APIKeyTouchInterval.Seconds())
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
@@ -0,0 +1,93 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewAPIKeyRepo(t *testing.T) {
+	db := &pgxpool.Pool{}
+	repo := NewAPIKeyRepo(db)
+
+	require.Equal(t, db, repo.db)
+}
+
+func Test_APIKeyRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewAPIKeyRepo(db)
+	ctx := context.Background()
+
+	// Create
+	tenant := "acme"
+	key := &models.APIKey{Name: "CI", Scopes: []string{models.ScopeProductsRead}, Roles: []string{"viewer"}, TenantId: &tenant}
+	hash, err := key.Generate()
+	require.Nil(t, err)
+	require.Nil(t, repo.Create(ctx, key, hash))
+	require.NotZero(t, key.Id)
+
+	found, err := repo.Find(ctx, key.Id)
+	require.Nil(t, err)
+	require.Equal(t, "", found.Key)
+	require.Equal(t, key.Prefix, found.Prefix)
+	require.Equal(t, key.Scopes, found.Scopes)
+	require.Equal(t, key.Roles, found.Roles)
+	require.Equal(t, key.TenantId, found.TenantId)
+	require.Nil(t, found.LastUsedAt)
+
+	keys, total, err := repo.All(ctx, &query.List{Limit: 10})
+	require.Nil(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, key.Id, (*keys)[0].Id)
+
+	// Authenticate saves the time of use
+	authenticated, err := repo.Authenticate(ctx, hash)
+	require.Nil(t, err)
+	require.Equal(t, key.Id, authenticated.Id)
+	found, err = repo.Find(ctx, key.Id)
+	require.Nil(t, err)
+	require.NotNil(t, found.LastUsedAt)
+
+	_, err = repo.Authenticate(ctx, models.HashAPIKey("unknown"))
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	// Rotate replaces the key
+	rotated := &models.APIKey{Id: key.Id}
+	newHash, err := rotated.Generate()
+	require.Nil(t, err)
+	require.Nil(t, repo.Rotate(ctx, rotated, newHash))
+	require.Equal(t, "CI", rotated.Name)
+	require.NotNil(t, rotated.RotatedAt)
+	_, err = repo.Authenticate(ctx, hash)
+	require.True(t, errors.Is(err, ErrNotFound))
+	_, err = repo.Authenticate(ctx, newHash)
+	require.Nil(t, err)
+
+	// Revoke disables the key
+	require.Nil(t, repo.Revoke(ctx, key.Id))
+	require.Nil(t, repo.Revoke(ctx, key.Id))
+	_, err = repo.Authenticate(ctx, newHash)
+	require.True(t, errors.Is(err, ErrNotFound))
+	err = repo.Rotate(ctx, &models.APIKey{Id: key.Id}, hash)
+	require.True(t, errors.Is(err, ErrNotFound))
+	found, err = repo.Find(ctx, key.Id)
+	require.Nil(t, err)
+	require.NotNil(t, found.RevokedAt)
+
+	err = repo.Revoke(ctx, key.Id+1)
+	require.True(t, errors.Is(err, ErrNotFound))
+	_, err = repo.Find(ctx, key.Id+1)
+	require.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,96 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/puddle"
+)
+
+// Domain errors returned by repos, check them with errors.Is
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("unavailable")
+	ErrStale       = errors.New("stale version")
+	ErrTimeout     = errors.New("timeout")
+	ErrCanceled    = errors.New("canceled")
+)
+
+var errVersionMismatch = errors.New("version mismatch")
+
+// Error keeps the original driver error while matching its kind
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// translateError maps pgx/pgconn errors to domain errors, unknown errors are
+// returned as is
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var repoErr *Error
+	if errors.As(err, &repoErr) {
+		return err
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{ErrNotFound, err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		// unique_violation, foreign_key_violation, exclusion_violation
+		case pgErr.Code == "23505" || pgErr.Code == "23503" || pgErr.Code == "23P01":
+			return &Error{ErrConflict, err}
+		// serialization_failure, deadlock_detected
+		case pgErr.Code == "40001" || pgErr.Code == "40P01":
+			return &Error{ErrConflict, err}
+		// data_exception, not_null_violation, check_violation
+		case strings.HasPrefix(pgErr.Code, "22") || pgErr.Code == "23502" || pgErr.Code == "23514":
+			return &Error{ErrValidation, err}
+		// query_canceled (statement_timeout or cancel request on deadline)
+		case pgErr.Code == "57014":
+			return &Error{ErrTimeout, err}
+		// connection_exception, insufficient_resources, operator_intervention
+		case strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "53") || strings.HasPrefix(pgErr.Code, "57"):
+			return &Error{ErrUnavailable, err}
+		}
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return &Error{ErrCanceled, err}
+	}
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return &Error{ErrTimeout, err}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, puddle.ErrClosedPool) ||
+		errors.As(err, &netErr) ||
+		pgconn.SafeToRetry(err) {
+		return &Error{ErrUnavailable, err}
+	}
+
+	return err
+}
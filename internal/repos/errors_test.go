@@ -0,0 +1,72 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/puddle"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Error(t *testing.T) {
+	err := &Error{ErrNotFound, pgx.ErrNoRows}
+
+	require.Equal(t, "not found: no rows in result set", err.Error())
+	require.True(t, errors.Is(err, ErrNotFound))
+	require.True(t, errors.Is(err, pgx.ErrNoRows))
+	require.False(t, errors.Is(err, ErrConflict))
+}
+
+func Test_translateError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		wantKind error
+	}{
+		{name: "no rows", err: fmt.Errorf("scan: %w", pgx.ErrNoRows), wantKind: ErrNotFound},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, wantKind: ErrConflict},
+		{name: "foreign key violation", err: &pgconn.PgError{Code: "23503"}, wantKind: ErrConflict},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, wantKind: ErrConflict},
+		{name: "string too long", err: &pgconn.PgError{Code: "22001"}, wantKind: ErrValidation},
+		{name: "not null violation", err: &pgconn.PgError{Code: "23502"}, wantKind: ErrValidation},
+		{name: "check violation", err: &pgconn.PgError{Code: "23514"}, wantKind: ErrValidation},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, wantKind: ErrUnavailable},
+		{name: "too many connections", err: &pgconn.PgError{Code: "53300"}, wantKind: ErrUnavailable},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, wantKind: ErrUnavailable},
+		{name: "query canceled", err: &pgconn.PgError{Code: "57014"}, wantKind: ErrTimeout},
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), wantKind: ErrTimeout},
+		{name: "canceled", err: context.Canceled, wantKind: ErrCanceled},
+		{name: "closed pool", err: puddle.ErrClosedPool, wantKind: ErrUnavailable},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, wantKind: ErrUnavailable},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := translateError(tc.err)
+
+			require.True(t, errors.Is(got, tc.wantKind), got)
+			require.True(t, errors.Is(got, tc.err))
+		})
+	}
+}
+
+func Test_translateError_Unknown(t *testing.T) {
+	require.Nil(t, translateError(nil))
+
+	err := errors.New("some error...")
+	require.Equal(t, err, translateError(err))
+
+	pgErr := &pgconn.PgError{Code: "42601"}
+	require.Equal(t, pgErr, translateError(pgErr))
+
+	repoErr := &Error{ErrConflict, err}
+	require.Equal(t, repoErr, translateError(repoErr))
+}
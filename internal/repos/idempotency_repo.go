@@ -0,0 +1,85 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+)
+
+const idempotencyColumns = `key, fingerprint, status, headers, body, expires_at`
+
+type IdempotencyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewIdempotencyRepo(db *pgxpool.Pool) *IdempotencyRepo {
+	return &IdempotencyRepo{
+		db: db,
+	}
+}
+
+// Begin reserves the key for a new request until the lease ends (expired
+// keys and keys of crashed requests are reused). If the key is already taken
+// it returns the stored key and false. Keys are unique per tenant and actor
+// of the request.
+func (s *IdempotencyRepo) Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration, lease time.Duration) (*models.IdempotencyKey, bool, error) {
+	actor := requestctx.Actor(ctx)
+	sql := `INSERT INTO idempotency_keys (actor, key, fingerprint, expires_at, locked_until)
+		VALUES ($1, $2, $3, now() + $4 * interval '1 second', now() + $5 * interval '1 second')
+		ON CONFLICT (tenant_id, actor, key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = NULL,
+			headers = NULL, body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at,
+			locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.expires_at <= now()
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= now())
+		RETURNING key`
+	var reserved string
+	err := s.db.QueryRow(ctx, sql, actor, key, fingerprint, ttl.Seconds(), lease.Seconds()).Scan(&reserved)
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, translateError(err)
+	}
+
+	var existing models.IdempotencyKey
+	sql = `SELECT ` + idempotencyColumns + ` FROM idempotency_keys
+		WHERE tenant_id = ` + currentTenant + ` AND actor = $1 AND key = $2`
+	err = pgxscan.Get(ctx, s.db, &existing, sql, actor, key)
+	if err != nil {
+		return nil, false, translateError(err)
+	}
+	return &existing, false, nil
+}
+
+// Complete stores the response of the request
+func (s *IdempotencyRepo) Complete(ctx context.Context, key string, status int, headers http.Header, body []byte) error {
+	sql := `UPDATE idempotency_keys SET status = $3, headers = $4, body = $5, locked_until = NULL
+		WHERE tenant_id = ` + currentTenant + ` AND actor = $1 AND key = $2`
+	_, err := s.db.Exec(ctx, sql, requestctx.Actor(ctx), key, status, headers, body)
+	return translateError(err)
+}
+
+// Release frees the key of a failed request so it can be retried
+func (s *IdempotencyRepo) Release(ctx context.Context, key string) error {
+	sql := `DELETE FROM idempotency_keys
+		WHERE tenant_id = ` + currentTenant + ` AND actor = $1 AND key = $2 AND status IS NULL`
+	_, err := s.db.Exec(ctx, sql, requestctx.Actor(ctx), key)
+	return translateError(err)
+}
+
+// DeleteExpired removes expired keys, it returns the number of deleted keys
+func (s *IdempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	sql := `DELETE FROM idempotency_keys WHERE expires_at <= now()`
+	tag, err := s.db.Exec(ctx, sql)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return tag.RowsAffected(), nil
+}
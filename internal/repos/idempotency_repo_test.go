@@ -0,0 +1,144 @@
+package repos
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewIdempotencyRepo(t *testing.T) {
+	db := &pgxpool.Pool{}
+	repo := NewIdempotencyRepo(db)
+
+	require.Equal(t, db, repo.db)
+}
+
+func Test_IdempotencyRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	repo := NewIdempotencyRepo(db)
+	ctx := context.Background()
+
+	t.Run("Begin, complete and replay", func(t *testing.T) {
+		defer test.Truncate()
+
+		existing, reserved, err := repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+		require.Nil(t, existing)
+
+		// In flight
+		existing, reserved, err = repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.False(t, reserved)
+		require.Nil(t, existing.Status)
+
+		headers := http.Header{"Content-Type": {"application/json"}}
+		err = repo.Complete(ctx, "key-1", http.StatusCreated, headers, []byte(`{"id":1}`))
+		require.Nil(t, err)
+
+		existing, reserved, err = repo.Begin(ctx, "key-1", "fingerprint-2", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.False(t, reserved)
+		require.Equal(t, "fingerprint-1", existing.Fingerprint)
+		require.Equal(t, http.StatusCreated, *existing.Status)
+		require.Equal(t, headers, existing.Headers)
+		require.Equal(t, []byte(`{"id":1}`), existing.Body)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		defer test.Truncate()
+
+		_, reserved, err := repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+
+		err = repo.Release(ctx, "key-1")
+		require.Nil(t, err)
+
+		_, reserved, err = repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		defer test.Truncate()
+
+		_, reserved, err := repo.Begin(ctx, "key-1", "fingerprint-1", -time.Second, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+		_, reserved, err = repo.Begin(ctx, "key-2", "fingerprint-2", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+
+		// Expired key is reused
+		_, reserved, err = repo.Begin(ctx, "key-1", "fingerprint-3", -time.Second, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+
+		deleted, err := repo.DeleteExpired(ctx)
+		require.Nil(t, err)
+		require.Equal(t, int64(1), deleted)
+	})
+
+	t.Run("Lease", func(t *testing.T) {
+		defer test.Truncate()
+
+		_, reserved, err := repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, -time.Second)
+		require.Nil(t, err)
+		require.True(t, reserved)
+
+		// The key of a crashed request is taken over after the lease
+		_, reserved, err = repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+		existing, reserved, err := repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.False(t, reserved)
+		require.Nil(t, existing.Status)
+
+		// Completed keys are kept after the lease
+		err = repo.Complete(ctx, "key-1", http.StatusCreated, http.Header{}, []byte(`{}`))
+		require.Nil(t, err)
+		_, err = db.Exec(ctx, `UPDATE idempotency_keys SET locked_until = now() - interval '1 second'`)
+		require.Nil(t, err)
+		existing, reserved, err = repo.Begin(ctx, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.False(t, reserved)
+		require.Equal(t, http.StatusCreated, *existing.Status)
+	})
+
+	t.Run("Actors", func(t *testing.T) {
+		defer test.Truncate()
+
+		alice := requestctx.WithActor(ctx, "api_key:1")
+		bob := requestctx.WithActor(ctx, "api_key:2")
+
+		_, reserved, err := repo.Begin(alice, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+		err = repo.Complete(alice, "key-1", http.StatusCreated, http.Header{}, []byte(`{"id":1}`))
+		require.Nil(t, err)
+
+		// Actors don't share keys
+		_, reserved, err = repo.Begin(bob, "key-1", "fingerprint-2", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.True(t, reserved)
+		err = repo.Release(bob, "key-1")
+		require.Nil(t, err)
+
+		existing, reserved, err := repo.Begin(alice, "key-1", "fingerprint-1", time.Hour, time.Minute)
+		require.Nil(t, err)
+		require.False(t, reserved)
+		require.Equal(t, []byte(`{"id":1}`), existing.Body)
+	})
+}
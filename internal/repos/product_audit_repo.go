@@ -0,0 +1,100 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/query"
+)
+
+const productAuditColumns = `id, product_id, action, actor, request_id, changes, created_at`
+
+type ProductAuditRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewProductAuditRepo(db *pgxpool.Pool) *ProductAuditRepo {
+	return &ProductAuditRepo{
+		db: db,
+	}
+}
+
+// History returns changes of the product, newest first
+func (s *ProductAuditRepo) History(ctx context.Context, productId int, list *query.List) (*[]models.ProductAudit, int, error) {
+	builder := newSQLBuilder(nil)
+	builder.condition(`product_id = ` + builder.arg(productId))
+	return s.audits(ctx, builder, list)
+}
+
+// Changes returns changes of all products made in [from, to), newest first
+func (s *ProductAuditRepo) Changes(ctx context.Context, from time.Time, to time.Time, list *query.List) (*[]models.ProductAudit, int, error) {
+	builder := newSQLBuilder(nil)
+	builder.condition(`created_at >= ` + builder.arg(from))
+	builder.condition(`created_at < ` + builder.arg(to))
+	return s.audits(ctx, builder, list)
+}
+
+func (s *ProductAuditRepo) audits(ctx context.Context, builder *sqlBuilder, list *query.List) (*[]models.ProductAudit, int, error) {
+	where := builder.where()
+
+	var total int
+	sql := `SELECT count(*) FROM product_audits` + where
+	err := pgxscan.Get(ctx, s.db, &total, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	audits := []models.ProductAudit{}
+	sql = `SELECT ` + productAuditColumns + ` FROM product_audits` + where +
+		` ORDER BY id DESC` + builder.limit(list.Limit, list.Offset)
+	err = pgxscan.Select(ctx, s.db, &audits, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return &audits, total, nil
+}
+
+// newProductAudit builds the audit of the change made by the actor of the
+// request
+func newProductAudit(ctx context.Context, action string, productId int, before *models.Product, after *models.Product) models.ProductAudit {
+	return models.ProductAudit{
+		ProductId: productId,
+		Action:    action,
+		Actor:     requestctx.Actor(ctx),
+		RequestId: requestctx.RequestID(ctx),
+		Changes:   models.ProductChanges(before, after),
+	}
+}
+
+// insertProductAudits saves audits in one statement, so it runs in the
+// transaction of the change
+func insertProductAudits(ctx context.Context, q querier, audits ...models.ProductAudit) error {
+	if len(audits) == 0 {
+		return nil
+	}
+
+	n := len(audits)
+	productIds, actions, actors, requestIds, changes := make([]int, n), make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	tenantIds := make([]string, n)
+	for i, audit := range audits {
+		data, err := json.Marshal(audit.Changes)
+		if err != nil {
+			return err
+		}
+		productIds[i], actions[i], actors[i], requestIds[i], changes[i] = audit.ProductId, audit.Action, audit.Actor, audit.RequestId, string(data)
+		tenantIds[i] = audit.TenantId
+	}
+
+	// Jobs run without tenant, so they pass the tenant of the product
+	sql := `INSERT INTO product_audits (product_id, action, actor, request_id, changes, tenant_id)
+		SELECT product_id, action, actor, request_id, changes::jsonb, COALESCE(NULLIF(tenant_id, ''), ` + currentTenant + `)
+		FROM unnest($1::integer[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[])
+			AS t (product_id, action, actor, request_id, changes, tenant_id)`
+	_, err := q.Exec(ctx, sql, productIds, actions, actors, requestIds, changes, tenantIds)
+	return translateError(err)
+}
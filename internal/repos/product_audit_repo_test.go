@@ -0,0 +1,97 @@
+package repos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewProductAuditRepo(t *testing.T) {
+	db := &pgxpool.Pool{}
+	repo := NewProductAuditRepo(db)
+
+	require.Equal(t, db, repo.db)
+}
+
+func auditActions(audits []models.ProductAudit) []string {
+	actions := []string{}
+	for _, audit := range audits {
+		actions = append(actions, audit.Action)
+	}
+	return actions
+}
+
+func Test_ProductAuditRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	productRepo := NewProductRepo(db)
+	repo := NewProductAuditRepo(db)
+	ctx := requestctx.WithRequestID(requestctx.WithActor(context.Background(), "alice"), "request-1")
+	from := time.Now().Add(-time.Minute)
+
+	// Every write is audited
+	product := &models.Product{Name: "Test 1", Price: 1}
+	require.Nil(t, productRepo.Create(ctx, product))
+	product.Price = 2
+	require.Nil(t, productRepo.Update(ctx, product))
+	require.Nil(t, productRepo.Destroy(ctx, product.Id, product.Version))
+	_, err := productRepo.Restore(ctx, product.Id)
+	require.Nil(t, err)
+	_, err = productRepo.BatchAtomic(ctx, []models.ProductOperation{
+		{Op: models.ProductOpCreate, Product: models.Product{Name: "Test 2", Price: 2}},
+		{Op: models.ProductOpDelete, Product: models.Product{Id: product.Id}},
+	})
+	require.Nil(t, err)
+	require.Nil(t, productRepo.Purge(ctx, product.Id))
+
+	// Failed writes are not audited
+	err = productRepo.Update(ctx, &models.Product{Id: product.Id, Name: "Test 1", Price: 3})
+	require.NotNil(t, err)
+
+	audits, total, err := repo.History(ctx, product.Id, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 6, total)
+	require.Equal(t, []string{
+		models.ProductAuditPurge,
+		models.ProductAuditDelete,
+		models.ProductAuditRestore,
+		models.ProductAuditDelete,
+		models.ProductAuditUpdate,
+		models.ProductAuditCreate,
+	}, auditActions(*audits))
+
+	update := (*audits)[4]
+	require.Equal(t, product.Id, update.ProductId)
+	require.Equal(t, "alice", update.Actor)
+	require.Equal(t, "request-1", update.RequestId)
+	require.Equal(t, map[string]models.FieldChange{"price": {Before: 1.0, After: 2.0}}, update.Changes)
+	require.WithinDuration(t, time.Now(), update.CreatedAt, time.Minute)
+
+	create := (*audits)[5]
+	require.Equal(t, map[string]models.FieldChange{
+		"name":  {Before: nil, After: "Test 1"},
+		"price": {Before: nil, After: 1.0},
+	}, create.Changes)
+
+	// Changes of the whole catalogue in the window
+	audits, total, err = repo.Changes(ctx, from, time.Now().Add(time.Minute), allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 7, total)
+	require.Equal(t, models.ProductAuditCreate, (*audits)[2].Action)
+	require.NotEqual(t, product.Id, (*audits)[2].ProductId)
+
+	_, total, err = repo.Changes(ctx, from.Add(-time.Hour), from, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 0, total)
+}
@@ -0,0 +1,229 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/query"
+)
+
+const productEventColumns = `id, type, product_id, version, data, changes, request_id, created_at, tenant_id, attempts, next_attempt_at`
+
+// relayLockKey is the advisory lock of the outbox relay
+const relayLockKey = 7265001
+
+// productEventsChannel is notified of inserted events by a trigger
+const productEventsChannel = "product_events"
+
+type ProductEventRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewProductEventRepo(db *pgxpool.Pool) *ProductEventRepo {
+	return &ProductEventRepo{
+		db: db,
+	}
+}
+
+// Lock takes the relay lock, so only one server instance publishes events
+// and the order of events is kept. If the lock is taken it returns false,
+// otherwise unlock must be called.
+func (s *ProductEventRepo) Lock(ctx context.Context) (func(), bool, error) {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return nil, false, translateError(err)
+	}
+
+	var locked bool
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, relayLockKey).Scan(&locked)
+	if err != nil || !locked {
+		conn.Release()
+		return nil, false, translateError(err)
+	}
+
+	unlock := func() {
+		// The session lock is released with the connection if unlock fails
+		_, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, relayLockKey)
+		if err != nil {
+			conn.Conn().Close(context.Background()) //nolint:errcheck
+		}
+		conn.Release()
+	}
+	return unlock, true, nil
+}
+
+// Listen calls handle with events committed by any server instance, it
+// holds a pool connection until ctx is done or the connection fails
+func (s *ProductEventRepo) Listen(ctx context.Context, handle func(event models.ProductEvent)) error {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	defer func() {
+		// The connection is closed if it can't stop listening
+		_, err := conn.Exec(context.Background(), `UNLISTEN *`)
+		if err != nil {
+			conn.Conn().Close(context.Background()) //nolint:errcheck
+		}
+		conn.Release()
+	}()
+
+	_, err = conn.Exec(ctx, `LISTEN `+productEventsChannel)
+	if err != nil {
+		return translateError(err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return translateError(err)
+		}
+
+		// TenantId isn't a JSON field of the event
+		var payload struct {
+			models.ProductEvent
+			TenantId string `json:"tenant_id"`
+		}
+		err = json.Unmarshal([]byte(notification.Payload), &payload)
+		if err != nil {
+			return err
+		}
+		payload.ProductEvent.TenantId = payload.TenantId
+		handle(payload.ProductEvent)
+	}
+}
+
+// Pending returns due events in order of creation. Events of a product
+// with an earlier event waiting for retry are skipped, so blocked products
+// don't fill the batch and hold back other products. Dead events are
+// skipped and don't block.
+func (s *ProductEventRepo) Pending(ctx context.Context, limit int) ([]models.ProductEvent, error) {
+	events := []models.ProductEvent{}
+	sql := `SELECT ` + productEventColumns + ` FROM product_events e
+		WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= now()
+			AND NOT EXISTS (
+				SELECT FROM product_events b
+				WHERE b.product_id = e.product_id AND b.id < e.id
+					AND b.published_at IS NULL AND b.dead_at IS NULL AND b.next_attempt_at > now()
+			)
+		ORDER BY id LIMIT $1`
+	err := pgxscan.Select(ctx, s.db, &events, sql, limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return events, nil
+}
+
+func (s *ProductEventRepo) MarkPublished(ctx context.Context, id int64) error {
+	sql := `UPDATE product_events SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`
+	_, err := s.db.Exec(ctx, sql, id)
+	return translateError(err)
+}
+
+// MarkFailed schedules the next attempt to publish the event, or marks it
+// dead if nextAttemptAt is nil
+func (s *ProductEventRepo) MarkFailed(ctx context.Context, id int64, nextAttemptAt *time.Time, lastError string) error {
+	sql := `UPDATE product_events SET attempts = attempts + 1, next_attempt_at = COALESCE($2, next_attempt_at),
+		dead_at = CASE WHEN $2::timestamptz IS NULL THEN now() END, last_error = $3 WHERE id = $1`
+	_, err := s.db.Exec(ctx, sql, id, nextAttemptAt, lastError)
+	return translateError(err)
+}
+
+// Dead returns events dead after MaxAttempts, oldest first
+func (s *ProductEventRepo) Dead(ctx context.Context, list *query.List) (*[]models.DeadProductEvent, int, error) {
+	var total int
+	err := pgxscan.Get(ctx, s.db, &total, `SELECT count(*) FROM product_events WHERE dead_at IS NOT NULL`)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	builder := newSQLBuilder(nil)
+	events := []models.DeadProductEvent{}
+	sql := `SELECT ` + productEventColumns + `, last_error, dead_at FROM product_events
+		WHERE dead_at IS NOT NULL ORDER BY id` + builder.limit(list.Limit, list.Offset)
+	err = pgxscan.Select(ctx, s.db, &events, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return &events, total, nil
+}
+
+// Redeliver schedules the dead event to be published again right away with
+// all attempts, e.g. after the publisher is fixed
+func (s *ProductEventRepo) Redeliver(ctx context.Context, id int64) (*models.ProductEvent, error) {
+	var event models.ProductEvent
+	sql := `UPDATE product_events SET dead_at = NULL, attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND dead_at IS NOT NULL RETURNING ` + productEventColumns
+	err := pgxscan.Get(ctx, s.db, &event, sql, id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &event, nil
+}
+
+// DeletePublished removes events published before the retention window, it
+// returns the number of deleted events
+func (s *ProductEventRepo) DeletePublished(ctx context.Context, retention time.Duration) (int64, error) {
+	sql := `DELETE FROM product_events WHERE published_at < now() - $1 * interval '1 second'`
+	tag, err := s.db.Exec(ctx, sql, retention.Seconds())
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// newProductEvent builds the event of the product after the change
+func newProductEvent(ctx context.Context, eventType string, product *models.Product) *models.ProductEvent {
+	data := *product
+	return &models.ProductEvent{
+		Type:      eventType,
+		ProductId: product.Id,
+		Version:   product.Version,
+		Data:      &data,
+		RequestId: requestctx.RequestID(ctx),
+	}
+}
+
+// insertProductEvents saves events to the outbox in one statement, so it
+// runs in the transaction of the change
+func insertProductEvents(ctx context.Context, q querier, events ...models.ProductEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	n := len(events)
+	types, productIds, versions, requestIds := make([]string, n), make([]int, n), make([]int, n), make([]string, n)
+	data, changes := make([]*string, n), make([]*string, n)
+	for i, event := range events {
+		if event.Data != nil {
+			raw, err := json.Marshal(event.Data)
+			if err != nil {
+				return err
+			}
+			value := string(raw)
+			data[i] = &value
+		}
+		if event.Changes != nil {
+			raw, err := json.Marshal(event.Changes)
+			if err != nil {
+				return err
+			}
+			value := string(raw)
+			changes[i] = &value
+		}
+		types[i], productIds[i], versions[i], requestIds[i] = event.Type, event.ProductId, event.Version, event.RequestId
+	}
+
+	sql := `INSERT INTO product_events (type, product_id, version, data, changes, request_id)
+		SELECT type, product_id, version, data::jsonb, changes::jsonb, request_id
+		FROM unnest($1::text[], $2::integer[], $3::integer[], $4::text[], $5::text[], $6::text[]) WITH ORDINALITY
+			AS t (type, product_id, version, data, changes, request_id, n)
+		ORDER BY n`
+	_, err := q.Exec(ctx, sql, types, productIds, versions, data, changes, requestIds)
+	return translateError(err)
+}
@@ -0,0 +1,184 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewProductEventRepo(t *testing.T) {
+	db := &pgxpool.Pool{}
+	repo := NewProductEventRepo(db)
+
+	require.Equal(t, db, repo.db)
+}
+
+func Test_ProductEventRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	productRepo := NewProductRepo(db)
+	repo := NewProductEventRepo(db)
+	ctx := requestctx.WithRequestID(context.Background(), "request-1")
+
+	// Writes save events in the same transaction
+	product := &models.Product{Name: "Test 1", Price: 1}
+	require.Nil(t, productRepo.Create(ctx, product))
+	product.Price = 2
+	require.Nil(t, productRepo.Update(ctx, product))
+	require.Nil(t, productRepo.Destroy(ctx, product.Id, product.Version))
+	_, err := productRepo.BatchAtomic(ctx, []models.ProductOperation{
+		{Op: models.ProductOpCreate, Product: models.Product{Name: "Test 2", Price: 2}},
+		{Op: models.ProductOpUpdate, Product: models.Product{Id: product.Id, Name: "Test 1", Price: 3}},
+	})
+	require.NotNil(t, err)
+
+	events, err := repo.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(events))
+
+	created, updated, deleted := events[0], events[1], events[2]
+	require.Equal(t, models.ProductEventCreated, created.Type)
+	require.Equal(t, requestctx.DefaultTenant, created.TenantId)
+	require.Equal(t, &models.Product{Id: product.Id, Name: "Test 1", Price: 1, Version: 1}, created.Data)
+	require.Equal(t, models.ProductEventUpdated, updated.Type)
+	require.Equal(t, 2, updated.Version)
+	require.Equal(t, &models.Product{Id: product.Id, Name: "Test 1", Price: 2, Version: 2}, updated.Data)
+	require.Equal(t, map[string]models.FieldChange{"price": {Before: 1.0, After: 2.0}}, updated.Changes)
+	require.Equal(t, models.ProductEventDeleted, deleted.Type)
+	require.Equal(t, product.Id, deleted.ProductId)
+	require.Equal(t, 3, deleted.Version)
+	require.Nil(t, deleted.Data)
+	require.Equal(t, "request-1", deleted.RequestId)
+
+	// Delivery state
+	nextAttemptAt := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	require.Nil(t, repo.MarkPublished(ctx, created.Id))
+	require.Nil(t, repo.MarkFailed(ctx, updated.Id, &nextAttemptAt, "connection refused"))
+
+	events, err = repo.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int64{updated.Id, deleted.Id}, []int64{events[0].Id, events[1].Id})
+	require.Equal(t, 1, events[0].Attempts)
+	require.True(t, nextAttemptAt.Equal(events[0].NextAttemptAt))
+
+	// A failed event holds back later events of the product until it's due
+	nextAttemptAt = time.Now().Add(time.Minute)
+	require.Nil(t, repo.MarkFailed(ctx, updated.Id, &nextAttemptAt, "connection refused"))
+
+	events, err = repo.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(events))
+
+	// A dead event is skipped and doesn't hold back later events
+	require.Nil(t, repo.MarkFailed(ctx, updated.Id, nil, "invalid event"))
+
+	events, err = repo.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, deleted.Id, events[0].Id)
+
+	dead, total, err := repo.Dead(ctx, &query.List{Limit: 10})
+	require.Nil(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, updated.Id, (*dead)[0].Id)
+	require.Equal(t, "invalid event", *(*dead)[0].LastError)
+	require.False(t, (*dead)[0].DeadAt.IsZero())
+
+	// Redelivered events are due again with all attempts
+	_, err = repo.Redeliver(ctx, deleted.Id)
+	require.True(t, errors.Is(err, ErrNotFound))
+	event, err := repo.Redeliver(ctx, updated.Id)
+	require.Nil(t, err)
+	require.Equal(t, 0, event.Attempts)
+
+	events, err = repo.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int64{updated.Id, deleted.Id}, []int64{events[0].Id, events[1].Id})
+	_, total, err = repo.Dead(ctx, &query.List{Limit: 10})
+	require.Nil(t, err)
+	require.Equal(t, 0, total)
+
+	deletedCount, err := repo.DeletePublished(ctx, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, int64(0), deletedCount)
+	_, err = db.Exec(ctx, `UPDATE product_events SET published_at = now() - interval '2 hours'`)
+	require.Nil(t, err)
+	deletedCount, err = repo.DeletePublished(ctx, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, int64(3), deletedCount)
+}
+
+func Test_ProductEventRepo_Lock(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	repo := NewProductEventRepo(db)
+	ctx := context.Background()
+
+	unlock, locked, err := repo.Lock(ctx)
+	require.Nil(t, err)
+	require.True(t, locked)
+
+	_, locked, err = repo.Lock(ctx)
+	require.Nil(t, err)
+	require.False(t, locked)
+
+	unlock()
+
+	unlock, locked, err = repo.Lock(ctx)
+	require.Nil(t, err)
+	require.True(t, locked)
+	unlock()
+}
+
+func Test_ProductEventRepo_Listen(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	productRepo := NewProductRepo(db)
+	repo := NewProductEventRepo(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan models.ProductEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- repo.Listen(ctx, func(event models.ProductEvent) { events <- event })
+	}()
+
+	// Notifications sent before LISTEN are not received
+	time.Sleep(100 * time.Millisecond)
+	product := &models.Product{Name: "Test 1", Price: 1}
+	require.Nil(t, productRepo.Create(ctx, product))
+
+	select {
+	case event := <-events:
+		require.Equal(t, models.ProductEventCreated, event.Type)
+		require.Equal(t, product.Id, event.ProductId)
+		require.Equal(t, &models.Product{Id: product.Id, Name: "Test 1", Price: 1, Version: 1}, event.Data)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "event is not received")
+	}
+
+	cancel()
+	require.NotNil(t, <-done)
+}
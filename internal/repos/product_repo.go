@@ -2,12 +2,28 @@ package repos
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
 
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
 )
 
+const productColumns = `id, name, price, version, deleted_at`
+
+// productFields is a whitelist of fields available for list queries
+var productFields = map[string]string{
+	"id":    "id",
+	"name":  "name",
+	"price": "price",
+}
+
 type ProductRepo struct {
 	db *pgxpool.Pool
 }
@@ -18,39 +34,493 @@ func NewProductRepo(db *pgxpool.Pool) *ProductRepo {
 	}
 }
 
-func (s *ProductRepo) All(ctx context.Context) (*[]models.Product, error) {
-	var products []models.Product
-	sql := `SELECT * FROM products ORDER BY id`
-	err := pgxscan.Select(ctx, s.db, &products, sql)
+// Soft delete scopes
+const (
+	scopeKept    = `deleted_at IS NULL`
+	scopeTrashed = `deleted_at IS NOT NULL`
+)
+
+func (s *ProductRepo) All(ctx context.Context, list *query.List) (*[]models.Product, int, error) {
+	builder := newSQLBuilder(productFields)
+	builder.condition(scopeKept)
+	return s.all(ctx, `products`, builder, list)
+}
+
+// Trash returns soft deleted products
+func (s *ProductRepo) Trash(ctx context.Context, list *query.List) (*[]models.Product, int, error) {
+	builder := newSQLBuilder(productFields)
+	builder.condition(scopeTrashed)
+	return s.all(ctx, `products`, builder, list)
+}
+
+// AllAsOf returns products as they were at the time, products_history keeps
+// every version of product rows
+func (s *ProductRepo) AllAsOf(ctx context.Context, at time.Time, list *query.List) (*[]models.Product, int, error) {
+	builder := newSQLBuilder(productFields)
+	builder.condition(asOf(builder, at))
+	builder.condition(scopeKept)
+	return s.all(ctx, `products_history`, builder, list)
+}
+
+func (s *ProductRepo) all(ctx context.Context, table string, builder *sqlBuilder, list *query.List) (*[]models.Product, int, error) {
+	err := builder.filter(list.Filters)
 	if err != nil {
-		return nil, err
+		return nil, 0, &Error{ErrValidation, err}
+	}
+	where := builder.where()
+	orderBy, err := builder.orderBy(list.Sort)
+	if err != nil {
+		return nil, 0, &Error{ErrValidation, err}
+	}
+
+	var total int
+	sql := `SELECT count(*) FROM ` + table + where
+	err = pgxscan.Get(ctx, s.db, &total, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	products := []models.Product{}
+	sql = `SELECT ` + productColumns + ` FROM ` + table + where + orderBy + builder.limit(list.Limit, list.Offset)
+	err = pgxscan.Select(ctx, s.db, &products, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return &products, total, nil
+}
+
+// asOf selects versions of products_history rows valid at the time
+func asOf(builder *sqlBuilder, at time.Time) string {
+	arg := builder.arg(at)
+	return `valid_from <= ` + arg + ` AND valid_to > ` + arg
+}
+
+// AllAfter returns products after the cursor (keyset pagination), it does
+// not depend on offset so deep pages are as fast as the first one
+func (s *ProductRepo) AllAfter(ctx context.Context, list *query.List) (*[]models.Product, error) {
+	builder := newSQLBuilder(productFields)
+	builder.condition(scopeKept)
+	err := builder.filter(list.Filters)
+	if err != nil {
+		return nil, &Error{ErrValidation, err}
+	}
+	err = builder.seek(list.After)
+	if err != nil {
+		return nil, &Error{ErrValidation, err}
+	}
+	orderBy, err := builder.orderBy(list.Sort)
+	if err != nil {
+		return nil, &Error{ErrValidation, err}
+	}
+
+	products := []models.Product{}
+	sql := `SELECT ` + productColumns + ` FROM products` + builder.where() + orderBy + builder.limit(list.Limit, 0)
+	err = pgxscan.Select(ctx, s.db, &products, sql, builder.args...)
+	if err != nil {
+		return nil, translateError(err)
 	}
 	return &products, nil
 }
 
+// Highlight delimiters of ts_headline, they're control characters removed
+// from names so the snippet can be HTML escaped before they become <mark>
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// highlightSnippet escapes the headline and wraps matches in <mark></mark>
+func highlightSnippet(headline string) string {
+	snippet := html.EscapeString(headline)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
+
+// Search ranks products by full-text match of the name plus trigram word
+// similarity, so queries with typos still find products
+func (s *ProductRepo) Search(ctx context.Context, text string, list *query.List) (*[]models.ProductSearchResult, int, error) {
+	const match = `FROM products, websearch_to_tsquery('simple', $1) AS q
+		WHERE (search_vector @@ q OR $1 <% name) AND ` + scopeKept
+
+	var total int
+	sql := `SELECT count(*) ` + match
+	err := pgxscan.Get(ctx, s.db, &total, sql, text)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	results := []models.ProductSearchResult{}
+	sql = `SELECT ` + productColumns + `,
+			ts_rank(search_vector, q) + word_similarity($1, name) AS rank,
+			ts_headline('simple', translate(name, $4, ''), q,
+				'StartSel="' || $5 || '", StopSel="' || $6 || '", HighlightAll=true') AS snippet
+		` + match + `
+		ORDER BY rank DESC, id ASC
+		LIMIT $2 OFFSET $3`
+	err = pgxscan.Select(ctx, s.db, &results, sql, text, list.Limit, list.Offset,
+		highlightStart+highlightStop, highlightStart, highlightStop)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	for i := range results {
+		results[i].Snippet = highlightSnippet(results[i].Snippet)
+	}
+	return &results, total, nil
+}
+
 func (s *ProductRepo) Find(ctx context.Context, id int) (*models.Product, error) {
 	var product models.Product
-	sql := `SELECT * FROM products WHERE id = $1 LIMIT 1`
+	sql := `SELECT ` + productColumns + ` FROM products WHERE id = $1 AND ` + scopeKept + ` LIMIT 1`
 	err := pgxscan.Get(ctx, s.db, &product, sql, id)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
+	}
+	return &product, nil
+}
+
+// FindByIds returns kept products of the ids, missing ids are skipped
+func (s *ProductRepo) FindByIds(ctx context.Context, ids []int) (*[]models.Product, error) {
+	products := []models.Product{}
+	sql := `SELECT ` + productColumns + ` FROM products WHERE id = ANY($1) AND ` + scopeKept + ` ORDER BY id`
+	err := pgxscan.Select(ctx, s.db, &products, sql, ids)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &products, nil
+}
+
+// FindAsOf returns the product as it was at the time
+func (s *ProductRepo) FindAsOf(ctx context.Context, id int, at time.Time) (*models.Product, error) {
+	var product models.Product
+	builder := newSQLBuilder(nil)
+	builder.condition(`id = ` + builder.arg(id))
+	builder.condition(asOf(builder, at))
+	builder.condition(scopeKept)
+	sql := `SELECT ` + productColumns + ` FROM products_history` + builder.where() + ` LIMIT 1`
+	err := pgxscan.Get(ctx, s.db, &product, sql, builder.args...)
+	if err != nil {
+		return nil, translateError(err)
 	}
 	return &product, nil
 }
 
 func (s *ProductRepo) Create(ctx context.Context, product *models.Product) error {
-	sql := `INSERT INTO products (name, price) VALUES ($1, $2) RETURNING id`
-	return pgxscan.Get(ctx, s.db, &product.Id, sql, product.Name, product.Price)
+	return s.write(ctx, models.ProductOpCreate, product)
 }
 
+// Update saves the product only if its version is not changed since it was
+// read, the version is incremented
 func (s *ProductRepo) Update(ctx context.Context, product *models.Product) error {
-	sql := `UPDATE products SET name = $1, price = $2 WHERE id = $3`
-	_, err := s.db.Exec(ctx, sql, product.Name, product.Price, product.Id)
-	return err
+	return s.write(ctx, models.ProductOpUpdate, product)
+}
+
+// Destroy soft deletes the product only if its version is not changed
+func (s *ProductRepo) Destroy(ctx context.Context, id int, version int) error {
+	return s.write(ctx, models.ProductOpDelete, &models.Product{Id: id, Version: version})
 }
 
-func (s *ProductRepo) Destroy(ctx context.Context, id int) error {
-	sql := `DELETE FROM products WHERE id = $1`
-	_, err := s.db.Exec(ctx, sql, id)
-	return err
+// write runs the operation and its audit in one transaction, the product is
+// changed only if the operation succeeds
+func (s *ProductRepo) write(ctx context.Context, op string, product *models.Product) error {
+	operation := models.ProductOperation{Op: op, Product: *product}
+	err := inTx(ctx, s.db, func(tx pgx.Tx) error {
+		return applyProductOperation(ctx, tx, &operation)
+	})
+	if err != nil {
+		return err
+	}
+	*product = operation.Product
+	return nil
+}
+
+// Restore brings the soft deleted product back
+func (s *ProductRepo) Restore(ctx context.Context, id int) (*models.Product, error) {
+	var product models.Product
+	err := inTx(ctx, s.db, func(tx pgx.Tx) error {
+		var deletedAt time.Time
+		sql := `UPDATE products p SET deleted_at = NULL, version = p.version + 1
+			FROM (SELECT id, deleted_at FROM products WHERE id = $1 FOR UPDATE) old
+			WHERE p.id = old.id AND p.` + scopeTrashed + `
+			RETURNING p.id, p.name, p.price, p.version, old.deleted_at`
+		err := tx.QueryRow(ctx, sql, id).Scan(&product.Id, &product.Name, &product.Price, &product.Version, &deletedAt)
+		if err != nil {
+			return translateError(err)
+		}
+		before := product
+		before.DeletedAt = &deletedAt
+		return saveProductChanges(ctx, tx, productChange{
+			audit: newProductAudit(ctx, models.ProductAuditRestore, id, &before, &product),
+			event: newProductEvent(ctx, models.ProductEventUpdated, &product),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Purge permanently deletes the soft deleted product
+func (s *ProductRepo) Purge(ctx context.Context, id int) error {
+	return inTx(ctx, s.db, func(tx pgx.Tx) error {
+		products, err := purgeProducts(ctx, tx, `id = $1 AND `+scopeTrashed, id)
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			return &Error{ErrNotFound, pgx.ErrNoRows}
+		}
+		return nil
+	})
+}
+
+// PurgeTrashed permanently deletes products soft deleted before the
+// retention window, it returns the number of deleted products
+func (s *ProductRepo) PurgeTrashed(ctx context.Context, retention time.Duration) (int64, error) {
+	var deleted int64
+	err := inTx(ctx, s.db, func(tx pgx.Tx) error {
+		products, err := purgeProducts(ctx, tx, `deleted_at < now() - $1 * interval '1 second'`, retention.Seconds())
+		deleted = int64(len(products))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// purgeProducts keeps audits in the tenant of each product, the purge job
+// sees products of all tenants
+func purgeProducts(ctx context.Context, tx pgx.Tx, where string, args ...interface{}) ([]models.Product, error) {
+	purged := []struct {
+		models.Product
+		TenantId string
+	}{}
+	sql := `DELETE FROM products WHERE ` + where + ` RETURNING ` + productColumns + `, tenant_id`
+	err := pgxscan.Select(ctx, tx, &purged, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// Purged products are already deleted for consumers, so only audits are saved
+	products := make([]models.Product, len(purged))
+	changes := make([]productChange, len(purged))
+	for i := range purged {
+		products[i] = purged[i].Product
+		changes[i].audit = newProductAudit(ctx, models.ProductAuditPurge, products[i].Id, &products[i], nil)
+		changes[i].audit.TenantId = purged[i].TenantId
+	}
+	return products, saveProductChanges(ctx, tx, changes...)
+}
+
+// BatchAtomic runs all operations in one transaction and one round trip (plus
+// one for audits and events). If an operation fails nothing is saved and the index of
+// the operation is returned (-1 if the transaction itself failed).
+func (s *ProductRepo) BatchAtomic(ctx context.Context, ops []models.ProductOperation) (int, error) {
+	batch := &pgx.Batch{}
+	for i, op := range ops {
+		sql, args, err := productOperationSQL(op)
+		if err != nil {
+			return i, err
+		}
+		batch.Queue(sql, args...)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return -1, translateError(err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	changes := make([]productChange, len(ops))
+	results := tx.SendBatch(ctx, batch)
+	for i := range ops {
+		changes[i], err = scanProductOperation(ctx, results.QueryRow(), &ops[i])
+		if err != nil {
+			results.Close()
+			if errors.Is(err, pgx.ErrNoRows) {
+				return i, batchMissingProduct(ctx, s.db, ops, i)
+			}
+			return i, translateError(err)
+		}
+	}
+	err = results.Close()
+	if err != nil {
+		return -1, translateError(err)
+	}
+
+	err = saveProductChanges(ctx, tx, changes...)
+	if err != nil {
+		return -1, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return -1, translateError(err)
+	}
+	return -1, nil
+}
+
+// BatchPartial runs each operation in its own savepoint of one transaction,
+// failed operations don't affect others. Errors are returned per operation,
+// the error is returned only if the whole batch failed.
+func (s *ProductRepo) BatchPartial(ctx context.Context, ops []models.ProductOperation) ([]error, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	errs := make([]error, len(ops))
+	for i := range ops {
+		errs[i] = savepoint(ctx, tx, func(tx pgx.Tx) error {
+			return applyProductOperation(ctx, tx, &ops[i])
+		})
+		if errors.Is(errs[i], ErrUnavailable) {
+			return nil, errs[i]
+		}
+		// The transaction can't commit anyway, so later operations are not run
+		if ctx.Err() != nil {
+			return nil, translateError(ctx.Err())
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return errs, nil
+}
+
+// Write statements are shared by single and batch writes, version 0 skips
+// the version check. Deleted products are soft deleted. Statements return
+// previous values of changed fields for audit.
+const (
+	sqlCreateProduct = `INSERT INTO products (name, price) VALUES ($1, $2) RETURNING id, version`
+	sqlUpdateProduct = `UPDATE products p SET name = $1, price = $2, version = p.version + 1
+		FROM (SELECT id, name, price FROM products WHERE id = $3 FOR UPDATE) old
+		WHERE p.id = old.id AND ($4 = 0 OR p.version = $4) AND p.` + scopeKept + `
+		RETURNING p.version, old.name, old.price`
+	sqlDestroyProduct = `UPDATE products SET deleted_at = now(), version = version + 1
+		WHERE id = $1 AND ($2 = 0 OR version = $2) AND ` + scopeKept + ` RETURNING version, deleted_at`
+)
+
+// applyProductOperation runs the operation and saves its audit and event
+func applyProductOperation(ctx context.Context, q querier, op *models.ProductOperation) error {
+	sql, args, err := productOperationSQL(*op)
+	if err != nil {
+		return err
+	}
+	change, err := scanProductOperation(ctx, q.QueryRow(ctx, sql, args...), op)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return missingProduct(ctx, q, op.Product.Id)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+	return saveProductChanges(ctx, q, change)
+}
+
+func productOperationSQL(op models.ProductOperation) (string, []interface{}, error) {
+	product := op.Product
+	switch op.Op {
+	case models.ProductOpCreate:
+		return sqlCreateProduct, []interface{}{product.Name, product.Price}, nil
+	case models.ProductOpUpdate:
+		return sqlUpdateProduct, []interface{}{product.Name, product.Price, product.Id, product.Version}, nil
+	case models.ProductOpDelete:
+		return sqlDestroyProduct, []interface{}{product.Id, product.Version}, nil
+	default:
+		return "", nil, &Error{ErrValidation, fmt.Errorf("unknown operation %q", op.Op)}
+	}
+}
+
+// scanProductOperation reads the result of the operation to the product and
+// returns the audit and the event of the operation
+func scanProductOperation(ctx context.Context, row pgx.Row, op *models.ProductOperation) (productChange, error) {
+	product := &op.Product
+	switch op.Op {
+	case models.ProductOpCreate:
+		err := row.Scan(&product.Id, &product.Version)
+		return productChange{
+			audit: newProductAudit(ctx, models.ProductAuditCreate, product.Id, nil, product),
+			event: newProductEvent(ctx, models.ProductEventCreated, product),
+		}, err
+	case models.ProductOpUpdate:
+		before := models.Product{Id: product.Id}
+		err := row.Scan(&product.Version, &before.Name, &before.Price)
+		return productChange{
+			audit: newProductAudit(ctx, models.ProductAuditUpdate, product.Id, &before, product),
+			event: newProductEvent(ctx, models.ProductEventUpdated, product),
+		}, err
+	case models.ProductOpDelete:
+		var deletedAt time.Time
+		err := row.Scan(&product.Version, &deletedAt)
+		after := models.Product{DeletedAt: &deletedAt}
+		event := newProductEvent(ctx, models.ProductEventDeleted, product)
+		event.Data = nil
+		return productChange{
+			audit: newProductAudit(ctx, models.ProductAuditDelete, product.Id, &models.Product{}, &after),
+			event: event,
+		}, err
+	default:
+		return productChange{}, &Error{ErrValidation, fmt.Errorf("unknown operation %q", op.Op)}
+	}
+}
+
+// productChange is saved in the transaction of the change, event is nil if
+// the change is not published
+type productChange struct {
+	audit models.ProductAudit
+	event *models.ProductEvent
+}
+
+func saveProductChanges(ctx context.Context, q querier, changes ...productChange) error {
+	audits := make([]models.ProductAudit, 0, len(changes))
+	events := make([]models.ProductEvent, 0, len(changes))
+	for _, change := range changes {
+		audits = append(audits, change.audit)
+		if change.event != nil {
+			event := *change.event
+			event.Changes = change.audit.Changes
+			events = append(events, event)
+		}
+	}
+
+	err := insertProductAudits(ctx, q, audits...)
+	if err != nil {
+		return err
+	}
+	return insertProductEvents(ctx, q, events...)
+}
+
+// batchMissingProduct explains why the operation of an atomic batch affected
+// no rows. All operations of the batch are already run in its transaction,
+// so the product is checked against earlier operations first and then
+// against q, which doesn't see the batch.
+func batchMissingProduct(ctx context.Context, q querier, ops []models.ProductOperation, index int) error {
+	id := ops[index].Product.Id
+	for i := index - 1; i >= 0; i-- {
+		if ops[i].Product.Id != id {
+			continue
+		}
+		if ops[i].Op == models.ProductOpDelete {
+			return &Error{ErrNotFound, pgx.ErrNoRows}
+		}
+		return &Error{ErrStale, errVersionMismatch}
+	}
+	return missingProduct(ctx, q, id)
+}
+
+// missingProduct explains why a conditional write affected no rows
+func missingProduct(ctx context.Context, q querier, id int) error {
+	var exists bool
+	sql := `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND ` + scopeKept + `)`
+	err := q.QueryRow(ctx, sql, id).Scan(&exists)
+	if err != nil {
+		return translateError(err)
+	}
+	if exists {
+		return &Error{ErrStale, errVersionMismatch}
+	}
+	return &Error{ErrNotFound, pgx.ErrNoRows}
 }
@@ -2,11 +2,15 @@ package repos
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
 	"github.com/roman-wb/crud-products/pkg/test"
 	"github.com/stretchr/testify/require"
 )
@@ -35,14 +39,16 @@ func Test_ProductRepo_All(t *testing.T) {
 
 		wantProducts := []models.Product{
 			{
-				Id:    1,
-				Name:  "Test 1",
-				Price: 100.99,
+				Id:      1,
+				Name:    "Test 1",
+				Price:   100.99,
+				Version: 1,
 			},
 			{
-				Id:    2,
-				Name:  "Test 2",
-				Price: 0,
+				Id:      2,
+				Name:    "Test 2",
+				Price:   0,
+				Version: 1,
 			},
 		}
 
@@ -50,25 +56,188 @@ func Test_ProductRepo_All(t *testing.T) {
 		_, err := db.Exec(context.Background(), sql)
 		require.Nil(t, err)
 
-		gotProducts, err := repo.All(context.Background())
+		gotProducts, gotTotal, err := repo.All(context.Background(), allProducts())
 		require.Nil(t, err)
 
+		require.Equal(t, 2, gotTotal)
 		require.Equal(t, len(wantProducts), len(*gotProducts))
 		for i, tc := range wantProducts {
 			require.Equal(t, tc, (*gotProducts)[i])
 		}
 	})
 
+	t.Run("Filter, sort and paginate", func(t *testing.T) {
+		defer test.Truncate()
+
+		sql := `INSERT INTO products (id, name, price) VALUES
+			(1, 'Apple', 10), (2, 'Pineapple', 30), (3, 'Apple 100%', 20), (4, 'Banana', 40)`
+		_, err := db.Exec(context.Background(), sql)
+		require.Nil(t, err)
+
+		gotProducts, gotTotal, err := repo.All(context.Background(), &query.List{
+			Sort: []query.Sort{{Field: "price", Desc: true}},
+			Filters: []query.Filter{
+				{Field: "name", Op: query.OpContains, Value: "apple"},
+				{Field: "price", Op: query.OpMax, Value: 30.0},
+			},
+			Limit:  2,
+			Offset: 1,
+		})
+		require.Nil(t, err)
+
+		require.Equal(t, 3, gotTotal)
+		require.Equal(t, []models.Product{
+			{Id: 3, Name: "Apple 100%", Price: 20, Version: 1},
+			{Id: 1, Name: "Apple", Price: 10, Version: 1},
+		}, *gotProducts)
+
+		gotProducts, gotTotal, err = repo.All(context.Background(), &query.List{
+			Filters: []query.Filter{{Field: "name", Op: query.OpContains, Value: "0%"}},
+			Limit:   10,
+		})
+		require.Nil(t, err)
+
+		require.Equal(t, 1, gotTotal)
+		require.Equal(t, 3, (*gotProducts)[0].Id)
+	})
+
 	t.Run("Not exist products", func(t *testing.T) {
 		defer test.Truncate()
 
-		goytProducts, err := repo.All(context.Background())
+		goytProducts, gotTotal, err := repo.All(context.Background(), allProducts())
 		require.Nil(t, err)
 
+		require.Equal(t, 0, gotTotal)
 		require.Equal(t, 0, len(*goytProducts))
 	})
 }
 
+func Test_ProductRepo_AllAfter(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+
+	sql := `INSERT INTO products (id, name, price) VALUES
+		(1, 'A', 10), (2, 'B', 20), (3, 'C', 20), (4, 'D', 30), (5, 'E', 10)`
+	_, err := db.Exec(context.Background(), sql)
+	require.Nil(t, err)
+
+	sort := []query.Sort{{Field: "price", Desc: true}, {Field: "name"}}
+
+	// First page
+	gotProducts, _, err := repo.All(context.Background(), &query.List{Sort: sort, Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, []int{4, 2}, productIds(*gotProducts))
+
+	// Rows inserted before the cursor and deleted after it do not shift pages
+	_, err = db.Exec(context.Background(), `INSERT INTO products (id, name, price) VALUES (6, 'F', 99)`)
+	require.Nil(t, err)
+	_, err = db.Exec(context.Background(), `DELETE FROM products WHERE id = 3`)
+	require.Nil(t, err)
+
+	gotProducts, err = repo.AllAfter(context.Background(), &query.List{
+		Sort:  sort,
+		Limit: 2,
+		After: &query.Cursor{Sort: sort, Values: []interface{}{20.0, "B", 2}},
+	})
+	require.Nil(t, err)
+	require.Equal(t, []int{1, 5}, productIds(*gotProducts))
+
+	gotProducts, err = repo.AllAfter(context.Background(), &query.List{
+		Sort:  sort,
+		Limit: 2,
+		After: &query.Cursor{Sort: sort, Values: []interface{}{10.0, "E", 5}},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 0, len(*gotProducts))
+
+	// Uniform sort with filter
+	gotProducts, err = repo.AllAfter(context.Background(), &query.List{
+		Sort:    []query.Sort{{Field: "id"}},
+		Filters: []query.Filter{{Field: "price", Op: query.OpMax, Value: 20.0}},
+		Limit:   10,
+		After:   &query.Cursor{Sort: []query.Sort{{Field: "id"}}, Values: []interface{}{1}},
+	})
+	require.Nil(t, err)
+	require.Equal(t, []int{2, 5}, productIds(*gotProducts))
+}
+
+func Test_ProductRepo_Search(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+
+	sql := `INSERT INTO products (id, name, price) VALUES
+		(1, 'Green apple', 10), (2, 'Apple juice', 20), (3, 'Banana', 30), (4, 'Red apple pie', 40),
+		(5, '<script>alert(1)</script> melon', 50)`
+	_, err := db.Exec(context.Background(), sql)
+	require.Nil(t, err)
+
+	t.Run("Full-text match", func(t *testing.T) {
+		gotResults, gotTotal, err := repo.Search(context.Background(), "apple", &query.List{Limit: 10})
+		require.Nil(t, err)
+
+		require.Equal(t, 3, gotTotal)
+		require.Equal(t, 3, len(*gotResults))
+		for _, result := range *gotResults {
+			require.Contains(t, result.Snippet, "<mark>")
+			require.Greater(t, result.Rank, 0.0)
+		}
+		require.Equal(t, "Green <mark>apple</mark>", (*gotResults)[0].Snippet)
+	})
+
+	t.Run("Typo tolerance", func(t *testing.T) {
+		gotResults, gotTotal, err := repo.Search(context.Background(), "bananna", &query.List{Limit: 10})
+		require.Nil(t, err)
+
+		require.Equal(t, 1, gotTotal)
+		require.Equal(t, models.Product{Id: 3, Name: "Banana", Price: 30, Version: 1}, (*gotResults)[0].Product)
+	})
+
+	t.Run("Escaped snippet", func(t *testing.T) {
+		gotResults, gotTotal, err := repo.Search(context.Background(), "melon", &query.List{Limit: 10})
+		require.Nil(t, err)
+
+		require.Equal(t, 1, gotTotal)
+		require.NotContains(t, (*gotResults)[0].Snippet, "<script>")
+		require.Contains(t, (*gotResults)[0].Snippet, "&lt;script&gt;")
+		require.Contains(t, (*gotResults)[0].Snippet, "<mark>melon</mark>")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		gotResults, gotTotal, err := repo.Search(context.Background(), "cherry", &query.List{Limit: 10})
+		require.Nil(t, err)
+
+		require.Equal(t, 0, gotTotal)
+		require.Equal(t, 0, len(*gotResults))
+	})
+}
+
+func Test_highlightSnippet(t *testing.T) {
+	testCases := []struct {
+		headline string
+		want     string
+	}{
+		{headline: "Green " + highlightStart + "apple" + highlightStop, want: "Green <mark>apple</mark>"},
+		{headline: "<b>Tom & Jerry</b> " + highlightStart + "toy" + highlightStop, want: "&lt;b&gt;Tom &amp; Jerry&lt;/b&gt; <mark>toy</mark>"},
+		{headline: `"quoted" 'name'`, want: "&#34;quoted&#34; &#39;name&#39;"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, highlightSnippet(tc.headline), tc.headline)
+	}
+}
+
 func Test_ProductRepo_Find(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
@@ -80,7 +249,7 @@ func Test_ProductRepo_Find(t *testing.T) {
 	t.Run("Exist product", func(t *testing.T) {
 		defer test.Truncate()
 
-		wantProduct := models.Product{Id: 1, Name: "Test 1", Price: 100.99}
+		wantProduct := models.Product{Id: 1, Name: "Test 1", Price: 100.99, Version: 1}
 
 		sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99), (2, 'Test 2', 0)`
 		_, err := db.Exec(context.Background(), sql)
@@ -102,10 +271,33 @@ func Test_ProductRepo_Find(t *testing.T) {
 		gotProduct, err := repo.Find(context.Background(), 10)
 
 		require.Nil(t, gotProduct)
-		require.Error(t, pgx.ErrNoRows, err)
+		require.True(t, errors.Is(err, ErrNotFound))
+		require.True(t, errors.Is(err, pgx.ErrNoRows))
 	})
 }
 
+func Test_ProductRepo_FindByIds(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+	repo := NewProductRepo(db)
+
+	sql := `INSERT INTO products (id, name, price, deleted_at) VALUES (1, 'Test 1', 1, NULL), (2, 'Test 2', 2, now()), (3, 'Test 3', 3, NULL)`
+	_, err := db.Exec(context.Background(), sql)
+	require.Nil(t, err)
+
+	// Trashed and missing products are skipped
+	products, err := repo.FindByIds(context.Background(), []int{3, 2, 1, 10})
+	require.Nil(t, err)
+	require.Equal(t, []models.Product{
+		{Id: 1, Name: "Test 1", Price: 1, Version: 1},
+		{Id: 3, Name: "Test 3", Price: 3, Version: 1},
+	}, *products)
+}
+
 func Test_Create(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
@@ -120,6 +312,7 @@ func Test_Create(t *testing.T) {
 	gotErr := repo.Create(context.Background(), product)
 
 	require.Nil(t, gotErr)
+	require.Equal(t, 1, product.Version)
 }
 
 func Test_Update(t *testing.T) {
@@ -132,21 +325,34 @@ func Test_Update(t *testing.T) {
 
 	repo := NewProductRepo(db)
 
-	wantProduct1 := &models.Product{Id: 1, Name: "Test 1 - updated", Price: 1999.99}
-	wantProduct2 := &models.Product{Id: 2, Name: "Test 2", Price: 0}
+	product1 := &models.Product{Id: 1, Name: "Test 1 - updated", Price: 1999.99, Version: 1}
+	wantProduct1 := models.Product{Id: 1, Name: "Test 1 - updated", Price: 1999.99, Version: 2}
+	wantProduct2 := models.Product{Id: 2, Name: "Test 2", Price: 0, Version: 1}
 
 	sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99), (2, 'Test 2', 0)`
 	_, err := db.Exec(context.Background(), sql)
 	require.Nil(t, err)
 
-	err = repo.Update(context.Background(), wantProduct1)
+	err = repo.Update(context.Background(), product1)
 	require.Nil(t, err)
+	require.Equal(t, wantProduct1, *product1)
 
-	gotProducts, gotErr := repo.All(context.Background())
+	gotProducts, _, gotErr := repo.All(context.Background(), allProducts())
 	require.Nil(t, gotErr)
 
-	require.Equal(t, (*gotProducts)[0], *wantProduct1)
-	require.Equal(t, (*gotProducts)[1], *wantProduct2)
+	require.Equal(t, (*gotProducts)[0], wantProduct1)
+	require.Equal(t, (*gotProducts)[1], wantProduct2)
+
+	// Stale version
+	err = repo.Update(context.Background(), &models.Product{Id: 1, Name: "Test 1 - stale", Price: 1, Version: 1})
+	require.True(t, errors.Is(err, ErrStale))
+
+	gotProduct, err := repo.Find(context.Background(), 1)
+	require.Nil(t, err)
+	require.Equal(t, wantProduct1, *gotProduct)
+
+	err = repo.Update(context.Background(), &models.Product{Id: 10, Name: "Test 10", Price: 1, Version: 1})
+	require.True(t, errors.Is(err, ErrNotFound))
 }
 
 func Test_Destroy(t *testing.T) {
@@ -159,18 +365,331 @@ func Test_Destroy(t *testing.T) {
 
 	repo := NewProductRepo(db)
 
-	wantProduct := models.Product{Id: 1, Name: "Test 1", Price: 100.99}
+	wantProduct := models.Product{Id: 1, Name: "Test 1", Price: 100.99, Version: 1}
 
 	sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99), (2, 'Test 2', 0)`
 	_, err := db.Exec(context.Background(), sql)
 	require.Nil(t, err)
 
-	err = repo.Destroy(context.Background(), 2)
+	err = repo.Destroy(context.Background(), 1, 2)
+	require.True(t, errors.Is(err, ErrStale))
+
+	err = repo.Destroy(context.Background(), 2, 1)
 	require.Nil(t, err)
 
-	gotProducts, err := repo.All(context.Background())
+	gotProducts, _, err := repo.All(context.Background(), allProducts())
 	require.Nil(t, err)
 
 	require.Equal(t, 1, len(*gotProducts))
 	require.Equal(t, wantProduct, (*gotProducts)[0])
+
+	err = repo.Destroy(context.Background(), 2, 1)
+	require.True(t, errors.Is(err, ErrNotFound))
+}
+
+func Test_Create_TooLongName(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+	product := &models.Product{Name: strings.Repeat("a", 251), Price: 1}
+
+	gotErr := repo.Create(context.Background(), product)
+
+	require.True(t, errors.Is(gotErr, ErrValidation))
+}
+
+func allProducts() *query.List {
+	return &query.List{Limit: query.MaxPerPage}
+}
+
+func productIds(products []models.Product) []int {
+	ids := []int{}
+	for _, product := range products {
+		ids = append(ids, product.Id)
+	}
+	return ids
+}
+
+func Test_BatchAtomic(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	repo := NewProductRepo(db)
+
+	t.Run("Success", func(t *testing.T) {
+		defer test.Truncate()
+
+		sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99), (2, 'Test 2', 0)`
+		_, err := db.Exec(context.Background(), sql)
+		require.Nil(t, err)
+
+		ops := []models.ProductOperation{
+			{Op: models.ProductOpCreate, Product: models.Product{Name: "Test 3", Price: 3}},
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Test 1 - updated", Price: 1, Version: 1}},
+			{Op: models.ProductOpDelete, Product: models.Product{Id: 2}},
+		}
+		index, err := repo.BatchAtomic(context.Background(), ops)
+		require.Nil(t, err)
+		require.Equal(t, -1, index)
+
+		require.NotZero(t, ops[0].Product.Id)
+		require.Equal(t, 1, ops[0].Product.Version)
+		require.Equal(t, 2, ops[1].Product.Version)
+
+		gotProducts, _, err := repo.All(context.Background(), allProducts())
+		require.Nil(t, err)
+		require.Equal(t, []models.Product{
+			{Id: 1, Name: "Test 1 - updated", Price: 1, Version: 2},
+			ops[0].Product,
+		}, *gotProducts)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		defer test.Truncate()
+
+		sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99)`
+		_, err := db.Exec(context.Background(), sql)
+		require.Nil(t, err)
+
+		index, err := repo.BatchAtomic(context.Background(), []models.ProductOperation{
+			{Op: models.ProductOpCreate, Product: models.Product{Name: "Test 2", Price: 2}},
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Test 1", Price: 1, Version: 5}},
+		})
+		require.True(t, errors.Is(err, ErrStale))
+		require.Equal(t, 1, index)
+
+		index, err = repo.BatchAtomic(context.Background(), []models.ProductOperation{
+			{Op: models.ProductOpDelete, Product: models.Product{Id: 10}},
+		})
+		require.True(t, errors.Is(err, ErrNotFound))
+		require.Equal(t, 0, index)
+
+		// Failed operations are checked against earlier operations of the batch
+		index, err = repo.BatchAtomic(context.Background(), []models.ProductOperation{
+			{Op: models.ProductOpDelete, Product: models.Product{Id: 1}},
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Test 1", Price: 1}},
+		})
+		require.True(t, errors.Is(err, ErrNotFound))
+		require.Equal(t, 1, index)
+
+		index, err = repo.BatchAtomic(context.Background(), []models.ProductOperation{
+			{Op: models.ProductOpCreate, Product: models.Product{Name: "Test 2", Price: 2}},
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Test 1", Price: 1, Version: 1}},
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Test 1", Price: 2, Version: 1}},
+		})
+		require.True(t, errors.Is(err, ErrStale))
+		require.Equal(t, 2, index)
+
+		index, err = repo.BatchAtomic(context.Background(), []models.ProductOperation{
+			{Op: models.ProductOpCreate, Product: models.Product{Name: strings.Repeat("a", 251), Price: 1}},
+		})
+		require.True(t, errors.Is(err, ErrValidation))
+		require.Equal(t, 0, index)
+
+		gotProducts, _, err := repo.All(context.Background(), allProducts())
+		require.Nil(t, err)
+		require.Equal(t, []int{1}, productIds(*gotProducts))
+	})
+}
+
+func Test_BatchPartial(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+
+	sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99), (2, 'Test 2', 0)`
+	_, err := db.Exec(context.Background(), sql)
+	require.Nil(t, err)
+
+	ops := []models.ProductOperation{
+		{Op: models.ProductOpCreate, Product: models.Product{Name: strings.Repeat("a", 251), Price: 1}},
+		{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Test 1 - updated", Price: 1}},
+		{Op: models.ProductOpDelete, Product: models.Product{Id: 10}},
+		{Op: models.ProductOpDelete, Product: models.Product{Id: 2, Version: 1}},
+	}
+	errs, err := repo.BatchPartial(context.Background(), ops)
+	require.Nil(t, err)
+
+	require.True(t, errors.Is(errs[0], ErrValidation))
+	require.Nil(t, errs[1])
+	require.True(t, errors.Is(errs[2], ErrNotFound))
+	require.Nil(t, errs[3])
+
+	gotProducts, _, err := repo.All(context.Background(), allProducts())
+	require.Nil(t, err)
+	require.Equal(t, []models.Product{{Id: 1, Name: "Test 1 - updated", Price: 1, Version: 2}}, *gotProducts)
+}
+
+func Test_SoftDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+	ctx := context.Background()
+
+	sql := `INSERT INTO products (id, name, price) VALUES (1, 'Test 1', 100.99), (2, 'Test 2', 0)`
+	_, err := db.Exec(ctx, sql)
+	require.Nil(t, err)
+
+	err = repo.Destroy(ctx, 1, 1)
+	require.Nil(t, err)
+
+	// Deleted product is hidden
+	_, err = repo.Find(ctx, 1)
+	require.True(t, errors.Is(err, ErrNotFound))
+	err = repo.Update(ctx, &models.Product{Id: 1, Name: "Test 1", Price: 1, Version: 2})
+	require.True(t, errors.Is(err, ErrNotFound))
+	gotProducts, gotTotal, err := repo.All(ctx, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 1, gotTotal)
+	require.Equal(t, []int{2}, productIds(*gotProducts))
+
+	// Trash
+	gotProducts, gotTotal, err = repo.Trash(ctx, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 1, gotTotal)
+	require.Equal(t, []int{1}, productIds(*gotProducts))
+	require.NotNil(t, (*gotProducts)[0].DeletedAt)
+	require.Equal(t, 2, (*gotProducts)[0].Version)
+
+	// Restore
+	gotProduct, err := repo.Restore(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, models.Product{Id: 1, Name: "Test 1", Price: 100.99, Version: 3}, *gotProduct)
+	_, err = repo.Restore(ctx, 1)
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	// Purge only trashed products
+	err = repo.Purge(ctx, 2)
+	require.True(t, errors.Is(err, ErrNotFound))
+	err = repo.Destroy(ctx, 2, 1)
+	require.Nil(t, err)
+	err = repo.Purge(ctx, 2)
+	require.Nil(t, err)
+	gotProducts, _, err = repo.Trash(ctx, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 0, len(*gotProducts))
+}
+
+func Test_PurgeTrashed(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+
+	sql := `INSERT INTO products (id, name, price, deleted_at) VALUES
+		(1, 'Test 1', 1, now() - interval '31 days'), (2, 'Test 2', 2, now() - interval '1 day'), (3, 'Test 3', 3, NULL)`
+	_, err := db.Exec(context.Background(), sql)
+	require.Nil(t, err)
+
+	deleted, err := repo.PurgeTrashed(context.Background(), 30*24*time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	gotProducts, _, err := repo.Trash(context.Background(), allProducts())
+	require.Nil(t, err)
+	require.Equal(t, []int{2}, productIds(*gotProducts))
+}
+
+func Test_AsOf(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewProductRepo(db)
+	ctx := context.Background()
+
+	// now returns DB time, history is versioned by DB clock
+	now := func() time.Time {
+		var at time.Time
+		require.Nil(t, db.QueryRow(ctx, `SELECT clock_timestamp()`).Scan(&at))
+		return at
+	}
+
+	beforeCreate := now()
+	product1 := &models.Product{Name: "Test 1", Price: 100}
+	require.Nil(t, repo.Create(ctx, product1))
+	product2 := &models.Product{Name: "Test 2", Price: 200}
+	require.Nil(t, repo.Create(ctx, product2))
+	afterCreate := now()
+
+	product1.Price = 120
+	require.Nil(t, repo.Update(ctx, product1))
+	require.Nil(t, repo.Destroy(ctx, product2.Id, product2.Version))
+	afterUpdate := now()
+
+	_, err := repo.Restore(ctx, product2.Id)
+	require.Nil(t, err)
+	afterRestore := now()
+
+	// Find
+	_, err = repo.FindAsOf(ctx, product1.Id, beforeCreate)
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	gotProduct, err := repo.FindAsOf(ctx, product1.Id, afterCreate)
+	require.Nil(t, err)
+	require.Equal(t, models.Product{Id: product1.Id, Name: "Test 1", Price: 100, Version: 1}, *gotProduct)
+
+	gotProduct, err = repo.FindAsOf(ctx, product1.Id, afterUpdate)
+	require.Nil(t, err)
+	require.Equal(t, models.Product{Id: product1.Id, Name: "Test 1", Price: 120, Version: 2}, *gotProduct)
+
+	_, err = repo.FindAsOf(ctx, product2.Id, afterUpdate)
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	// All
+	gotProducts, gotTotal, err := repo.AllAsOf(ctx, beforeCreate, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 0, gotTotal)
+	require.Equal(t, 0, len(*gotProducts))
+
+	gotProducts, gotTotal, err = repo.AllAsOf(ctx, afterCreate, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, 2, gotTotal)
+	require.Equal(t, []models.Product{
+		{Id: product1.Id, Name: "Test 1", Price: 100, Version: 1},
+		{Id: product2.Id, Name: "Test 2", Price: 200, Version: 1},
+	}, *gotProducts)
+
+	gotProducts, _, err = repo.AllAsOf(ctx, afterUpdate, allProducts())
+	require.Nil(t, err)
+	require.Equal(t, []int{product1.Id}, productIds(*gotProducts))
+
+	list := allProducts()
+	list.Filters = []query.Filter{{Field: "price", Op: query.OpMin, Value: 150.0}}
+	gotProducts, _, err = repo.AllAsOf(ctx, afterRestore, list)
+	require.Nil(t, err)
+	require.Equal(t, []int{product2.Id}, productIds(*gotProducts))
+
+	// Purged products are kept in history
+	require.Nil(t, repo.Destroy(ctx, product2.Id, 0))
+	require.Nil(t, repo.Purge(ctx, product2.Id))
+	gotProduct, err = repo.FindAsOf(ctx, product2.Id, afterRestore)
+	require.Nil(t, err)
+	require.Equal(t, 3, gotProduct.Version)
+	_, err = repo.FindAsOf(ctx, product2.Id, now())
+	require.True(t, errors.Is(err, ErrNotFound))
 }
@@ -0,0 +1,151 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/roman-wb/crud-products/pkg/query"
+)
+
+// sqlBuilder renders list queries to SQL, fields are mapped to columns by
+// whitelist so user input never gets into the statement as is
+type sqlBuilder struct {
+	columns    map[string]string
+	conditions []string
+	args       []interface{}
+}
+
+func newSQLBuilder(columns map[string]string) *sqlBuilder {
+	return &sqlBuilder{
+		columns: columns,
+	}
+}
+
+func (b *sqlBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *sqlBuilder) column(field string) (string, error) {
+	column, ok := b.columns[field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", field)
+	}
+	return column, nil
+}
+
+// condition adds a fixed condition, it must not contain user input
+func (b *sqlBuilder) condition(sql string) {
+	b.conditions = append(b.conditions, sql)
+}
+
+func (b *sqlBuilder) filter(filters []query.Filter) error {
+	for _, filter := range filters {
+		column, err := b.column(filter.Field)
+		if err != nil {
+			return err
+		}
+		switch filter.Op {
+		case query.OpEq:
+			b.conditions = append(b.conditions, column+" = "+b.arg(filter.Value))
+		case query.OpContains:
+			value := escapeLike(fmt.Sprint(filter.Value))
+			b.conditions = append(b.conditions, column+" ILIKE '%' || "+b.arg(value)+" || '%'")
+		case query.OpMin:
+			b.conditions = append(b.conditions, column+" >= "+b.arg(filter.Value))
+		case query.OpMax:
+			b.conditions = append(b.conditions, column+" <= "+b.arg(filter.Value))
+		default:
+			return fmt.Errorf("unknown filter operator %q", filter.Op)
+		}
+	}
+	return nil
+}
+
+// seek adds keyset condition for rows after the cursor. Row comparison
+// `(a, b, id) > ($1, $2, $3)` is used when all keys have the same direction
+// (it can use a composite index), otherwise it is expanded to
+// `a > $1 OR (a = $1 AND b < $2) OR ...`.
+func (b *sqlBuilder) seek(cursor *query.Cursor) error {
+	keys := query.Keys(cursor.Sort)
+	if len(keys) != len(cursor.Values) {
+		return fmt.Errorf("cursor has %d values for %d keys", len(cursor.Values), len(keys))
+	}
+
+	columns := []string{}
+	for _, key := range keys {
+		column, err := b.column(key.Field)
+		if err != nil {
+			return err
+		}
+		columns = append(columns, column)
+	}
+	args := []string{}
+	for _, value := range cursor.Values {
+		args = append(args, b.arg(value))
+	}
+
+	uniform := true
+	for _, key := range keys {
+		uniform = uniform && key.Desc == keys[0].Desc
+	}
+	if uniform {
+		b.conditions = append(b.conditions, fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(columns, ", "), seekOp(keys[0]), strings.Join(args, ", ")))
+		return nil
+	}
+
+	alternatives := []string{}
+	for i, key := range keys {
+		parts := []string{}
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[j]+" = "+args[j])
+		}
+		parts = append(parts, columns[i]+" "+seekOp(key)+" "+args[i])
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	b.conditions = append(b.conditions, "("+strings.Join(alternatives, " OR ")+")")
+	return nil
+}
+
+func (b *sqlBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// orderBy always ends with the key field so pages are stable for equal values
+func (b *sqlBuilder) orderBy(sort []query.Sort) (string, error) {
+	parts := []string{}
+	for _, s := range query.Keys(sort) {
+		column, err := b.column(s.Field)
+		if err != nil {
+			return "", err
+		}
+		if s.Desc {
+			parts = append(parts, column+" DESC")
+		} else {
+			parts = append(parts, column+" ASC")
+		}
+	}
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+func (b *sqlBuilder) limit(limit, offset int) string {
+	if offset == 0 {
+		return " LIMIT " + b.arg(limit)
+	}
+	return " LIMIT " + b.arg(limit) + " OFFSET " + b.arg(offset)
+}
+
+func seekOp(key query.Sort) string {
+	if key.Desc {
+		return "<"
+	}
+	return ">"
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,104 @@
+package repos
+
+import (
+	"testing"
+
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SQLBuilder(t *testing.T) {
+	builder := newSQLBuilder(productFields)
+
+	err := builder.filter([]query.Filter{
+		{Field: "name", Op: query.OpContains, Value: "50%_off"},
+		{Field: "price", Op: query.OpMin, Value: 1.5},
+		{Field: "price", Op: query.OpMax, Value: 10.0},
+		{Field: "id", Op: query.OpEq, Value: 7},
+	})
+	require.Nil(t, err)
+	require.Equal(t, ` WHERE name ILIKE '%' || $1 || '%' AND price >= $2 AND price <= $3 AND id = $4`, builder.where())
+
+	orderBy, err := builder.orderBy([]query.Sort{{Field: "price", Desc: true}, {Field: "name"}})
+	require.Nil(t, err)
+	require.Equal(t, ` ORDER BY price DESC, name ASC, id ASC`, orderBy)
+
+	require.Equal(t, ` LIMIT $5 OFFSET $6`, builder.limit(20, 40))
+	require.Equal(t, ` LIMIT $7`, builder.limit(20, 0))
+	require.Equal(t, []interface{}{`50\%\_off`, 1.5, 10.0, 7, 20, 40, 20}, builder.args)
+}
+
+func Test_SQLBuilder_Condition(t *testing.T) {
+	builder := newSQLBuilder(productFields)
+	builder.condition(scopeKept)
+
+	err := builder.filter([]query.Filter{{Field: "id", Op: query.OpEq, Value: 7}})
+	require.Nil(t, err)
+	require.Equal(t, ` WHERE deleted_at IS NULL AND id = $1`, builder.where())
+}
+
+func Test_SQLBuilder_UnknownField(t *testing.T) {
+	builder := newSQLBuilder(productFields)
+
+	err := builder.filter([]query.Filter{{Field: "name; DROP TABLE products", Op: query.OpEq, Value: 1}})
+	require.Error(t, err)
+
+	_, err = builder.orderBy([]query.Sort{{Field: "secret"}})
+	require.Error(t, err)
+}
+
+func Test_SQLBuilder_OrderByID(t *testing.T) {
+	builder := newSQLBuilder(productFields)
+
+	orderBy, err := builder.orderBy([]query.Sort{{Field: "id", Desc: true}})
+	require.Nil(t, err)
+	require.Equal(t, ` ORDER BY id DESC`, orderBy)
+}
+
+func Test_SQLBuilder_Seek(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cursor    *query.Cursor
+		wantWhere string
+		wantErr   bool
+	}{
+		{
+			name:      "uniform ascending",
+			cursor:    &query.Cursor{Sort: []query.Sort{{Field: "price"}}, Values: []interface{}{10.5, 3}},
+			wantWhere: ` WHERE (price, id) > ($1, $2)`,
+		},
+		{
+			name:      "uniform descending",
+			cursor:    &query.Cursor{Sort: []query.Sort{{Field: "id", Desc: true}}, Values: []interface{}{3}},
+			wantWhere: ` WHERE (id) < ($1)`,
+		},
+		{
+			name:      "mixed directions",
+			cursor:    &query.Cursor{Sort: []query.Sort{{Field: "price", Desc: true}, {Field: "name"}}, Values: []interface{}{10.5, "A", 3}},
+			wantWhere: ` WHERE ((price < $1) OR (price = $1 AND name > $2) OR (price = $1 AND name = $2 AND id > $3))`,
+		},
+		{
+			name:    "values mismatch",
+			cursor:  &query.Cursor{Sort: []query.Sort{{Field: "price"}}, Values: []interface{}{10.5}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			builder := newSQLBuilder(productFields)
+			err := builder.seek(tc.cursor)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.wantWhere, builder.where())
+			require.Equal(t, tc.cursor.Values, builder.args)
+		})
+	}
+}
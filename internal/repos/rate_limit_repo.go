@@ -0,0 +1,44 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/ratelimit"
+)
+
+// RateLimitRepo keeps token buckets in Postgres, so server instances share
+// limits
+type RateLimitRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewRateLimitRepo(db *pgxpool.Pool) *RateLimitRepo {
+	return &RateLimitRepo{
+		db: db,
+	}
+}
+
+// Take takes a token from the bucket of the key, a new bucket is full
+func (s *RateLimitRepo) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	sql := `SELECT allowed, tokens FROM rate_limit_take($1, $2, $3)`
+	var allowed bool
+	var tokens float64
+	err := s.db.QueryRow(ctx, sql, key, limit.Requests, limit.Rate()).Scan(&allowed, &tokens)
+	if err != nil {
+		return ratelimit.Result{}, translateError(err)
+	}
+	return limit.Result(allowed, tokens), nil
+}
+
+// DeleteIdle removes buckets not used for idle, it returns the number of
+// deleted buckets
+func (s *RateLimitRepo) DeleteIdle(ctx context.Context, idle time.Duration) (int64, error) {
+	sql := `DELETE FROM rate_limits WHERE updated_at <= now() - $1 * interval '1 second'`
+	tag, err := s.db.Exec(ctx, sql, idle.Seconds())
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,86 @@
+package repos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/ratelimit"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewRateLimitRepo(t *testing.T) {
+	db := &pgxpool.Pool{}
+	repo := NewRateLimitRepo(db)
+
+	require.Equal(t, db, repo.db)
+}
+
+func Test_RateLimitRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	repo := NewRateLimitRepo(db)
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 2, Period: time.Hour}
+
+	t.Run("Take", func(t *testing.T) {
+		defer test.Truncate()
+
+		for _, wantRemaining := range []int{1, 0} {
+			result, err := repo.Take(ctx, "ip:127.0.0.1", limit)
+			require.Nil(t, err)
+			require.True(t, result.Allowed)
+			require.Equal(t, wantRemaining, result.Remaining)
+		}
+
+		result, err := repo.Take(ctx, "ip:127.0.0.1", limit)
+		require.Nil(t, err)
+		require.False(t, result.Allowed)
+		require.Greater(t, int64(result.RetryAfter), int64(0))
+
+		result, err = repo.Take(ctx, "ip:127.0.0.2", limit)
+		require.Nil(t, err)
+		require.True(t, result.Allowed)
+	})
+
+	t.Run("Concurrent takes", func(t *testing.T) {
+		defer test.Truncate()
+
+		// Requests waiting for the row lock don't refill the bucket again
+		slow := ratelimit.Limit{Requests: 5, Period: 24 * time.Hour}
+		results := make(chan bool, 20)
+		for i := 0; i < cap(results); i++ {
+			go func() {
+				result, err := repo.Take(ctx, "ip:127.0.0.1", slow)
+				results <- err == nil && result.Allowed
+			}()
+		}
+		allowed := 0
+		for i := 0; i < cap(results); i++ {
+			if <-results {
+				allowed++
+			}
+		}
+		require.Equal(t, slow.Requests, allowed)
+	})
+
+	t.Run("DeleteIdle", func(t *testing.T) {
+		defer test.Truncate()
+
+		_, err := repo.Take(ctx, "ip:127.0.0.1", limit)
+		require.Nil(t, err)
+		_, err = db.Exec(ctx, `UPDATE rate_limits SET updated_at = now() - interval '2 hours'`)
+		require.Nil(t, err)
+		_, err = repo.Take(ctx, "ip:127.0.0.2", limit)
+		require.Nil(t, err)
+
+		deleted, err := repo.DeleteIdle(ctx, time.Hour)
+		require.Nil(t, err)
+		require.Equal(t, int64(1), deleted)
+	})
+}
@@ -5,11 +5,23 @@ import (
 )
 
 type Repos struct {
-	Product *ProductRepo
+	Product      *ProductRepo
+	ProductAudit *ProductAuditRepo
+	ProductEvent *ProductEventRepo
+	Idempotency  *IdempotencyRepo
+	Webhook      *WebhookRepo
+	APIKey       *APIKeyRepo
+	RateLimit    *RateLimitRepo
 }
 
 func NewRepos(db *pgxpool.Pool) *Repos {
 	return &Repos{
-		Product: NewProductRepo(db),
+		Product:      NewProductRepo(db),
+		ProductAudit: NewProductAuditRepo(db),
+		ProductEvent: NewProductEventRepo(db),
+		Idempotency:  NewIdempotencyRepo(db),
+		Webhook:      NewWebhookRepo(db),
+		APIKey:       NewAPIKeyRepo(db),
+		RateLimit:    NewRateLimitRepo(db),
 	}
 }
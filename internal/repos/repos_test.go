@@ -15,4 +15,22 @@ func Test_NewRepos(t *testing.T) {
 
 	require.NotNil(t, repos.Product)
 	require.Equal(t, db, repos.Product.db)
+
+	require.NotNil(t, repos.ProductAudit)
+	require.Equal(t, db, repos.ProductAudit.db)
+
+	require.NotNil(t, repos.ProductEvent)
+	require.Equal(t, db, repos.ProductEvent.db)
+
+	require.NotNil(t, repos.Idempotency)
+	require.Equal(t, db, repos.Idempotency.db)
+
+	require.NotNil(t, repos.Webhook)
+	require.Equal(t, db, repos.Webhook.db)
+
+	require.NotNil(t, repos.APIKey)
+	require.Equal(t, db, repos.APIKey.db)
+
+	require.NotNil(t, repos.RateLimit)
+	require.Equal(t, db, repos.RateLimit.db)
 }
@@ -0,0 +1,29 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+)
+
+// TenantRole is the Postgres role of requests, row level security policies
+// of tenant tables apply to it (the owner of tables bypasses them)
+const TenantRole = "products_tenant"
+
+// currentTenant is the tenant of the connection in SQL, rows written without
+// tenant belong to the default one
+const currentTenant = `COALESCE(current_tenant(), '` + requestctx.DefaultTenant + `')`
+
+// SetTenant is pgxpool.Config.BeforeAcquire: a connection acquired with
+// a tenant in ctx runs as TenantRole with `app.tenant_id` of the tenant,
+// otherwise it's reset to the login role. It costs a round trip per acquire
+// but a connection never keeps the tenant of a previous request.
+func SetTenant(ctx context.Context, conn *pgx.Conn) bool {
+	role, tenant := "none", requestctx.Tenant(ctx)
+	if tenant != "" {
+		role = TenantRole
+	}
+	_, err := conn.Exec(ctx, `SELECT set_config('role', $1, false), set_config('app.tenant_id', $2, false)`, role, tenant)
+	return err == nil
+}
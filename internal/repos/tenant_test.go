@@ -0,0 +1,161 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantDB connects to the test database like the server, one connection
+// makes sure it's reused by every tenant
+func tenantDB(t *testing.T) *pgxpool.Pool {
+	config, err := pgxpool.ParseConfig(test.DatabaseURL)
+	require.Nil(t, err)
+	config.MaxConns = 1
+	config.BeforeAcquire = SetTenant
+
+	db, err := pgxpool.ConnectConfig(context.Background(), config)
+	require.Nil(t, err)
+	t.Cleanup(db.Close)
+	return db
+}
+
+func Test_Tenant_Isolation(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	owner := test.Setup()
+	defer test.Truncate()
+
+	db := tenantDB(t)
+	repo := NewProductRepo(db)
+	acme := requestctx.WithTenant(context.Background(), "acme")
+	globex := requestctx.WithTenant(context.Background(), "globex")
+	list := &query.List{Limit: 10}
+
+	acmeProduct := &models.Product{Name: "Acme", Price: 1}
+	require.Nil(t, repo.Create(acme, acmeProduct))
+	globexProduct := &models.Product{Name: "Globex", Price: 2}
+	require.Nil(t, repo.Create(globex, globexProduct))
+
+	// Repo queries see products of the tenant only
+	products, total, err := repo.All(acme, list)
+	require.Nil(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, acmeProduct.Id, (*products)[0].Id)
+
+	_, err = repo.Find(globex, acmeProduct.Id)
+	require.True(t, errors.Is(err, ErrNotFound))
+	acmeProduct.Price = 3
+	err = repo.Update(globex, acmeProduct)
+	require.True(t, errors.Is(err, ErrNotFound))
+	err = repo.Destroy(globex, acmeProduct.Id, acmeProduct.Version)
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	// History of other tenants is hidden too
+	products, _, err = repo.AllAsOf(globex, time.Now(), list)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(*products))
+	require.Equal(t, globexProduct.Id, (*products)[0].Id)
+
+	// Queries without tenant filter
+	for _, table := range []string{"products", "products_history", "product_audits", "product_events"} {
+		var count int
+		require.Nil(t, db.QueryRow(acme, `SELECT count(*) FROM `+table).Scan(&count))
+		require.Equal(t, 1, count, table)
+	}
+
+	// Rows can't be written to another tenant
+	_, err = db.Exec(acme, `INSERT INTO products (name, price, tenant_id) VALUES ('Globex', 1, 'globex')`)
+	require.NotNil(t, err)
+	_, err = db.Exec(acme, `UPDATE products SET tenant_id = 'globex'`)
+	require.NotNil(t, err)
+
+	// Connections without tenant are reset to the owner, it sees every tenant
+	var count int
+	require.Nil(t, db.QueryRow(context.Background(), `SELECT count(*) FROM products`).Scan(&count))
+	require.Equal(t, 2, count)
+	var tenant string
+	require.Nil(t, owner.QueryRow(context.Background(), `SELECT tenant_id FROM products WHERE id = $1`, globexProduct.Id).Scan(&tenant))
+	require.Equal(t, "globex", tenant)
+}
+
+func Test_Tenant_Idempotency(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	test.Setup()
+	defer test.Truncate()
+
+	repo := NewIdempotencyRepo(tenantDB(t))
+	acme := requestctx.WithTenant(context.Background(), "acme")
+	globex := requestctx.WithTenant(context.Background(), "globex")
+
+	// Tenants don't share keys
+	_, reserved, err := repo.Begin(acme, "key-1", "fingerprint", time.Hour, time.Minute)
+	require.Nil(t, err)
+	require.True(t, reserved)
+	_, reserved, err = repo.Begin(globex, "key-1", "other", time.Hour, time.Minute)
+	require.Nil(t, err)
+	require.True(t, reserved)
+	existing, reserved, err := repo.Begin(acme, "key-1", "fingerprint", time.Hour, time.Minute)
+	require.Nil(t, err)
+	require.False(t, reserved)
+	require.Equal(t, "fingerprint", existing.Fingerprint)
+
+	// Complete and Release change the key of their tenant only
+	err = repo.Complete(acme, "key-1", http.StatusCreated, http.Header{}, []byte(`{}`))
+	require.Nil(t, err)
+	err = repo.Release(globex, "key-1")
+	require.Nil(t, err)
+	existing, _, err = repo.Begin(acme, "key-1", "fingerprint", time.Hour, time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusCreated, *existing.Status)
+	_, reserved, err = repo.Begin(globex, "key-1", "other", time.Hour, time.Minute)
+	require.Nil(t, err)
+	require.True(t, reserved)
+}
+
+func Test_Tenant_PurgeTrashed(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	test.Setup()
+	defer test.Truncate()
+
+	db := tenantDB(t)
+	repo := NewProductRepo(db)
+	auditRepo := NewProductAuditRepo(db)
+	acme := requestctx.WithTenant(context.Background(), "acme")
+	list := &query.List{Limit: 10}
+
+	product := &models.Product{Name: "Acme", Price: 1}
+	require.Nil(t, repo.Create(acme, product))
+	require.Nil(t, repo.Destroy(acme, product.Id, product.Version))
+
+	// The purge job runs without tenant
+	deleted, err := repo.PurgeTrashed(context.Background(), time.Nanosecond)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	// The purge audit belongs to the tenant of the product
+	audits, _, err := auditRepo.History(acme, product.Id, list)
+	require.Nil(t, err)
+	require.Equal(t, models.ProductAuditPurge, (*audits)[0].Action)
+	defaultCtx := requestctx.WithTenant(context.Background(), requestctx.DefaultTenant)
+	_, total, err := auditRepo.History(defaultCtx, product.Id, list)
+	require.Nil(t, err)
+	require.Equal(t, 0, total)
+}
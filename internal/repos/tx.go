@@ -0,0 +1,48 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// querier is implemented by pgxpool.Pool and pgx.Tx, so write helpers run
+// both standalone and inside a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// inTx runs fn in a transaction, it is committed if fn succeeds
+func inTx(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return translateError(tx.Commit(ctx))
+}
+
+// savepoint runs fn in a nested transaction, its changes are rolled back on
+// error without aborting the outer transaction
+func savepoint(ctx context.Context, tx pgx.Tx, fn func(tx pgx.Tx) error) error {
+	nested, err := tx.Begin(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	defer nested.Rollback(ctx) //nolint:errcheck
+
+	err = fn(nested)
+	if err != nil {
+		return err
+	}
+	return translateError(nested.Commit(ctx))
+}
@@ -0,0 +1,181 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+)
+
+// Secret is never read back except for claimed deliveries
+const webhookColumns = `id, url, events, active, created_at, updated_at`
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+type WebhookRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewWebhookRepo(db *pgxpool.Pool) *WebhookRepo {
+	return &WebhookRepo{
+		db: db,
+	}
+}
+
+func (s *WebhookRepo) All(ctx context.Context, list *query.List) (*[]models.WebhookSubscription, int, error) {
+	var total int
+	err := pgxscan.Get(ctx, s.db, &total, `SELECT count(*) FROM webhook_subscriptions`)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	builder := newSQLBuilder(nil)
+	subscriptions := []models.WebhookSubscription{}
+	sql := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions ORDER BY id` + builder.limit(list.Limit, list.Offset)
+	err = pgxscan.Select(ctx, s.db, &subscriptions, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return &subscriptions, total, nil
+}
+
+func (s *WebhookRepo) Find(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	sql := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	err := pgxscan.Get(ctx, s.db, &subscription, sql, id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &subscription, nil
+}
+
+func (s *WebhookRepo) Create(ctx context.Context, subscription *models.WebhookSubscription) error {
+	sql := `INSERT INTO webhook_subscriptions (url, events, secret, active) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+	err := s.db.QueryRow(ctx, sql, subscription.URL, subscription.Events, subscription.Secret, subscription.Active).
+		Scan(&subscription.Id, &subscription.CreatedAt, &subscription.UpdatedAt)
+	return translateError(err)
+}
+
+// Update saves the subscription, blank secret keeps the current one
+func (s *WebhookRepo) Update(ctx context.Context, subscription *models.WebhookSubscription) error {
+	sql := `UPDATE webhook_subscriptions
+		SET url = $2, events = $3, secret = COALESCE(NULLIF($4, ''), secret), active = $5, updated_at = now()
+		WHERE id = $1 RETURNING updated_at`
+	err := s.db.QueryRow(ctx, sql, subscription.Id, subscription.URL, subscription.Events, subscription.Secret, subscription.Active).
+		Scan(&subscription.UpdatedAt)
+	return translateError(err)
+}
+
+// Destroy deletes the subscription with its deliveries
+func (s *WebhookRepo) Destroy(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return &Error{ErrNotFound, pgx.ErrNoRows}
+	}
+	return nil
+}
+
+// Deliveries returns deliveries of the subscription, newest first
+func (s *WebhookRepo) Deliveries(ctx context.Context, subscriptionId int, list *query.List) (*[]models.WebhookDelivery, int, error) {
+	builder := newSQLBuilder(nil)
+	builder.condition(`subscription_id = ` + builder.arg(subscriptionId))
+	where := builder.where()
+
+	var total int
+	sql := `SELECT count(*) FROM webhook_deliveries` + where
+	err := pgxscan.Get(ctx, s.db, &total, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	sql = `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries` + where +
+		` ORDER BY id DESC` + builder.limit(list.Limit, list.Offset)
+	err = pgxscan.Select(ctx, s.db, &deliveries, sql, builder.args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return &deliveries, total, nil
+}
+
+// Redeliver schedules the delivery to be sent again right away with all
+// attempts, e.g. a dead one after the receiver is fixed
+func (s *WebhookRepo) Redeliver(ctx context.Context, subscriptionId int, id int64) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	sql := `UPDATE webhook_deliveries SET status = $3, attempts = 0, next_attempt_at = now()
+		WHERE subscription_id = $1 AND id = $2 RETURNING ` + webhookDeliveryColumns
+	err := pgxscan.Get(ctx, s.db, &delivery, sql, subscriptionId, id, models.WebhookDeliveryPending)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &delivery, nil
+}
+
+// Enqueue creates deliveries of the event for active subscriptions of its
+// tenant, it is safe to enqueue an event twice. It returns the number of new
+// deliveries.
+func (s *WebhookRepo) Enqueue(ctx context.Context, event models.ProductEvent) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	sql := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, tenant_id)
+		SELECT id, $1::bigint, $2::text, $3::jsonb, tenant_id FROM webhook_subscriptions
+		WHERE active AND $2::text = ANY (events) AND tenant_id = $4
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+	tag, err := s.db.Exec(ctx, sql, event.Id, event.Type, payload, event.TenantId)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Claim takes due deliveries with their targets for the lease, other server
+// instances skip them. Deliveries not marked before the lease ends are sent
+// again.
+func (s *WebhookRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	sql := `UPDATE webhook_deliveries d SET next_attempt_at = now() + $3 * interval '1 second'
+		FROM webhook_subscriptions w
+		WHERE w.id = d.subscription_id AND d.id IN (
+			SELECT id FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id LIMIT $2 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret`
+	err := pgxscan.Select(ctx, s.db, &deliveries, sql, models.WebhookDeliveryPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return deliveries, nil
+}
+
+func (s *WebhookRepo) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	sql := `UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, last_status_code = $3,
+		last_error = NULL, delivered_at = now() WHERE id = $1`
+	_, err := s.db.Exec(ctx, sql, id, models.WebhookDeliverySucceeded, statusCode)
+	return translateError(err)
+}
+
+// MarkFailed schedules the next attempt of the delivery, or marks it dead if
+// nextAttemptAt is nil. statusCode is nil if there is no response.
+func (s *WebhookRepo) MarkFailed(ctx context.Context, id int64, statusCode *int, lastError string, nextAttemptAt *time.Time) error {
+	status := models.WebhookDeliveryPending
+	if nextAttemptAt == nil {
+		status = models.WebhookDeliveryDead
+	}
+	sql := `UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, last_status_code = $3,
+		last_error = $4, next_attempt_at = COALESCE($5, next_attempt_at) WHERE id = $1`
+	_, err := s.db.Exec(ctx, sql, id, status, statusCode, lastError, nextAttemptAt)
+	return translateError(err)
+}
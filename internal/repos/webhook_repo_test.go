@@ -0,0 +1,131 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewWebhookRepo(t *testing.T) {
+	db := &pgxpool.Pool{}
+	repo := NewWebhookRepo(db)
+
+	require.Equal(t, db, repo.db)
+}
+
+func Test_WebhookRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db := test.Setup()
+	defer test.Truncate()
+
+	repo := NewWebhookRepo(db)
+	ctx := context.Background()
+	list := &query.List{Limit: 10}
+
+	// Subscriptions
+	subscription := &models.WebhookSubscription{
+		URL:    "http://localhost/hook",
+		Events: []string{models.ProductEventCreated},
+		Secret: "0123456789abcdef",
+		Active: true,
+	}
+	require.Nil(t, repo.Create(ctx, subscription))
+	require.NotZero(t, subscription.Id)
+	inactive := &models.WebhookSubscription{
+		URL:    "http://localhost/inactive",
+		Events: models.WebhookEventTypes,
+		Secret: "0123456789abcdef",
+	}
+	require.Nil(t, repo.Create(ctx, inactive))
+
+	found, err := repo.Find(ctx, subscription.Id)
+	require.Nil(t, err)
+	require.Equal(t, "", found.Secret)
+	require.Equal(t, subscription.Events, found.Events)
+
+	subscriptions, total, err := repo.All(ctx, list)
+	require.Nil(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, subscription.Id, (*subscriptions)[0].Id)
+
+	// Blank secret keeps the current one
+	found.Events = []string{models.ProductEventCreated, models.ProductEventUpdated}
+	require.Nil(t, repo.Update(ctx, found))
+
+	// Deliveries are enqueued once for active subscriptions of the type and
+	// the tenant
+	event := models.ProductEvent{Id: 1, Type: models.ProductEventUpdated, ProductId: 1, Version: 2, TenantId: requestctx.DefaultTenant}
+	count, err := repo.Enqueue(ctx, event)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+	count, err = repo.Enqueue(ctx, event)
+	require.Nil(t, err)
+	require.Equal(t, int64(0), count)
+	count, err = repo.Enqueue(ctx, models.ProductEvent{Id: 2, Type: models.ProductEventDeleted, TenantId: requestctx.DefaultTenant})
+	require.Nil(t, err)
+	require.Equal(t, int64(0), count)
+	count, err = repo.Enqueue(ctx, models.ProductEvent{Id: 3, Type: models.ProductEventUpdated, TenantId: "other"})
+	require.Nil(t, err)
+	require.Equal(t, int64(0), count)
+
+	claimed, err := repo.Claim(ctx, 10, time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(claimed))
+	delivery := claimed[0]
+	require.Equal(t, subscription.URL, delivery.URL)
+	require.Equal(t, subscription.Secret, delivery.Secret)
+	require.Equal(t, event.Id, delivery.Payload.Id)
+
+	// Claimed deliveries are leased
+	claimed, err = repo.Claim(ctx, 10, time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(claimed))
+
+	statusCode := 500
+	require.Nil(t, repo.MarkFailed(ctx, delivery.Id, &statusCode, "500 Internal Server Error", nil))
+
+	deliveries, total, err := repo.Deliveries(ctx, subscription.Id, list)
+	require.Nil(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, models.WebhookDeliveryDead, (*deliveries)[0].Status)
+	require.Equal(t, 1, (*deliveries)[0].Attempts)
+	require.Equal(t, &statusCode, (*deliveries)[0].LastStatusCode)
+
+	redelivered, err := repo.Redeliver(ctx, subscription.Id, delivery.Id)
+	require.Nil(t, err)
+	require.Equal(t, models.WebhookDeliveryPending, redelivered.Status)
+	require.Equal(t, 0, redelivered.Attempts)
+	_, err = repo.Redeliver(ctx, inactive.Id, delivery.Id)
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	claimed, err = repo.Claim(ctx, 10, time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(claimed))
+	require.Nil(t, repo.MarkDelivered(ctx, delivery.Id, 204))
+
+	deliveries, _, err = repo.Deliveries(ctx, subscription.Id, list)
+	require.Nil(t, err)
+	require.Equal(t, models.WebhookDeliverySucceeded, (*deliveries)[0].Status)
+	require.NotNil(t, (*deliveries)[0].DeliveredAt)
+
+	// Deleting a subscription deletes its deliveries
+	require.Nil(t, repo.Destroy(ctx, subscription.Id))
+	require.True(t, errors.Is(repo.Destroy(ctx, subscription.Id), ErrNotFound))
+	_, err = repo.Find(ctx, subscription.Id)
+	require.True(t, errors.Is(err, ErrNotFound))
+	deliveries, total, err = repo.Deliveries(ctx, subscription.Id, list)
+	require.Nil(t, err)
+	require.Equal(t, 0, total)
+	require.Equal(t, 0, len(*deliveries))
+}
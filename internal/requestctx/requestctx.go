@@ -0,0 +1,107 @@
+// Package requestctx keeps request metadata (who sent the request and its
+// id) in the context, so it reaches repos without changing their signatures
+package requestctx
+
+import (
+	"context"
+	"time"
+)
+
+// AnonymousActor is the actor of requests without credentials
+const AnonymousActor = "anonymous"
+
+// SystemActor is the actor of background jobs
+const SystemActor = "system"
+
+// DefaultTenant is the tenant of requests which don't choose one, rows
+// created before tenants belong to it
+const DefaultTenant = "default"
+
+type key int
+
+const (
+	actorKey key = iota
+	requestIDKey
+	principalKey
+	tenantKey
+)
+
+// Principal is the authenticated client of the request: an API key or
+// a JWT subject. Claims are verified claims of the JWT, nil for API keys.
+// Tenant is blank if the principal has no tenant, admins choose it then.
+type Principal struct {
+	Subject string
+	Issuer  string
+	Tenant  string
+	Scopes  []string
+	Roles   []string
+	Claims  map[string]interface{}
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, value := range p.Scopes {
+		if value == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the actor of the request, AnonymousActor if it's not set
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the id of the request, blank if it's not set
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFrom returns the principal of the request, nil if it's anonymous
+func PrincipalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey).(*Principal)
+	return principal
+}
+
+// WithTenant scopes DB connections of the request to the tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Tenant returns the tenant of the request, blank if it's not scoped (e.g.
+// background jobs)
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey).(string)
+	return tenant
+}
+
+// Detach returns a context with the values of ctx (actor, tenant, ...)
+// which is not cancelled with it, it's meant for writes that must finish
+// after the request is done
+func Detach(ctx context.Context) context.Context {
+	return detached{ctx}
+}
+
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (deadline time.Time, ok bool) { return }
+func (detached) Done() <-chan struct{}                   { return nil }
+func (detached) Err() error                              { return nil }
@@ -0,0 +1,50 @@
+package requestctx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Actor(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, AnonymousActor, Actor(ctx))
+	require.Equal(t, AnonymousActor, Actor(WithActor(ctx, "")))
+	require.Equal(t, "alice", Actor(WithActor(ctx, "alice")))
+}
+
+func Test_RequestID(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, "", RequestID(ctx))
+	require.Equal(t, "abc", RequestID(WithRequestID(ctx, "abc")))
+}
+
+func Test_Principal(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, PrincipalFrom(ctx))
+
+	principal := &Principal{Subject: "user-1", Scopes: []string{"products:read"}}
+	require.Equal(t, principal, PrincipalFrom(WithPrincipal(ctx, principal)))
+	require.True(t, principal.HasScope("products:read"))
+	require.False(t, principal.HasScope("products:write"))
+}
+
+func Test_Tenant(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, "", Tenant(ctx))
+	require.Equal(t, "acme", Tenant(WithTenant(ctx, "acme")))
+}
+
+func Test_Detach(t *testing.T) {
+	ctx, cancel := context.WithTimeout(WithTenant(context.Background(), "acme"), time.Minute)
+	cancel()
+
+	detached := Detach(ctx)
+	require.Equal(t, "acme", Tenant(detached))
+	require.Nil(t, detached.Err())
+	require.Nil(t, detached.Done())
+	_, ok := detached.Deadline()
+	require.False(t, ok)
+}
@@ -0,0 +1,144 @@
+//go:generate mockgen -destination mock_server/auth_repo.go . AuthRepo
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/jwt"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const HeaderAuthorization = "Authorization"
+const HeaderWWWAuthenticate = "WWW-Authenticate"
+const ParamToken = "token"
+
+const MessageInvalidKey = "API key or token is missing or invalid"
+const MessageMissingScope = "Credentials don't have scope "
+const MessageUnknownRoute = "Route is not allowed"
+
+// AdminActor is the actor of requests with the admin key
+const AdminActor = "admin"
+
+type AuthRepo interface {
+	Authenticate(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+// Auth authenticates requests with `Authorization: Bearer <key>`, the key
+// is an API key or a JWT (when verifier is set). The scope a request needs
+// is looked up by the route name in scopes. Routes with blank scope are
+// public, routes missing in scopes are forbidden. Stream routes also take
+// the key from the `token` query param (browsers can't set headers of
+// EventSource and WebSocket). adminKey has all scopes, it's meant to create
+// the first keys, blank disables it.
+//
+// The principal and the actor of the request are set in the context.
+func Auth(logger *zap.Logger, repo AuthRepo, verifier *jwt.Verifier, adminKey string, scopes map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			var name string
+			if route := mux.CurrentRoute(req); route != nil {
+				name = route.GetName()
+			}
+			scope, ok := scopes[name]
+			if !ok {
+				utils.ResponseForbidden(res, req, MessageUnknownRoute)
+				return
+			}
+			if scope == "" {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			token := bearerToken(req)
+			if token == "" && streamRoutes[name] {
+				token = req.URL.Query().Get(ParamToken)
+			}
+			if token == "" {
+				res.Header().Set(HeaderWWWAuthenticate, "Bearer")
+				utils.ResponseUnauthorized(res, req, MessageInvalidKey)
+				return
+			}
+
+			principal, actor, err := authenticate(req.Context(), repo, verifier, adminKey, token)
+			if err != nil {
+				switch {
+				case errors.Is(err, errInvalidCredentials) || errors.Is(err, repos.ErrNotFound):
+					logger.Debug("invalid credentials", zap.Error(err))
+					res.Header().Set(HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+					utils.ResponseUnauthorized(res, req, MessageInvalidKey)
+				case errors.Is(err, repos.ErrUnavailable):
+					logger.Sugar().Error(err)
+					utils.ResponseUnavailable(res, req)
+				default:
+					logger.Sugar().Error(err)
+					utils.ResponseInternalError(res, req)
+				}
+				return
+			}
+			if !principal.HasScope(scope) {
+				utils.ResponseForbidden(res, req, MessageMissingScope+scope)
+				return
+			}
+
+			ctx := requestctx.WithActor(requestctx.WithPrincipal(req.Context(), principal), actor)
+			next.ServeHTTP(res, req.WithContext(ctx))
+		})
+	}
+}
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// authenticate returns the principal of the token and the actor of audit
+// trail: `admin`, `api_key:<id>` or `jwt:<subject>`
+func authenticate(ctx context.Context, repo AuthRepo, verifier *jwt.Verifier, adminKey string, token string) (*requestctx.Principal, string, error) {
+	if verifier != nil && jwt.IsToken(token) {
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %v", errInvalidCredentials, err)
+		}
+		principal := &requestctx.Principal{
+			Subject: claims.Subject,
+			Issuer:  claims.Issuer,
+			Tenant:  claims.Tenant,
+			Scopes:  claims.Scopes,
+			Roles:   claims.Roles,
+			Claims:  claims.Raw,
+		}
+		return principal, "jwt:" + claims.Subject, nil
+	}
+
+	if adminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminKey)) == 1 {
+		return &requestctx.Principal{Subject: AdminActor, Scopes: models.Scopes, Roles: []string{RoleAdmin}}, AdminActor, nil
+	}
+
+	key, err := repo.Authenticate(ctx, models.HashAPIKey(token))
+	if err != nil {
+		return nil, "", err
+	}
+	actor := "api_key:" + strconv.Itoa(key.Id)
+	principal := &requestctx.Principal{Subject: actor, Scopes: key.Scopes, Roles: key.Roles}
+	if key.TenantId != nil {
+		principal.Tenant = *key.TenantId
+	}
+	return principal, actor, nil
+}
+
+func bearerToken(req *http.Request) string {
+	header := req.Header.Get(HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/jwt"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/internal/server/mock_server"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_Auth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockAuthRepo(ctrl)
+	reader := &models.APIKey{Id: 7, Scopes: []string{models.ScopeProductsRead}}
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("reader")).Return(reader, nil).AnyTimes()
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("unknown")).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")}).AnyTimes()
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("down")).
+		Return(nil, &repos.Error{Kind: repos.ErrUnavailable, Err: errors.New("connection refused")}).AnyTimes()
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("broken")).
+		Return(nil, errors.New("some error...")).AnyTimes()
+
+	var gotActor string
+	router := mux.NewRouter()
+	handler := func(res http.ResponseWriter, req *http.Request) {
+		gotActor = requestctx.Actor(req.Context())
+	}
+	router.HandleFunc("/health", handler).Name("health")
+	router.HandleFunc("/products", handler).Methods("GET").Name("products.index")
+	router.HandleFunc("/products", handler).Methods("POST").Name("products.create")
+	router.HandleFunc("/products/stream", handler).Name("products.stream")
+	router.HandleFunc("/unknown", handler).Name("unknown")
+	router.Use(Auth(zaptest.NewLogger(t), mock, nil, "admin-secret", map[string]string{
+		"health":          "",
+		"products.index":  models.ScopeProductsRead,
+		"products.create": models.ScopeProductsWrite,
+		"products.stream": models.ScopeProductsRead,
+	}))
+
+	testCases := []struct {
+		name       string
+		method     string
+		url        string
+		header     string
+		wantStatus int
+		wantActor  string
+	}{
+		{name: "public", method: "GET", url: "/health", wantStatus: http.StatusOK, wantActor: requestctx.AnonymousActor},
+		{name: "unknown route", method: "GET", url: "/unknown", header: "Bearer admin-secret", wantStatus: http.StatusForbidden},
+		{name: "missing", method: "GET", url: "/products", wantStatus: http.StatusUnauthorized},
+		{name: "not bearer", method: "GET", url: "/products", header: "Basic cmVhZGVy", wantStatus: http.StatusUnauthorized},
+		{name: "invalid", method: "GET", url: "/products", header: "Bearer unknown", wantStatus: http.StatusUnauthorized},
+		{name: "scope", method: "GET", url: "/products", header: "Bearer reader", wantStatus: http.StatusOK, wantActor: "api_key:7"},
+		{name: "lowercase bearer", method: "GET", url: "/products", header: "bearer reader", wantStatus: http.StatusOK, wantActor: "api_key:7"},
+		{name: "missing scope", method: "POST", url: "/products", header: "Bearer reader", wantStatus: http.StatusForbidden},
+		{name: "admin", method: "POST", url: "/products", header: "Bearer admin-secret", wantStatus: http.StatusOK, wantActor: AdminActor},
+		{name: "query token of stream", method: "GET", url: "/products/stream?token=reader", wantStatus: http.StatusOK, wantActor: "api_key:7"},
+		{name: "query token of others", method: "GET", url: "/products?token=reader", wantStatus: http.StatusUnauthorized},
+		{name: "unavailable", method: "GET", url: "/products", header: "Bearer down", wantStatus: http.StatusServiceUnavailable},
+		{name: "error", method: "GET", url: "/products", header: "Bearer broken", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		gotActor = ""
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		req.Header.Set(HeaderAuthorization, tc.header)
+
+		router.ServeHTTP(res, req)
+
+		require.Equal(t, tc.wantStatus, res.Result().StatusCode, tc.name)
+		require.Equal(t, tc.wantActor, gotActor, tc.name)
+		if tc.wantStatus == http.StatusUnauthorized {
+			require.True(t, strings.HasPrefix(res.Header().Get(HeaderWWWAuthenticate), "Bearer"), tc.name)
+		}
+	}
+}
+
+func Test_Auth_WithoutAdminKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockAuthRepo(ctrl)
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("")).Times(0)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/products", func(http.ResponseWriter, *http.Request) {}).Name("products.index")
+	router.Use(Auth(zaptest.NewLogger(t), mock, nil, "", map[string]string{"products.index": models.ScopeProductsRead}))
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/products", nil)
+	req.Header.Set(HeaderAuthorization, "Bearer ")
+
+	router.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusUnauthorized, res.Result().StatusCode)
+}
+
+// signHS256 issues a JWT like an identity provider
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func Test_Auth_JWT(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier := jwt.NewVerifier(&jwt.KeySet{Keys: []jwt.Key{{Public: secret}}})
+	verifier.Audience = "crud-products"
+
+	var gotPrincipal *requestctx.Principal
+	var gotActor string
+	router := mux.NewRouter()
+	router.HandleFunc("/products", func(res http.ResponseWriter, req *http.Request) {
+		gotPrincipal = requestctx.PrincipalFrom(req.Context())
+		gotActor = requestctx.Actor(req.Context())
+	}).Methods("GET").Name("products.index")
+	router.HandleFunc("/products", func(http.ResponseWriter, *http.Request) {}).Methods("POST").Name("products.create")
+	router.Use(Auth(zaptest.NewLogger(t), mock_server.NewMockAuthRepo(ctrl), verifier, "", map[string]string{
+		"products.index":  models.ScopeProductsRead,
+		"products.create": models.ScopeProductsWrite,
+	}))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	reader := signHS256(t, secret, map[string]interface{}{
+		"sub": "user-1", "aud": "crud-products", "exp": exp, "scope": "products:read", "tenant_id": "acme",
+	})
+	expired := signHS256(t, secret, map[string]interface{}{
+		"sub": "user-1", "aud": "crud-products", "exp": time.Now().Add(-time.Hour).Unix(), "scope": "products:read",
+	})
+	otherAudience := signHS256(t, secret, map[string]interface{}{
+		"sub": "user-1", "aud": "other", "exp": exp, "scope": "products:read",
+	})
+
+	testCases := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid", method: "GET", token: reader, wantStatus: http.StatusOK},
+		{name: "missing scope", method: "POST", token: reader, wantStatus: http.StatusForbidden},
+		{name: "expired", method: "GET", token: expired, wantStatus: http.StatusUnauthorized},
+		{name: "other audience", method: "GET", token: otherAudience, wantStatus: http.StatusUnauthorized},
+		{name: "invalid signature", method: "GET", token: reader[:len(reader)-4] + "AAAA", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, "/products", nil)
+		req.Header.Set(HeaderAuthorization, "Bearer "+tc.token)
+
+		router.ServeHTTP(res, req)
+
+		require.Equal(t, tc.wantStatus, res.Result().StatusCode, tc.name)
+		if tc.wantStatus == http.StatusUnauthorized {
+			require.Equal(t, `Bearer error="invalid_token"`, res.Header().Get(HeaderWWWAuthenticate), tc.name)
+		}
+	}
+
+	require.Equal(t, "jwt:user-1", gotActor)
+	require.Equal(t, "user-1", gotPrincipal.Subject)
+	require.Equal(t, []string{models.ScopeProductsRead}, gotPrincipal.Scopes)
+	require.Equal(t, "acme", gotPrincipal.Claims["tenant_id"])
+	require.Equal(t, "acme", gotPrincipal.Tenant)
+}
+
+// Test_Auth_PurgeScope checks that purge needs its own scope, not products:write
+func Test_Auth_PurgeScope(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockAuthRepo(ctrl)
+	writer := &models.APIKey{Id: 7, Scopes: []string{models.ScopeProductsRead, models.ScopeProductsWrite}}
+	purger := &models.APIKey{Id: 8, Scopes: []string{models.ScopeProductsPurge}}
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("writer")).Return(writer, nil).AnyTimes()
+	mock.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("purger")).Return(purger, nil).AnyTimes()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/products/trash/{id}", func(http.ResponseWriter, *http.Request) {}).Methods("DELETE").Name("products.purge")
+	router.Use(Auth(zaptest.NewLogger(t), mock, nil, "admin-secret", routeScopes))
+
+	testCases := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{name: "products:write", header: "Bearer writer", wantStatus: http.StatusForbidden},
+		{name: "products:purge", header: "Bearer purger", wantStatus: http.StatusOK},
+		{name: "admin", header: "Bearer admin-secret", wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("DELETE", "/products/trash/1", nil)
+		req.Header.Set(HeaderAuthorization, tc.header)
+
+		router.ServeHTTP(res, req)
+
+		require.Equal(t, tc.wantStatus, res.Result().StatusCode, tc.name)
+	}
+}
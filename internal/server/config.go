@@ -0,0 +1,297 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/ratelimit"
+)
+
+const DefaultIdempotencyTTL = 24 * time.Hour
+const DefaultIdempotencyLease = time.Minute
+const DefaultQueryTimeout = 3 * time.Second
+const DefaultTrashRetention = 30 * 24 * time.Hour
+const DefaultOutboxPublisher = "stdout"
+const DefaultOutboxInterval = time.Second
+const DefaultWebhookInterval = time.Second
+const DefaultStreamHeartbeat = 15 * time.Second
+const DefaultStreamReplaySize = 1000
+const DefaultJWTClockSkew = time.Minute
+const DefaultRateLimitStore = RateLimitStoreMemory
+
+// Config is read from environment variables
+type Config struct {
+	ListenAddr string
+
+	// RequireIfMatch rejects product writes without If-Match header
+	RequireIfMatch bool
+
+	// IdempotencyTTL is how long responses of Idempotency-Key requests are kept,
+	// IdempotencyLease is how long a key is reserved for the request in flight
+	IdempotencyTTL   time.Duration
+	IdempotencyLease time.Duration
+
+	// QueryTimeout is the deadline of the request context (so of DB queries),
+	// RouteTimeouts overrides it by route name
+	QueryTimeout  time.Duration
+	RouteTimeouts map[string]time.Duration
+
+	// PurgeEnabled allows to permanently delete trashed products, trashed
+	// products older than TrashRetention are purged in background anyway
+	PurgeEnabled   bool
+	TrashRetention time.Duration
+
+	// OutboxPublisher is the target of product events (see outbox.NewPublisher),
+	// pending events are published every OutboxInterval
+	OutboxPublisher string
+	OutboxInterval  time.Duration
+
+	// WebhookInterval is how often due webhook deliveries are sent
+	WebhookInterval time.Duration
+
+	// StreamHeartbeat is the interval of heartbeats of idle event streams,
+	// StreamReplaySize is the number of the last events kept for resume
+	StreamHeartbeat  time.Duration
+	StreamReplaySize int
+
+	// AdminKey is an API key with all scopes, it's meant to create the
+	// first keys, blank disables it
+	AdminKey string
+
+	// JWTKeysFile is a JWKS or PEM file of keys verifying JWTs, blank
+	// disables JWTs. JWTIssuer and JWTAudience are checked when set,
+	// exp/nbf are checked with JWTClockSkew tolerance.
+	JWTKeysFile  string
+	JWTIssuer    string
+	JWTAudience  string
+	JWTClockSkew time.Duration
+
+	// PolicyFile is the JSON policy of roles (see Policy), blank disables
+	// role checks
+	PolicyFile string
+
+	// RateLimits are token bucket limits of clients (see RateLimits), buckets
+	// are kept in RateLimitStore: memory or postgres (shared by instances)
+	RateLimits     RateLimits
+	RateLimitStore string
+
+	// ValidateResponses logs responses which don't match the OpenAPI
+	// document, it's meant for development and tests
+	ValidateResponses bool
+}
+
+// NewConfig reads config with getenv (e.g. os.Getenv), blank values are
+// replaced by defaults
+func NewConfig(getenv func(key string) string) (*Config, error) {
+	config := &Config{
+		ListenAddr:       getenv("LISTEN_ADDR"),
+		IdempotencyTTL:   DefaultIdempotencyTTL,
+		IdempotencyLease: DefaultIdempotencyLease,
+		QueryTimeout:     DefaultQueryTimeout,
+		RouteTimeouts:    map[string]time.Duration{},
+		TrashRetention:   DefaultTrashRetention,
+		OutboxPublisher:  DefaultOutboxPublisher,
+		OutboxInterval:   DefaultOutboxInterval,
+		WebhookInterval:  DefaultWebhookInterval,
+		StreamHeartbeat:  DefaultStreamHeartbeat,
+		StreamReplaySize: DefaultStreamReplaySize,
+		AdminKey:         getenv("ADMIN_API_KEY"),
+		JWTKeysFile:      getenv("JWT_KEYS_FILE"),
+		JWTIssuer:        getenv("JWT_ISSUER"),
+		JWTAudience:      getenv("JWT_AUDIENCE"),
+		JWTClockSkew:     DefaultJWTClockSkew,
+		PolicyFile:       getenv("POLICY_FILE"),
+		RateLimits: RateLimits{
+			Routes:  map[string]ratelimit.Limit{},
+			Clients: map[string]ratelimit.Limit{},
+		},
+		RateLimitStore: DefaultRateLimitStore,
+	}
+
+	if raw := getenv("REQUIRE_IF_MATCH"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("REQUIRE_IF_MATCH: %w", err)
+		}
+		config.RequireIfMatch = value
+	}
+
+	if raw := getenv("IDEMPOTENCY_TTL"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("IDEMPOTENCY_TTL: %w", err)
+		}
+		config.IdempotencyTTL = value
+	}
+
+	if raw := getenv("IDEMPOTENCY_LEASE"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("IDEMPOTENCY_LEASE: %w", err)
+		}
+		config.IdempotencyLease = value
+	}
+
+	if raw := getenv("QUERY_TIMEOUT"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("QUERY_TIMEOUT: %w", err)
+		}
+		config.QueryTimeout = value
+	}
+
+	// Comma separated `<route name>=<duration>`
+	if raw := getenv("ROUTE_TIMEOUTS"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			name, duration := part, ""
+			if i := strings.Index(part, "="); i >= 0 {
+				name, duration = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+			}
+			value, err := parseDuration(duration)
+			if err != nil {
+				return nil, fmt.Errorf("ROUTE_TIMEOUTS: %s: %w", name, err)
+			}
+			config.RouteTimeouts[name] = value
+		}
+	}
+
+	if raw := getenv("PURGE_ENABLED"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("PURGE_ENABLED: %w", err)
+		}
+		config.PurgeEnabled = value
+	}
+
+	if raw := getenv("TRASH_RETENTION"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("TRASH_RETENTION: %w", err)
+		}
+		config.TrashRetention = value
+	}
+
+	if raw := getenv("OUTBOX_PUBLISHER"); raw != "" {
+		config.OutboxPublisher = raw
+	}
+
+	if raw := getenv("OUTBOX_INTERVAL"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("OUTBOX_INTERVAL: %w", err)
+		}
+		config.OutboxInterval = value
+	}
+
+	if raw := getenv("WEBHOOK_INTERVAL"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("WEBHOOK_INTERVAL: %w", err)
+		}
+		config.WebhookInterval = value
+	}
+
+	if raw := getenv("STREAM_HEARTBEAT"); raw != "" {
+		value, err := parseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("STREAM_HEARTBEAT: %w", err)
+		}
+		config.StreamHeartbeat = value
+	}
+
+	if raw := getenv("VALIDATE_RESPONSES"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("VALIDATE_RESPONSES: %w", err)
+		}
+		config.ValidateResponses = value
+	}
+
+	if raw := getenv("STREAM_REPLAY_SIZE"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("STREAM_REPLAY_SIZE: %w", err)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("STREAM_REPLAY_SIZE: must not be negative")
+		}
+		config.StreamReplaySize = value
+	}
+
+	if raw := getenv("JWT_CLOCK_SKEW"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_CLOCK_SKEW: %w", err)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("JWT_CLOCK_SKEW: must not be negative")
+		}
+		config.JWTClockSkew = value
+	}
+
+	if raw := getenv("RATE_LIMIT"); raw != "" {
+		value, err := ratelimit.ParseLimit(raw)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT: %w", err)
+		}
+		config.RateLimits.Default = &value
+	}
+
+	if raw := getenv("RATE_LIMIT_IP"); raw != "" {
+		value, err := ratelimit.ParseLimit(raw)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_IP: %w", err)
+		}
+		config.RateLimits.IP = &value
+	}
+
+	if raw := getenv("RATE_LIMIT_ROUTES"); raw != "" {
+		if err := parseLimits(raw, config.RateLimits.Routes); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+		}
+	}
+
+	if raw := getenv("RATE_LIMIT_CLIENTS"); raw != "" {
+		if err := parseLimits(raw, config.RateLimits.Clients); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_CLIENTS: %w", err)
+		}
+	}
+
+	if raw := getenv("RATE_LIMIT_STORE"); raw != "" {
+		if raw != RateLimitStoreMemory && raw != RateLimitStorePostgres {
+			return nil, fmt.Errorf("RATE_LIMIT_STORE: must be %s or %s", RateLimitStoreMemory, RateLimitStorePostgres)
+		}
+		config.RateLimitStore = raw
+	}
+
+	return config, nil
+}
+
+// parseLimits parses comma separated `<name>=<requests>/<period>` into limits
+func parseLimits(raw string, limits map[string]ratelimit.Limit) error {
+	for _, part := range strings.Split(raw, ",") {
+		name, limit := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			name, limit = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		value, err := ratelimit.ParseLimit(limit)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		limits[name] = value
+	}
+	return nil
+}
+
+func parseDuration(raw string) (time.Duration, error) {
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return value, nil
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewConfig(t *testing.T) {
+	testCases := []struct {
+		name       string
+		env        map[string]string
+		wantConfig *Config
+		wantErr    string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			wantConfig: &Config{
+				IdempotencyTTL:   DefaultIdempotencyTTL,
+				IdempotencyLease: DefaultIdempotencyLease,
+				QueryTimeout:     DefaultQueryTimeout,
+				RouteTimeouts:    map[string]time.Duration{},
+				TrashRetention:   DefaultTrashRetention,
+				OutboxPublisher:  DefaultOutboxPublisher,
+				OutboxInterval:   DefaultOutboxInterval,
+				WebhookInterval:  DefaultWebhookInterval,
+				StreamHeartbeat:  DefaultStreamHeartbeat,
+				StreamReplaySize: DefaultStreamReplaySize,
+				JWTClockSkew:     DefaultJWTClockSkew,
+				RateLimits: RateLimits{
+					Routes:  map[string]ratelimit.Limit{},
+					Clients: map[string]ratelimit.Limit{},
+				},
+				RateLimitStore: DefaultRateLimitStore,
+			},
+		},
+		{
+			name: "all",
+			env: map[string]string{
+				"LISTEN_ADDR":        "0.0.0.0:8080",
+				"REQUIRE_IF_MATCH":   "true",
+				"IDEMPOTENCY_TTL":    "1h30m",
+				"IDEMPOTENCY_LEASE":  "30s",
+				"QUERY_TIMEOUT":      "2s",
+				"ROUTE_TIMEOUTS":     "products.batch=30s, products.search = 500ms",
+				"PURGE_ENABLED":      "true",
+				"TRASH_RETENTION":    "168h",
+				"OUTBOX_PUBLISHER":   "https://example.com/events",
+				"OUTBOX_INTERVAL":    "5s",
+				"WEBHOOK_INTERVAL":   "2s",
+				"STREAM_HEARTBEAT":   "30s",
+				"STREAM_REPLAY_SIZE": "10",
+				"ADMIN_API_KEY":      "secret",
+				"JWT_KEYS_FILE":      "/etc/crud-products/jwks.json",
+				"JWT_ISSUER":         "https://auth.example.com",
+				"JWT_AUDIENCE":       "crud-products",
+				"JWT_CLOCK_SKEW":     "0s",
+				"POLICY_FILE":        "policy.json",
+				"VALIDATE_RESPONSES": "true",
+				"RATE_LIMIT":         "60/1m",
+				"RATE_LIMIT_IP":      "600/1m",
+				"RATE_LIMIT_ROUTES":  "products.index=10/1s, products.batch = 5/1m",
+				"RATE_LIMIT_CLIENTS": "api_key:7=1000/1h",
+				"RATE_LIMIT_STORE":   "postgres",
+			},
+			wantConfig: &Config{
+				ListenAddr:       "0.0.0.0:8080",
+				RequireIfMatch:   true,
+				IdempotencyTTL:   90 * time.Minute,
+				IdempotencyLease: 30 * time.Second,
+				QueryTimeout:     2 * time.Second,
+				RouteTimeouts: map[string]time.Duration{
+					"products.batch":  30 * time.Second,
+					"products.search": 500 * time.Millisecond,
+				},
+				PurgeEnabled:      true,
+				TrashRetention:    7 * 24 * time.Hour,
+				OutboxPublisher:   "https://example.com/events",
+				OutboxInterval:    5 * time.Second,
+				WebhookInterval:   2 * time.Second,
+				StreamHeartbeat:   30 * time.Second,
+				StreamReplaySize:  10,
+				AdminKey:          "secret",
+				JWTKeysFile:       "/etc/crud-products/jwks.json",
+				JWTIssuer:         "https://auth.example.com",
+				JWTAudience:       "crud-products",
+				PolicyFile:        "policy.json",
+				ValidateResponses: true,
+				RateLimits: RateLimits{
+					Default: &ratelimit.Limit{Requests: 60, Period: time.Minute},
+					IP:      &ratelimit.Limit{Requests: 600, Period: time.Minute},
+					Routes: map[string]ratelimit.Limit{
+						"products.index": {Requests: 10, Period: time.Second},
+						"products.batch": {Requests: 5, Period: time.Minute},
+					},
+					Clients: map[string]ratelimit.Limit{
+						"api_key:7": {Requests: 1000, Period: time.Hour},
+					},
+				},
+				RateLimitStore: RateLimitStorePostgres,
+			},
+		},
+		{
+			name:    "invalid bool",
+			env:     map[string]string{"REQUIRE_IF_MATCH": "yes please"},
+			wantErr: `REQUIRE_IF_MATCH: strconv.ParseBool: parsing "yes please": invalid syntax`,
+		},
+		{
+			name:    "invalid duration",
+			env:     map[string]string{"IDEMPOTENCY_TTL": "day"},
+			wantErr: `IDEMPOTENCY_TTL: time: invalid duration "day"`,
+		},
+		{
+			name:    "negative duration",
+			env:     map[string]string{"IDEMPOTENCY_TTL": "-1h"},
+			wantErr: `IDEMPOTENCY_TTL: must be positive`,
+		},
+		{
+			name:    "invalid trash retention",
+			env:     map[string]string{"TRASH_RETENTION": "0s"},
+			wantErr: `TRASH_RETENTION: must be positive`,
+		},
+		{
+			name:    "invalid replay size",
+			env:     map[string]string{"STREAM_REPLAY_SIZE": "-1"},
+			wantErr: `STREAM_REPLAY_SIZE: must not be negative`,
+		},
+		{
+			name:    "negative clock skew",
+			env:     map[string]string{"JWT_CLOCK_SKEW": "-1s"},
+			wantErr: `JWT_CLOCK_SKEW: must not be negative`,
+		},
+		{
+			name:    "invalid route timeout",
+			env:     map[string]string{"ROUTE_TIMEOUTS": "products.batch"},
+			wantErr: `ROUTE_TIMEOUTS: products.batch: time: invalid duration ""`,
+		},
+		{
+			name:    "invalid rate limit",
+			env:     map[string]string{"RATE_LIMIT": "60"},
+			wantErr: `RATE_LIMIT: "60" is not <requests>/<period>`,
+		},
+		{
+			name:    "invalid route rate limit",
+			env:     map[string]string{"RATE_LIMIT_ROUTES": "products.index=0/1s"},
+			wantErr: `RATE_LIMIT_ROUTES: products.index: requests of "0/1s" must be a positive integer`,
+		},
+		{
+			name:    "invalid rate limit store",
+			env:     map[string]string{"RATE_LIMIT_STORE": "redis"},
+			wantErr: `RATE_LIMIT_STORE: must be memory or postgres`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := NewConfig(func(key string) string { return tc.env[key] })
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.wantConfig, config)
+		})
+	}
+}
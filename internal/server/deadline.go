@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// streamRoutes are long-lived routes, they have no deadline and handle
+// timeouts of writes themselves
+var streamRoutes = map[string]bool{
+	"products.stream": true,
+	"ws":              true,
+}
+
+// Deadline sets the deadline of the request context, the timeout of a route
+// is looked up by the route name. Handlers pass the context to repos so slow
+// queries are cancelled on deadline or client disconnect.
+func Deadline(timeout time.Duration, routeTimeouts map[string]time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			routeTimeout := timeout
+			if route := mux.CurrentRoute(req); route != nil {
+				if streamRoutes[route.GetName()] {
+					next.ServeHTTP(res, req)
+					return
+				}
+				if value, ok := routeTimeouts[route.GetName()]; ok {
+					routeTimeout = value
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context(), routeTimeout)
+			defer cancel()
+
+			next.ServeHTTP(res, req.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Deadline(t *testing.T) {
+	testCases := []struct {
+		name        string
+		path        string
+		wantTimeout time.Duration
+	}{
+		{name: "default", path: "/fast", wantTimeout: time.Second},
+		{name: "route", path: "/slow", wantTimeout: time.Minute},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotTimeout time.Duration
+			handler := func(res http.ResponseWriter, req *http.Request) {
+				deadline, ok := req.Context().Deadline()
+				require.True(t, ok)
+				gotTimeout = time.Until(deadline)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/fast", handler).Name("fast")
+			router.HandleFunc("/slow", handler).Name("slow")
+			router.Use(Deadline(time.Second, map[string]time.Duration{"slow": time.Minute}))
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.path, nil))
+
+			require.LessOrEqual(t, gotTimeout, tc.wantTimeout)
+			require.Greater(t, gotTimeout, tc.wantTimeout-time.Second/2)
+		})
+	}
+}
+
+func Test_Deadline_StreamRoute(t *testing.T) {
+	hasDeadline := true
+	router := mux.NewRouter()
+	router.HandleFunc("/products/stream", func(res http.ResponseWriter, req *http.Request) {
+		_, hasDeadline = req.Context().Deadline()
+	}).Name("products.stream")
+	router.Use(Deadline(time.Second, map[string]time.Duration{}))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/products/stream", nil))
+
+	require.False(t, hasDeadline)
+}
@@ -0,0 +1,141 @@
+//go:generate mockgen -destination mock_handlers/api_key_repo.go . APIKeyRepo
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+type APIKeyRepo interface {
+	All(ctx context.Context, list *query.List) (*[]models.APIKey, int, error)
+	Find(ctx context.Context, id int) (*models.APIKey, error)
+	Create(ctx context.Context, key *models.APIKey, hash string) error
+	Rotate(ctx context.Context, key *models.APIKey, hash string) error
+	Revoke(ctx context.Context, id int) error
+}
+
+type APIKeyHandler struct {
+	logger     *zap.Logger
+	apiKeyRepo APIKeyRepo
+}
+
+func NewAPIKeyHandler(logger *zap.Logger, apiKeyRepo APIKeyRepo) *APIKeyHandler {
+	return &APIKeyHandler{
+		logger:     logger,
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+func (a APIKeyHandler) IndexHandler(res http.ResponseWriter, req *http.Request) {
+	list, errs := query.Parse(req.URL.Query(), query.Schema{})
+	if len(errs) > 0 {
+		utils.ResponseBadRequest(res, req, queryErrors(errs))
+		return
+	}
+
+	keys, total, err := a.apiKeyRepo.All(req.Context(), list)
+	if err != nil {
+		responseError(a.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: keys,
+		Meta: list.Meta(total, nil),
+	})
+}
+
+// CreateHandler generates a key with the name, scopes, roles and tenant,
+// the key is returned only here and by RotateHandler
+func (a APIKeyHandler) CreateHandler(res http.ResponseWriter, req *http.Request) {
+	var params models.APIKeyParams
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+
+	key := models.APIKey{Name: params.Name, Scopes: params.Scopes, Roles: params.Roles, TenantId: params.TenantId}
+	if key.Roles == nil {
+		key.Roles = []string{}
+	}
+	if errors := key.Validate(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
+		return
+	}
+
+	hash, err := key.Generate()
+	if err == nil {
+		err = a.apiKeyRepo.Create(req.Context(), &key, hash)
+	}
+	if err != nil {
+		responseError(a.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseCreate(res, key)
+}
+
+func (a APIKeyHandler) ShowHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(a.logger, res, req, errInvalidID)
+		return
+	}
+
+	key, err := a.apiKeyRepo.Find(req.Context(), id)
+	if err != nil {
+		responseError(a.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, key)
+}
+
+// RotateHandler replaces the key of an active key keeping its name and
+// scopes, the old key stops working right away
+func (a APIKeyHandler) RotateHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(a.logger, res, req, errInvalidID)
+		return
+	}
+
+	key := models.APIKey{Id: id}
+	hash, err := key.Generate()
+	if err == nil {
+		err = a.apiKeyRepo.Rotate(req.Context(), &key, hash)
+	}
+	if err != nil {
+		responseError(a.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, key)
+}
+
+// RevokeHandler disables the key, it's still listed with revoked_at
+func (a APIKeyHandler) RevokeHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(a.logger, res, req, errInvalidID)
+		return
+	}
+
+	err = a.apiKeyRepo.Revoke(req.Context(), id)
+	if err != nil {
+		responseError(a.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseNoContent(res)
+}
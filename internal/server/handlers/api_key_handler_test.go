@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewAPIKeyHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	repo := repos.NewAPIKeyRepo(&pgxpool.Pool{})
+
+	handler := NewAPIKeyHandler(logger, repo)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, repo, handler.apiKeyRepo)
+}
+
+func newAPIKey() *models.APIKey {
+	return &models.APIKey{
+		Id:        1,
+		Name:      "CI",
+		Prefix:    "cp_01234567",
+		Scopes:    []string{models.ScopeProductsRead},
+		Roles:     []string{"viewer"},
+		CreatedAt: time.Date(2021, 7, 30, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_APIKey_IndexHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockAPIKeyRepo(ctrl)
+	handler := NewAPIKeyHandler(zaptest.NewLogger(t), mock)
+
+	keys := []models.APIKey{*newAPIKey()}
+	mock.
+		EXPECT().
+		All(gomock.Any(), &query.List{Limit: 20}).
+		Return(&keys, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/keys", nil)
+
+	handler.IndexHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, `{"data":[{"id":1,"name":"CI","prefix":"cp_01234567","scopes":["products:read"],"roles":["viewer"],"tenant_id":null,`+
+		`"created_at":"2021-07-30T10:00:00Z","rotated_at":null,"last_used_at":null,"revoked_at":null}],`+
+		`"meta":{"total":1,"page":1,"per_page":20,"total_pages":1,"next_cursor":null}}`, utils.BodyToString(res.Body))
+}
+
+func Test_APIKey_CreateHandler_Case1_Invalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockAPIKeyRepo(ctrl)
+	handler := NewAPIKeyHandler(zaptest.NewLogger(t), mock)
+
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "invalid json", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "blank name", body: `{"name":" ","scopes":["products:read"]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown scope", body: `{"name":"CI","scopes":["products:delete"]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid role", body: `{"name":"CI","scopes":["products:read"],"roles":["Pricing Manager"]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid tenant", body: `{"name":"CI","scopes":["products:read"],"tenant_id":"Acme Inc"}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/admin/keys", strings.NewReader(tc.body))
+
+			handler.CreateHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+		})
+	}
+}
+
+func Test_APIKey_CreateHandler_Case2_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockAPIKeyRepo(ctrl)
+	handler := NewAPIKeyHandler(zaptest.NewLogger(t), mock)
+
+	var created models.APIKey
+	var createdHash string
+	mock.
+		EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ interface{}, key *models.APIKey, hash string) error {
+			key.Id = 1
+			created, createdHash = *key, hash
+			return nil
+		})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/keys", strings.NewReader(`{"name":"CI","scopes":["products:read"],"tenant_id":"acme"}`))
+
+	handler.CreateHandler(res, req)
+
+	require.Equal(t, http.StatusCreated, res.Result().StatusCode)
+	require.Equal(t, "acme", *created.TenantId)
+	require.True(t, strings.HasPrefix(created.Key, models.APIKeyPrefix))
+	require.Equal(t, models.HashAPIKey(created.Key), createdHash)
+	require.Equal(t, utils.DataToJson(created), utils.BodyToString(res.Body))
+}
+
+func Test_APIKey_ShowHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockAPIKeyRepo(ctrl)
+	handler := NewAPIKeyHandler(zaptest.NewLogger(t), mock)
+
+	mock.EXPECT().Find(gomock.Any(), 1).Return(newAPIKey(), nil)
+	mock.EXPECT().Find(gomock.Any(), 2).Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")})
+
+	testCases := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "found", id: "1", wantStatus: http.StatusOK},
+		{name: "not found", id: "2", wantStatus: http.StatusNotFound},
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/admin/keys/"+tc.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			handler.ShowHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			if tc.wantStatus == http.StatusOK {
+				require.Equal(t, utils.DataToJson(newAPIKey()), utils.BodyToString(res.Body))
+			}
+		})
+	}
+}
+
+func Test_APIKey_RotateHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockAPIKeyRepo(ctrl)
+	handler := NewAPIKeyHandler(zaptest.NewLogger(t), mock)
+
+	var rotated models.APIKey
+	mock.
+		EXPECT().
+		Rotate(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ interface{}, key *models.APIKey, hash string) error {
+			require.Equal(t, 1, key.Id)
+			require.Equal(t, models.HashAPIKey(key.Key), hash)
+			key.Name, key.Scopes = "CI", []string{models.ScopeProductsRead}
+			rotated = *key
+			return nil
+		})
+	mock.
+		EXPECT().
+		Rotate(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")})
+
+	testCases := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "rotated", id: "1", wantStatus: http.StatusOK},
+		{name: "not found or revoked", id: "2", wantStatus: http.StatusNotFound},
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/keys/"+tc.id+"/rotate", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+		handler.RotateHandler(res, req)
+
+		require.Equal(t, tc.wantStatus, res.Result().StatusCode, tc.name)
+		if tc.wantStatus == http.StatusOK {
+			require.Equal(t, utils.DataToJson(rotated), utils.BodyToString(res.Body))
+		}
+	}
+}
+
+func Test_APIKey_RevokeHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockAPIKeyRepo(ctrl)
+	handler := NewAPIKeyHandler(zaptest.NewLogger(t), mock)
+
+	mock.EXPECT().Revoke(gomock.Any(), 1).Return(nil)
+	mock.EXPECT().Revoke(gomock.Any(), 2).Return(&repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")})
+
+	testCases := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "revoked", id: "1", wantStatus: http.StatusNoContent},
+		{name: "not found", id: "2", wantStatus: http.StatusNotFound},
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/admin/keys/"+tc.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			handler.RevokeHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const MessageInvalidID = "must be an integer"
+const MessageInvalidData = "Invalid data"
+
+var errInvalidID = errors.New("invalid id")
+
+// responseError maps domain errors to HTTP statuses, only unexpected and
+// infrastructure errors are logged as errors
+func responseError(logger *zap.Logger, res http.ResponseWriter, req *http.Request, err error) {
+	utils.ResponseProblem(res, errorProblem(logger, req, err))
+}
+
+// errorProblem is the problem of responseError, batch results embed it
+func errorProblem(logger *zap.Logger, req *http.Request, err error) utils.Problem {
+	switch {
+	case errors.Is(err, repos.ErrCanceled) || errors.Is(req.Context().Err(), context.Canceled):
+		// Client is gone, the response is written for logs only
+		logger.Sugar().Infow("request canceled", "path", req.URL.Path, "error", err)
+		return utils.NewProblem(req, http.StatusServiceUnavailable, utils.CodeUnavailable, utils.MessageUnavailable)
+	case errors.Is(err, repos.ErrTimeout) || errors.Is(req.Context().Err(), context.DeadlineExceeded):
+		logger.Sugar().Warnw("request deadline exceeded", "path", req.URL.Path, "error", err)
+		return utils.NewProblem(req, http.StatusGatewayTimeout, utils.CodeTimeout, utils.MessageTimeout)
+	case errors.Is(err, errInvalidID):
+		problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+		problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+		return problem
+	case errors.Is(err, errPreconditionFailed):
+		return utils.NewProblem(req, http.StatusPreconditionFailed, utils.CodePreconditionFailed, utils.MessagePreconditionFailed)
+	case errors.Is(err, errPreconditionRequired):
+		problem := utils.NewProblem(req, http.StatusPreconditionRequired, utils.CodePreconditionRequired, utils.MessagePreconditionRequired)
+		problem.Detail = utils.HeaderIfMatch + " header is required"
+		return problem
+	case errors.Is(err, repos.ErrStale):
+		// Without If-Match the product was changed after it was loaded
+		logger.Sugar().Info(err)
+		if req.Header.Get(utils.HeaderIfMatch) != "" {
+			return utils.NewProblem(req, http.StatusPreconditionFailed, utils.CodePreconditionFailed, utils.MessagePreconditionFailed)
+		}
+		return utils.NewProblem(req, http.StatusConflict, utils.CodeConflict, utils.MessageConflict)
+	case errors.Is(err, repos.ErrNotFound):
+		return utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound)
+	case errors.Is(err, repos.ErrValidation):
+		logger.Sugar().Info(err)
+		problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+		problem.Detail = MessageInvalidData
+		return problem
+	case errors.Is(err, repos.ErrConflict):
+		logger.Sugar().Warn(err)
+		return utils.NewProblem(req, http.StatusConflict, utils.CodeConflict, utils.MessageConflict)
+	case errors.Is(err, repos.ErrUnavailable):
+		logger.Sugar().Error(err)
+		return utils.NewProblem(req, http.StatusServiceUnavailable, utils.CodeUnavailable, utils.MessageUnavailable)
+	default:
+		logger.Sugar().Error(err)
+		return utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError)
+	}
+}
+
+// queryErrors converts list query errors to field errors of a problem
+func queryErrors(errs []query.Error) []utils.FieldError {
+	fieldErrors := []utils.FieldError{}
+	for _, err := range errs {
+		fieldErrors = append(fieldErrors, utils.FieldError{
+			Field:   err.Param,
+			Code:    err.Code,
+			Message: err.Message,
+		})
+	}
+	return fieldErrors
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_responseError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/products/1", nil)
+
+	invalid := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	invalid.Detail = MessageInvalidData
+
+	preconditionRequired := utils.NewProblem(req, http.StatusPreconditionRequired, utils.CodePreconditionRequired, utils.MessagePreconditionRequired)
+	preconditionRequired.Detail = "If-Match header is required"
+
+	invalidID := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	invalidID.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+
+	testCases := []struct {
+		name        string
+		err         error
+		wantProblem utils.Problem
+	}{
+		{
+			name:        "invalid id",
+			err:         errInvalidID,
+			wantProblem: invalidID,
+		},
+		{
+			name:        "not found",
+			err:         &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")},
+			wantProblem: utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound),
+		},
+		{
+			name:        "validation",
+			err:         &repos.Error{Kind: repos.ErrValidation, Err: errors.New("value too long")},
+			wantProblem: invalid,
+		},
+		{
+			name:        "conflict",
+			err:         fmt.Errorf("wrapped: %w", &repos.Error{Kind: repos.ErrConflict, Err: errors.New("duplicate")}),
+			wantProblem: utils.NewProblem(req, http.StatusConflict, utils.CodeConflict, utils.MessageConflict),
+		},
+		{
+			name:        "precondition failed",
+			err:         errPreconditionFailed,
+			wantProblem: utils.NewProblem(req, http.StatusPreconditionFailed, utils.CodePreconditionFailed, utils.MessagePreconditionFailed),
+		},
+		{
+			name:        "precondition required",
+			err:         errPreconditionRequired,
+			wantProblem: preconditionRequired,
+		},
+		{
+			name:        "stale without If-Match",
+			err:         &repos.Error{Kind: repos.ErrStale, Err: errors.New("version mismatch")},
+			wantProblem: utils.NewProblem(req, http.StatusConflict, utils.CodeConflict, utils.MessageConflict),
+		},
+		{
+			name:        "timeout",
+			err:         &repos.Error{Kind: repos.ErrTimeout, Err: context.DeadlineExceeded},
+			wantProblem: utils.NewProblem(req, http.StatusGatewayTimeout, utils.CodeTimeout, utils.MessageTimeout),
+		},
+		{
+			name:        "canceled",
+			err:         &repos.Error{Kind: repos.ErrCanceled, Err: context.Canceled},
+			wantProblem: utils.NewProblem(req, http.StatusServiceUnavailable, utils.CodeUnavailable, utils.MessageUnavailable),
+		},
+		{
+			name:        "unavailable",
+			err:         &repos.Error{Kind: repos.ErrUnavailable, Err: context.DeadlineExceeded},
+			wantProblem: utils.NewProblem(req, http.StatusServiceUnavailable, utils.CodeUnavailable, utils.MessageUnavailable),
+		},
+		{
+			name:        "unknown",
+			err:         errors.New("some error..."),
+			wantProblem: utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+
+			responseError(zaptest.NewLogger(t), res, req, tc.err)
+
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+			require.Equal(t, tc.wantProblem.Status, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(tc.wantProblem), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_responseError_RequestContext(t *testing.T) {
+	testCases := []struct {
+		name       string
+		ctx        func() (context.Context, context.CancelFunc)
+		wantStatus int
+		wantCode   string
+		wantTitle  string
+	}{
+		{
+			name:       "deadline exceeded",
+			ctx:        func() (context.Context, context.CancelFunc) { return context.WithTimeout(context.Background(), 0) },
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   utils.CodeTimeout,
+			wantTitle:  utils.MessageTimeout,
+		},
+		{
+			name: "canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   utils.CodeUnavailable,
+			wantTitle:  utils.MessageUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			req := httptest.NewRequest("GET", "/products/1", nil).WithContext(ctx)
+			res := httptest.NewRecorder()
+
+			// Driver errors are not always wrapped context errors
+			responseError(zaptest.NewLogger(t), res, req, errors.New("conn closed"))
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(
+				utils.NewProblem(req, tc.wantStatus, tc.wantCode, tc.wantTitle),
+			), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_queryErrors(t *testing.T) {
+	got := queryErrors([]query.Error{{Param: "page", Code: query.CodeInvalid, Message: "must be an integer"}})
+
+	require.Equal(t, []utils.FieldError{{Field: "page", Code: query.CodeInvalid, Message: "must be an integer"}}, got)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+var errPreconditionFailed = errors.New("precondition failed")
+var errPreconditionRequired = errors.New("precondition required")
+
+// productETag is a strong ETag of the product version
+func productETag(product *models.Product) string {
+	return strconv.Quote(strconv.Itoa(product.Version))
+}
+
+func setETag(res http.ResponseWriter, product *models.Product) {
+	res.Header().Set(utils.HeaderETag, productETag(product))
+}
+
+// matchETag reports whether a list of ETags in the header contains etag, `*`
+// matches any. Weak ETags are only matched with weak comparison (RFC 7232).
+func matchETag(header string, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch compares If-Match with the loaded product, the repo checks the
+// version again on write so concurrent updates are detected too
+func (p ProductHandler) checkIfMatch(req *http.Request, product *models.Product) error {
+	header := req.Header.Get(utils.HeaderIfMatch)
+	if header == "" {
+		if p.RequireIfMatch {
+			return errPreconditionRequired
+		}
+		return nil
+	}
+	if !matchETag(header, productETag(product), false) {
+		return errPreconditionFailed
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_productETag(t *testing.T) {
+	require.Equal(t, `"3"`, productETag(&models.Product{Id: 1, Version: 3}))
+}
+
+func Test_matchETag(t *testing.T) {
+	testCases := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{header: `"3"`, want: true},
+		{header: `"2"`, want: false},
+		{header: `"1", "3"`, want: true},
+		{header: `*`, want: true},
+		{header: `W/"3"`, want: false},
+		{header: `W/"3"`, weak: true, want: true},
+		{header: `"33"`, weak: true, want: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.header, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, matchETag(tc.header, `"3"`, tc.weak))
+		})
+	}
+}
+
+func newETagProduct() *models.Product {
+	return &models.Product{Id: 1, Name: "Name 1", Price: 100.00, Version: 3}
+}
+
+func Test_Product_ShowHandler_ETag(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "without If-None-Match", wantStatus: http.StatusOK},
+		{name: "changed", ifNoneMatch: `"2"`, wantStatus: http.StatusOK},
+		{name: "not modified", ifNoneMatch: `"3"`, wantStatus: http.StatusNotModified},
+		{name: "not modified weak", ifNoneMatch: `W/"3"`, wantStatus: http.StatusNotModified},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+			mock.
+				EXPECT().
+				Find(gomock.Any(), 1).
+				Return(newETagProduct(), nil)
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/products/1", nil)
+			req.Header.Set(utils.HeaderIfNoneMatch, tc.ifNoneMatch)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.ShowHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, `"3"`, res.Header().Get(utils.HeaderETag))
+			if tc.wantStatus == http.StatusNotModified {
+				require.Equal(t, "", utils.BodyToString(res.Body))
+			} else {
+				require.Equal(t, utils.DataToJson(newETagProduct()), utils.BodyToString(res.Body))
+			}
+		})
+	}
+}
+
+func Test_Product_UpdateHandler_IfMatch(t *testing.T) {
+	body := `{"name": "Name 1 - update", "price": 100}`
+
+	testCases := []struct {
+		name           string
+		ifMatch        string
+		requireIfMatch bool
+		updateErr      error
+		wantStatus     int
+		wantCode       string
+	}{
+		{name: "match", ifMatch: `"3"`, wantStatus: http.StatusOK},
+		{name: "any", ifMatch: `*`, wantStatus: http.StatusOK},
+		{name: "not required", wantStatus: http.StatusOK},
+		{name: "mismatch", ifMatch: `"2"`, wantStatus: http.StatusPreconditionFailed, wantCode: utils.CodePreconditionFailed},
+		{name: "weak never matches", ifMatch: `W/"3"`, wantStatus: http.StatusPreconditionFailed, wantCode: utils.CodePreconditionFailed},
+		{name: "required", requireIfMatch: true, wantStatus: http.StatusPreconditionRequired, wantCode: utils.CodePreconditionRequired},
+		{
+			name:       "changed concurrently",
+			ifMatch:    `"3"`,
+			updateErr:  &repos.Error{Kind: repos.ErrStale, Err: errors.New("version mismatch")},
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   utils.CodePreconditionFailed,
+		},
+		{
+			name:       "changed concurrently without If-Match",
+			updateErr:  &repos.Error{Kind: repos.ErrStale, Err: errors.New("version mismatch")},
+			wantStatus: http.StatusConflict,
+			wantCode:   utils.CodeConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+			handler.RequireIfMatch = tc.requireIfMatch
+
+			mock.
+				EXPECT().
+				Find(gomock.Any(), 1).
+				Return(newETagProduct(), nil)
+
+			if tc.wantStatus == http.StatusOK || tc.updateErr != nil {
+				mock.
+					EXPECT().
+					Update(gomock.Any(), &models.Product{Id: 1, Name: "Name 1 - update", Price: 100.00, Version: 3}).
+					DoAndReturn(func(_ context.Context, product *models.Product) error {
+						if tc.updateErr != nil {
+							return tc.updateErr
+						}
+						product.Version++
+						return nil
+					})
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/products/1", bytes.NewBufferString(body))
+			req.Header.Set(utils.HeaderIfMatch, tc.ifMatch)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.UpdateHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			if tc.wantStatus == http.StatusOK {
+				require.Equal(t, `"4"`, res.Header().Get(utils.HeaderETag))
+			} else {
+				require.Contains(t, utils.BodyToString(res.Body), `"code":"`+tc.wantCode+`"`)
+			}
+		})
+	}
+}
+
+func Test_Product_PatchHandler_IfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		Find(gomock.Any(), 1).
+		Return(newETagProduct(), nil)
+
+	res := httptest.NewRecorder()
+	req := newPatchRequest(utils.ContentTypeMergePatchJSON, `{"price": 200}`)
+	req.Header.Set(utils.HeaderIfMatch, `"2"`)
+
+	handler.PatchHandler(res, req)
+
+	require.Equal(t, http.StatusPreconditionFailed, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusPreconditionFailed, utils.CodePreconditionFailed, utils.MessagePreconditionFailed),
+	), utils.BodyToString(res.Body))
+}
+
+func Test_Product_DestroyHandler_IfMatch(t *testing.T) {
+	testCases := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+	}{
+		{name: "match", ifMatch: `"3"`, wantStatus: http.StatusNoContent},
+		{name: "mismatch", ifMatch: `"2"`, wantStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+			mock.
+				EXPECT().
+				Find(gomock.Any(), 1).
+				Return(newETagProduct(), nil)
+
+			if tc.wantStatus == http.StatusNoContent {
+				mock.
+					EXPECT().
+					Destroy(gomock.Any(), 1, 3).
+					Return(nil)
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/products/1", nil)
+			req.Header.Set(utils.HeaderIfMatch, tc.ifMatch)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.DestroyHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+		})
+	}
+}
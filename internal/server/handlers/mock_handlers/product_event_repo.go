@@ -0,0 +1,68 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/roman-wb/crud-products/internal/server/handlers (interfaces: ProductEventRepo)
+
+// Package mock_handlers is a generated GoMock package.
+package mock_handlers
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/roman-wb/crud-products/internal/models"
+	query "github.com/roman-wb/crud-products/pkg/query"
+)
+
+// MockProductEventRepo is a mock of ProductEventRepo interface.
+type MockProductEventRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductEventRepoMockRecorder
+}
+
+// MockProductEventRepoMockRecorder is the mock recorder for MockProductEventRepo.
+type MockProductEventRepoMockRecorder struct {
+	mock *MockProductEventRepo
+}
+
+// NewMockProductEventRepo creates a new mock instance.
+func NewMockProductEventRepo(ctrl *gomock.Controller) *MockProductEventRepo {
+	mock := &MockProductEventRepo{ctrl: ctrl}
+	mock.recorder = &MockProductEventRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductEventRepo) EXPECT() *MockProductEventRepoMockRecorder {
+	return m.recorder
+}
+
+// Dead mocks base method.
+func (m *MockProductEventRepo) Dead(arg0 context.Context, arg1 *query.List) (*[]models.DeadProductEvent, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dead", arg0, arg1)
+	ret0, _ := ret[0].(*[]models.DeadProductEvent)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Dead indicates an expected call of Dead.
+func (mr *MockProductEventRepoMockRecorder) Dead(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dead", reflect.TypeOf((*MockProductEventRepo)(nil).Dead), arg0, arg1)
+}
+
+// Redeliver mocks base method.
+func (m *MockProductEventRepo) Redeliver(arg0 context.Context, arg1 int64) (*models.ProductEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", arg0, arg1)
+	ret0, _ := ret[0].(*models.ProductEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockProductEventRepoMockRecorder) Redeliver(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockProductEventRepo)(nil).Redeliver), arg0, arg1)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/roman-wb/crud-products/internal/server/handlers (interfaces: ProductHistoryRepo)
+
+// Package mock_handlers is a generated GoMock package.
+package mock_handlers
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/roman-wb/crud-products/internal/models"
+	query "github.com/roman-wb/crud-products/pkg/query"
+)
+
+// MockProductHistoryRepo is a mock of ProductHistoryRepo interface.
+type MockProductHistoryRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductHistoryRepoMockRecorder
+}
+
+// MockProductHistoryRepoMockRecorder is the mock recorder for MockProductHistoryRepo.
+type MockProductHistoryRepoMockRecorder struct {
+	mock *MockProductHistoryRepo
+}
+
+// NewMockProductHistoryRepo creates a new mock instance.
+func NewMockProductHistoryRepo(ctrl *gomock.Controller) *MockProductHistoryRepo {
+	mock := &MockProductHistoryRepo{ctrl: ctrl}
+	mock.recorder = &MockProductHistoryRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductHistoryRepo) EXPECT() *MockProductHistoryRepoMockRecorder {
+	return m.recorder
+}
+
+// Changes mocks base method.
+func (m *MockProductHistoryRepo) Changes(arg0 context.Context, arg1, arg2 time.Time, arg3 *query.List) (*[]models.ProductAudit, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Changes", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*[]models.ProductAudit)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Changes indicates an expected call of Changes.
+func (mr *MockProductHistoryRepoMockRecorder) Changes(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Changes", reflect.TypeOf((*MockProductHistoryRepo)(nil).Changes), arg0, arg1, arg2, arg3)
+}
+
+// History mocks base method.
+func (m *MockProductHistoryRepo) History(arg0 context.Context, arg1 int, arg2 *query.List) (*[]models.ProductAudit, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*[]models.ProductAudit)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// History indicates an expected call of History.
+func (mr *MockProductHistoryRepoMockRecorder) History(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockProductHistoryRepo)(nil).History), arg0, arg1, arg2)
+}
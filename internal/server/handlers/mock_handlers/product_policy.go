@@ -0,0 +1,63 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/roman-wb/crud-products/internal/server/handlers (interfaces: ProductPolicy)
+
+// Package mock_handlers is a generated GoMock package.
+package mock_handlers
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockProductPolicy is a mock of ProductPolicy interface.
+type MockProductPolicy struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductPolicyMockRecorder
+}
+
+// MockProductPolicyMockRecorder is the mock recorder for MockProductPolicy.
+type MockProductPolicyMockRecorder struct {
+	mock *MockProductPolicy
+}
+
+// NewMockProductPolicy creates a new mock instance.
+func NewMockProductPolicy(ctrl *gomock.Controller) *MockProductPolicy {
+	mock := &MockProductPolicy{ctrl: ctrl}
+	mock.recorder = &MockProductPolicyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductPolicy) EXPECT() *MockProductPolicyMockRecorder {
+	return m.recorder
+}
+
+// AllowsRoute mocks base method.
+func (m *MockProductPolicy) AllowsRoute(arg0 context.Context, arg1 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowsRoute", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AllowsRoute indicates an expected call of AllowsRoute.
+func (mr *MockProductPolicyMockRecorder) AllowsRoute(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowsRoute", reflect.TypeOf((*MockProductPolicy)(nil).AllowsRoute), arg0, arg1)
+}
+
+// DeniedFields mocks base method.
+func (m *MockProductPolicy) DeniedFields(arg0 context.Context, arg1 string, arg2 []string) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeniedFields", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// DeniedFields indicates an expected call of DeniedFields.
+func (mr *MockProductPolicyMockRecorder) DeniedFields(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeniedFields", reflect.TypeOf((*MockProductPolicy)(nil).DeniedFields), arg0, arg1, arg2)
+}
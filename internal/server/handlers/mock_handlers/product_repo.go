@@ -7,9 +7,11 @@ package mock_handlers
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/roman-wb/crud-products/internal/models"
+	query "github.com/roman-wb/crud-products/pkg/query"
 )
 
 // MockProductRepo is a mock of ProductRepo interface.
@@ -36,18 +38,80 @@ func (m *MockProductRepo) EXPECT() *MockProductRepoMockRecorder {
 }
 
 // All mocks base method.
-func (m *MockProductRepo) All(arg0 context.Context) (*[]models.Product, error) {
+func (m *MockProductRepo) All(arg0 context.Context, arg1 *query.List) (*[]models.Product, int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "All", arg0)
+	ret := m.ctrl.Call(m, "All", arg0, arg1)
+	ret0, _ := ret[0].(*[]models.Product)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// All indicates an expected call of All.
+func (mr *MockProductRepoMockRecorder) All(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "All", reflect.TypeOf((*MockProductRepo)(nil).All), arg0, arg1)
+}
+
+// AllAfter mocks base method.
+func (m *MockProductRepo) AllAfter(arg0 context.Context, arg1 *query.List) (*[]models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllAfter", arg0, arg1)
 	ret0, _ := ret[0].(*[]models.Product)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// All indicates an expected call of All.
-func (mr *MockProductRepoMockRecorder) All(arg0 interface{}) *gomock.Call {
+// AllAfter indicates an expected call of AllAfter.
+func (mr *MockProductRepoMockRecorder) AllAfter(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "All", reflect.TypeOf((*MockProductRepo)(nil).All), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllAfter", reflect.TypeOf((*MockProductRepo)(nil).AllAfter), arg0, arg1)
+}
+
+// AllAsOf mocks base method.
+func (m *MockProductRepo) AllAsOf(arg0 context.Context, arg1 time.Time, arg2 *query.List) (*[]models.Product, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllAsOf", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*[]models.Product)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AllAsOf indicates an expected call of AllAsOf.
+func (mr *MockProductRepoMockRecorder) AllAsOf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllAsOf", reflect.TypeOf((*MockProductRepo)(nil).AllAsOf), arg0, arg1, arg2)
+}
+
+// BatchAtomic mocks base method.
+func (m *MockProductRepo) BatchAtomic(arg0 context.Context, arg1 []models.ProductOperation) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchAtomic", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchAtomic indicates an expected call of BatchAtomic.
+func (mr *MockProductRepoMockRecorder) BatchAtomic(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchAtomic", reflect.TypeOf((*MockProductRepo)(nil).BatchAtomic), arg0, arg1)
+}
+
+// BatchPartial mocks base method.
+func (m *MockProductRepo) BatchPartial(arg0 context.Context, arg1 []models.ProductOperation) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchPartial", arg0, arg1)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchPartial indicates an expected call of BatchPartial.
+func (mr *MockProductRepoMockRecorder) BatchPartial(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchPartial", reflect.TypeOf((*MockProductRepo)(nil).BatchPartial), arg0, arg1)
 }
 
 // Create mocks base method.
@@ -65,17 +129,17 @@ func (mr *MockProductRepoMockRecorder) Create(arg0, arg1 interface{}) *gomock.Ca
 }
 
 // Destroy mocks base method.
-func (m *MockProductRepo) Destroy(arg0 context.Context, arg1 int) error {
+func (m *MockProductRepo) Destroy(arg0 context.Context, arg1, arg2 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Destroy", arg0, arg1)
+	ret := m.ctrl.Call(m, "Destroy", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Destroy indicates an expected call of Destroy.
-func (mr *MockProductRepoMockRecorder) Destroy(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockProductRepoMockRecorder) Destroy(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Destroy", reflect.TypeOf((*MockProductRepo)(nil).Destroy), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Destroy", reflect.TypeOf((*MockProductRepo)(nil).Destroy), arg0, arg1, arg2)
 }
 
 // Find mocks base method.
@@ -93,6 +157,81 @@ func (mr *MockProductRepoMockRecorder) Find(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockProductRepo)(nil).Find), arg0, arg1)
 }
 
+// FindAsOf mocks base method.
+func (m *MockProductRepo) FindAsOf(arg0 context.Context, arg1 int, arg2 time.Time) (*models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAsOf", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAsOf indicates an expected call of FindAsOf.
+func (mr *MockProductRepoMockRecorder) FindAsOf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAsOf", reflect.TypeOf((*MockProductRepo)(nil).FindAsOf), arg0, arg1, arg2)
+}
+
+// FindByIds mocks base method.
+func (m *MockProductRepo) FindByIds(arg0 context.Context, arg1 []int) (*[]models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByIds", arg0, arg1)
+	ret0, _ := ret[0].(*[]models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByIds indicates an expected call of FindByIds.
+func (mr *MockProductRepoMockRecorder) FindByIds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByIds", reflect.TypeOf((*MockProductRepo)(nil).FindByIds), arg0, arg1)
+}
+
+// Purge mocks base method.
+func (m *MockProductRepo) Purge(arg0 context.Context, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockProductRepoMockRecorder) Purge(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockProductRepo)(nil).Purge), arg0, arg1)
+}
+
+// Restore mocks base method.
+func (m *MockProductRepo) Restore(arg0 context.Context, arg1 int) (*models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", arg0, arg1)
+	ret0, _ := ret[0].(*models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockProductRepoMockRecorder) Restore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockProductRepo)(nil).Restore), arg0, arg1)
+}
+
+// Trash mocks base method.
+func (m *MockProductRepo) Trash(arg0 context.Context, arg1 *query.List) (*[]models.Product, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trash", arg0, arg1)
+	ret0, _ := ret[0].(*[]models.Product)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Trash indicates an expected call of Trash.
+func (mr *MockProductRepoMockRecorder) Trash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trash", reflect.TypeOf((*MockProductRepo)(nil).Trash), arg0, arg1)
+}
+
 // Update mocks base method.
 func (m *MockProductRepo) Update(arg0 context.Context, arg1 *models.Product) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,53 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/roman-wb/crud-products/internal/server/handlers (interfaces: ProductSearchRepo)
+
+// Package mock_handlers is a generated GoMock package.
+package mock_handlers
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/roman-wb/crud-products/internal/models"
+	query "github.com/roman-wb/crud-products/pkg/query"
+)
+
+// MockProductSearchRepo is a mock of ProductSearchRepo interface.
+type MockProductSearchRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductSearchRepoMockRecorder
+}
+
+// MockProductSearchRepoMockRecorder is the mock recorder for MockProductSearchRepo.
+type MockProductSearchRepoMockRecorder struct {
+	mock *MockProductSearchRepo
+}
+
+// NewMockProductSearchRepo creates a new mock instance.
+func NewMockProductSearchRepo(ctrl *gomock.Controller) *MockProductSearchRepo {
+	mock := &MockProductSearchRepo{ctrl: ctrl}
+	mock.recorder = &MockProductSearchRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductSearchRepo) EXPECT() *MockProductSearchRepoMockRecorder {
+	return m.recorder
+}
+
+// Search mocks base method.
+func (m *MockProductSearchRepo) Search(arg0 context.Context, arg1 string, arg2 *query.List) (*[]models.ProductSearchResult, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*[]models.ProductSearchResult)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockProductSearchRepoMockRecorder) Search(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockProductSearchRepo)(nil).Search), arg0, arg1, arg2)
+}
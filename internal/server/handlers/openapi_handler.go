@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/roman-wb/crud-products/internal/openapi"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+const ContentTypeHTML = "text/html; charset=utf-8"
+
+func OpenAPIHandler(res http.ResponseWriter, req *http.Request) {
+	utils.ResponseOK(res, json.RawMessage(openapi.Spec))
+}
+
+func DocsHandler(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set(utils.HeaderContentType, ContentTypeHTML)
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(openapi.Docs)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/openapi"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_OpenAPIHandler(t *testing.T) {
+	res := httptest.NewRecorder()
+
+	OpenAPIHandler(res, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.JSONEq(t, string(openapi.Spec), utils.BodyToString(res.Body))
+}
+
+func Test_DocsHandler(t *testing.T) {
+	res := httptest.NewRecorder()
+
+	DocsHandler(res, httptest.NewRequest("GET", "/docs", nil))
+
+	require.Equal(t, ContentTypeHTML, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Contains(t, utils.BodyToString(res.Body), `url: "/openapi.json"`)
+}
+
+// Test_OpenAPI_Schemas fails when a JSON field of a model is added, removed
+// or changes type without the document. Response schemas also require every
+// field without omitempty and nullable pointers.
+func Test_OpenAPI_Schemas(t *testing.T) {
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		schema   string
+		model    interface{}
+		response bool
+	}{
+		{schema: "Product", model: models.Product{}, response: true},
+		{schema: "ProductInput", model: models.ProductParams{}},
+		{schema: "ProductPatch", model: models.ProductParams{}},
+		{schema: "JSONPatchOperation"}, // RFC 6902, parsed by json-patch
+		{schema: "ProductSearchResult", model: models.ProductSearchResult{}, response: true},
+		{schema: "FieldChange", model: models.FieldChange{}, response: true},
+		{schema: "ProductAudit", model: models.ProductAudit{}, response: true},
+		{schema: "ProductEvent", model: models.ProductEvent{}, response: true},
+		{schema: "DeadProductEvent", model: models.DeadProductEvent{}, response: true},
+		{schema: "BatchRequest", model: batchRequest{}},
+		{schema: "BatchOperation", model: batchOperation{}},
+		{schema: "BatchResult", model: BatchResult{}, response: true},
+		{schema: "BatchResponse", model: BatchResponse{}, response: true},
+		{schema: "WebhookSubscription", model: models.WebhookSubscription{}, response: true},
+		{schema: "WebhookSubscriptionInput", model: models.WebhookSubscriptionParams{}},
+		{schema: "WebhookDelivery", model: models.WebhookDelivery{}, response: true},
+		{schema: "APIKey", model: models.APIKey{}, response: true},
+		{schema: "APIKeyInput", model: models.APIKeyParams{}},
+		{schema: "Meta", model: query.Meta{}, response: true},
+		{schema: "CursorMeta", model: query.CursorMeta{}, response: true},
+		{schema: "Health", model: ResponseHealth{}, response: true},
+		{schema: "Problem", model: utils.Problem{}, response: true},
+		{schema: "FieldError", model: utils.FieldError{}, response: true},
+	}
+
+	var names []string
+	for _, tc := range testCases {
+		names = append(names, tc.schema)
+	}
+	var documented []string
+	for name := range doc.Components.Schemas {
+		documented = append(documented, name)
+	}
+	sort.Strings(names)
+	sort.Strings(documented)
+	require.Equal(t, names, documented)
+
+	for _, tc := range testCases {
+		tc := tc
+		if tc.model == nil {
+			continue
+		}
+		t.Run(tc.schema, func(t *testing.T) {
+			t.Parallel()
+
+			schema := doc.Components.Schemas[tc.schema]
+			fields := jsonFields(reflect.TypeOf(tc.model))
+
+			var want, got []string
+			for name := range fields {
+				want = append(want, name)
+			}
+			for name := range schema.Properties {
+				got = append(got, name)
+			}
+			sort.Strings(want)
+			sort.Strings(got)
+			require.Equal(t, want, got, "properties")
+
+			var required []string
+			for name, field := range fields {
+				property := schema.Properties[name]
+				typ := openAPIType(field.Type)
+				switch {
+				case property.Ref != "" || len(property.AllOf) > 0:
+					require.Equal(t, "object", typ, name)
+				case typ != "":
+					require.Equal(t, typ, property.Type, name)
+				}
+
+				if !tc.response {
+					continue
+				}
+				if !field.omitEmpty {
+					required = append(required, name)
+				}
+				if field.Type.Kind() == reflect.Ptr && !field.omitEmpty {
+					require.True(t, property.Nullable, "%s must be nullable", name)
+				}
+			}
+			if tc.response {
+				sort.Strings(required)
+				gotRequired := append([]string(nil), schema.Required...)
+				sort.Strings(gotRequired)
+				require.Equal(t, required, gotRequired, "required")
+			}
+		})
+	}
+}
+
+func Test_OpenAPI_Refs(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &doc))
+
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if ref, ok := value["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+				var target interface{} = doc
+				for _, part := range parts {
+					object, _ := target.(map[string]interface{})
+					target = object[part]
+				}
+				require.NotNil(t, target, "unresolved %s", ref)
+			}
+			for _, item := range value {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+}
+
+type jsonField struct {
+	reflect.Type
+	omitEmpty bool
+}
+
+// jsonFields returns fields of struct type by JSON name, embedded structs are
+// flattened like encoding/json does
+func jsonFields(typ reflect.Type) map[string]jsonField {
+	fields := map[string]jsonField{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			for name, embedded := range jsonFields(field.Type) {
+				fields[name] = embedded
+			}
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = jsonField{
+			Type:      field.Type,
+			omitEmpty: len(parts) > 1 && parts[1] == "omitempty",
+		}
+	}
+	return fields
+}
+
+// openAPIType returns the OpenAPI type of Go type, empty for any value
+func openAPIType(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return ""
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+)
+
+const BatchModeAtomic = "atomic"
+const BatchModePartial = "partial"
+const BatchMaxOperations = 1000
+
+const MessageBatchMode = "must be atomic or partial"
+const MessageBatchSize = "must have 1-1000 operations"
+const MessageBatchOp = "must be create, update or delete"
+const MessageBatchID = "must be a positive integer"
+
+// batchOpField is the field of errors of the whole operation
+const batchOpField = "op"
+
+// batchOpRoutes are names of routes doing the same write as the operation
+var batchOpRoutes = map[string]string{
+	models.ProductOpCreate: "products.create",
+	models.ProductOpUpdate: "products.update",
+	models.ProductOpDelete: "products.destroy",
+}
+
+type batchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []batchOperation `json:"operations"`
+}
+
+// batchOperation is an item of a batch request, version is optional and
+// checked like If-Match
+type batchOperation struct {
+	Op      string               `json:"op"`
+	Id      int                  `json:"id"`
+	Version int                  `json:"version"`
+	Data    models.ProductParams `json:"data"`
+}
+
+type BatchResult struct {
+	Index  int             `json:"index"`
+	Op     string          `json:"op"`
+	Status int             `json:"status"`
+	Data   *models.Product `json:"data,omitempty"`
+	Error  *utils.Problem  `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Data []BatchResult `json:"data"`
+}
+
+// BatchHandler creates, updates and deletes products in one transaction.
+// Atomic mode saves all operations or nothing, partial mode saves valid
+// operations and returns 207 with the result of each operation.
+func (p ProductHandler) BatchHandler(res http.ResponseWriter, req *http.Request) {
+	// Read JSON params
+	var params batchRequest
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+	if params.Mode == "" {
+		params.Mode = BatchModeAtomic
+	}
+	fieldErrors := []utils.FieldError{}
+	if params.Mode != BatchModeAtomic && params.Mode != BatchModePartial {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "mode", Code: query.CodeInvalid, Message: MessageBatchMode})
+	}
+	if len(params.Operations) == 0 || len(params.Operations) > BatchMaxOperations {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "operations", Code: query.CodeOutOfRange, Message: MessageBatchSize})
+	}
+	if len(fieldErrors) > 0 {
+		utils.ResponseBadRequest(res, req, fieldErrors)
+		return
+	}
+
+	// Validate and authorize operations
+	ops := make([]models.ProductOperation, len(params.Operations))
+	opErrors := make([][]utils.FieldError, len(params.Operations))
+	opDenied := make([][]utils.FieldError, len(params.Operations))
+	for i, item := range params.Operations {
+		ops[i], opErrors[i] = batchProductOperation(item)
+		if len(opErrors[i]) == 0 {
+			opDenied[i] = p.deniedOperation(req.Context(), item.Op)
+		}
+	}
+	if err := p.allowUnchangedFields(req.Context(), ops, opDenied); err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	if params.Mode == BatchModeAtomic {
+		p.batchAtomic(res, req, ops, opErrors, opDenied)
+	} else {
+		p.batchPartial(res, req, ops, opErrors, opDenied)
+	}
+}
+
+func (p ProductHandler) batchAtomic(res http.ResponseWriter, req *http.Request, ops []models.ProductOperation, opErrors [][]utils.FieldError, opDenied [][]utils.FieldError) {
+	// Any invalid or denied operation rejects the batch
+	fieldErrors := []utils.FieldError{}
+	for i, errors := range opErrors {
+		fieldErrors = append(fieldErrors, batchFieldErrors(i, errors)...)
+	}
+	if len(fieldErrors) > 0 {
+		utils.ResponseInvalid(res, req, fieldErrors)
+		return
+	}
+	for i, errors := range opDenied {
+		fieldErrors = append(fieldErrors, batchFieldErrors(i, errors)...)
+	}
+	if len(fieldErrors) > 0 {
+		utils.ResponseProblem(res, fieldsForbiddenProblem(req, fieldErrors))
+		return
+	}
+
+	// Save all operations
+	index, err := p.productRepo.BatchAtomic(req.Context(), ops)
+	if err != nil {
+		problem := errorProblem(p.logger, req, err)
+		if index >= 0 {
+			problem.Detail = fmt.Sprintf("operation %d failed, nothing is saved", index)
+			problem.Errors = []utils.FieldError{
+				{Field: batchField(index, ""), Code: problem.Code, Message: problem.Title},
+			}
+		}
+		utils.ResponseProblem(res, problem)
+		return
+	}
+
+	results := []BatchResult{}
+	for i := range ops {
+		results = append(results, batchResult(i, &ops[i]))
+	}
+	utils.ResponseOK(res, BatchResponse{Data: results})
+}
+
+func (p ProductHandler) batchPartial(res http.ResponseWriter, req *http.Request, ops []models.ProductOperation, opErrors [][]utils.FieldError, opDenied [][]utils.FieldError) {
+	// Save valid and allowed operations only
+	valid := []models.ProductOperation{}
+	for i, errors := range opErrors {
+		if len(errors) == 0 && len(opDenied[i]) == 0 {
+			valid = append(valid, ops[i])
+		}
+	}
+	var errs []error
+	if len(valid) > 0 {
+		var err error
+		errs, err = p.productRepo.BatchPartial(req.Context(), valid)
+		if err != nil {
+			responseError(p.logger, res, req, err)
+			return
+		}
+	}
+
+	results := []BatchResult{}
+	for i, errors := range opErrors {
+		if len(errors) > 0 {
+			problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+			problem.Errors = batchFieldErrors(i, errors)
+			results = append(results, BatchResult{Index: i, Op: ops[i].Op, Status: problem.Status, Error: &problem})
+			continue
+		}
+		if len(opDenied[i]) > 0 {
+			problem := fieldsForbiddenProblem(req, batchFieldErrors(i, opDenied[i]))
+			results = append(results, BatchResult{Index: i, Op: ops[i].Op, Status: problem.Status, Error: &problem})
+			continue
+		}
+
+		op := &valid[0]
+		err := errs[0]
+		valid, errs = valid[1:], errs[1:]
+		if err != nil {
+			problem := errorProblem(p.logger, req, err)
+			results = append(results, BatchResult{Index: i, Op: op.Op, Status: problem.Status, Error: &problem})
+			continue
+		}
+		results = append(results, batchResult(i, op))
+	}
+	utils.ResponseMultiStatus(res, BatchResponse{Data: results})
+}
+
+// batchProductOperation converts and validates an operation of the request
+func batchProductOperation(item batchOperation) (models.ProductOperation, []utils.FieldError) {
+	op := models.ProductOperation{
+		Op:      item.Op,
+		Product: models.Product{Id: item.Id, Version: item.Version},
+	}
+
+	switch item.Op {
+	case models.ProductOpCreate, models.ProductOpUpdate:
+		errors := []utils.FieldError{}
+		if item.Op == models.ProductOpUpdate && item.Id <= 0 {
+			errors = append(errors, utils.FieldError{Field: "id", Code: validation.CodeRequired, Message: MessageBatchID})
+		}
+		for _, err := range item.Data.ValidatePresence() {
+			err.Field = "data." + err.Field
+			errors = append(errors, err)
+		}
+		if len(errors) > 0 {
+			return op, errors
+		}
+		op.Product.Apply(item.Data)
+		for _, err := range op.Product.Validate() {
+			err.Field = "data." + err.Field
+			errors = append(errors, err)
+		}
+		return op, errors
+	case models.ProductOpDelete:
+		if item.Id <= 0 {
+			return op, []utils.FieldError{{Field: "id", Code: validation.CodeRequired, Message: MessageBatchID}}
+		}
+		return op, nil
+	default:
+		return op, []utils.FieldError{{Field: batchOpField, Code: query.CodeInvalid, Message: MessageBatchOp}}
+	}
+}
+
+// deniedOperation checks the operation like the route of the same write,
+// create and update replace the whole product so all fields are checked
+// (see allowUnchangedFields)
+func (p ProductHandler) deniedOperation(ctx context.Context, op string) []utils.FieldError {
+	if p.Policy == nil {
+		return nil
+	}
+	if !p.Policy.AllowsRoute(ctx, batchOpRoutes[op]) {
+		return []utils.FieldError{{Field: batchOpField, Code: utils.CodeForbidden, Message: MessageOpForbidden}}
+	}
+	if op == models.ProductOpDelete {
+		return nil
+	}
+	return p.deniedFields(ctx, "data.", models.ProductWritableFields)
+}
+
+// allowUnchangedFields checks denied fields of updates again with fields
+// changed from the current product, like PUT does. Current products are
+// loaded in one query only for updates with denied fields, missing products
+// keep the denial. Updates without a version are pinned to the version
+// checked, so a concurrent change fails as stale instead of being reverted.
+func (p ProductHandler) allowUnchangedFields(ctx context.Context, ops []models.ProductOperation, opDenied [][]utils.FieldError) error {
+	ids := []int{}
+	for i, op := range ops {
+		if op.Op == models.ProductOpUpdate && deniedFieldsOnly(opDenied[i]) {
+			ids = append(ids, op.Product.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	products, err := p.productRepo.FindByIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	current := map[int]models.Product{}
+	for _, product := range *products {
+		current[product.Id] = product
+	}
+	for i, op := range ops {
+		if product, ok := current[op.Product.Id]; ok && op.Op == models.ProductOpUpdate && deniedFieldsOnly(opDenied[i]) {
+			opDenied[i] = p.deniedFields(ctx, "data.", op.Product.ChangedFields(product))
+			if op.Product.Version == 0 {
+				ops[i].Product.Version = product.Version
+			}
+		}
+	}
+	return nil
+}
+
+// deniedFieldsOnly reports whether the operation is allowed but some of its
+// fields are not
+func deniedFieldsOnly(denied []utils.FieldError) bool {
+	return len(denied) > 0 && denied[0].Field != batchOpField
+}
+
+func batchResult(index int, op *models.ProductOperation) BatchResult {
+	switch op.Op {
+	case models.ProductOpCreate:
+		return BatchResult{Index: index, Op: op.Op, Status: http.StatusCreated, Data: &op.Product}
+	case models.ProductOpUpdate:
+		return BatchResult{Index: index, Op: op.Op, Status: http.StatusOK, Data: &op.Product}
+	default:
+		return BatchResult{Index: index, Op: op.Op, Status: http.StatusNoContent}
+	}
+}
+
+// batchFieldErrors prefixes field errors with the operation path
+func batchFieldErrors(index int, errors []utils.FieldError) []utils.FieldError {
+	fieldErrors := []utils.FieldError{}
+	for _, err := range errors {
+		err.Field = batchField(index, err.Field)
+		fieldErrors = append(fieldErrors, err)
+	}
+	return fieldErrors
+}
+
+func batchField(index int, field string) string {
+	if field == "" {
+		return fmt.Sprintf("operations[%d]", index)
+	}
+	return fmt.Sprintf("operations[%d].%s", index, field)
+}
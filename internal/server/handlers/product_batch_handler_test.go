@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newBatchRequest(body string) *http.Request {
+	req, _ := http.NewRequest("POST", "/products/batch", bytes.NewBufferString(body))
+	return req
+}
+
+func Test_Product_BatchHandler_Case1_InvalidRequest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		wantProblem func(req *http.Request) utils.Problem
+	}{
+		{
+			name: "invalid json",
+			body: ``,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeInvalidJSON, utils.MessageBadRequest)
+				problem.Detail = "EOF"
+				return problem
+			},
+		},
+		{
+			name: "invalid mode and no operations",
+			body: `{"mode": "some", "operations": []}`,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+				problem.Errors = []utils.FieldError{
+					{Field: "mode", Code: query.CodeInvalid, Message: MessageBatchMode},
+					{Field: "operations", Code: query.CodeOutOfRange, Message: MessageBatchSize},
+				}
+				return problem
+			},
+		},
+		{
+			name: "invalid operations",
+			body: `{"operations": [
+				{"op": "create", "data": {"name": "Name 1"}},
+				{"op": "update", "data": {"name": "", "price": 1}},
+				{"op": "delete"},
+				{"op": "upsert"}
+			]}`,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "operations[0].data.price", Code: "required", Message: models.ProductValidationPriceRequired},
+					{Field: "operations[1].id", Code: "required", Message: MessageBatchID},
+					{Field: "operations[2].id", Code: "required", Message: MessageBatchID},
+					{Field: "operations[3].op", Code: query.CodeInvalid, Message: MessageBatchOp},
+				}
+				return problem
+			},
+		},
+		{
+			name: "invalid values",
+			body: `{"operations": [
+				{"op": "update", "id": 1, "data": {"name": "", "price": -1}}
+			]}`,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "operations[0].data.name", Code: "required", Message: models.ProductValidationNameRequired},
+					{Field: "operations[0].data.price", Code: "min", Message: models.ProductValidationPriceGte},
+				}
+				return problem
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+			res := httptest.NewRecorder()
+			req := newBatchRequest(tc.body)
+
+			handler.BatchHandler(res, req)
+
+			problem := tc.wantProblem(req)
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+			require.Equal(t, problem.Status, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_Product_BatchHandler_Case2_AtomicSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		BatchAtomic(gomock.Any(), []models.ProductOperation{
+			{Op: models.ProductOpCreate, Product: models.Product{Name: "Name 3", Price: 3}},
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 2}},
+			{Op: models.ProductOpDelete, Product: models.Product{Id: 2}},
+		}).
+		DoAndReturn(func(_ context.Context, ops []models.ProductOperation) (int, error) {
+			ops[0].Product.Id = 3
+			ops[0].Product.Version = 1
+			ops[1].Product.Version = 3
+			return -1, nil
+		})
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"mode": "atomic", "operations": [
+		{"op": "create", "data": {"name": "Name 3", "price": 3}},
+		{"op": "update", "id": 1, "version": 2, "data": {"name": "Name 1", "price": 1}},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	handler.BatchHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(BatchResponse{Data: []BatchResult{
+		{Index: 0, Op: models.ProductOpCreate, Status: http.StatusCreated, Data: &models.Product{Id: 3, Name: "Name 3", Price: 3, Version: 1}},
+		{Index: 1, Op: models.ProductOpUpdate, Status: http.StatusOK, Data: &models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 3}},
+		{Index: 2, Op: models.ProductOpDelete, Status: http.StatusNoContent},
+	}}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case3_AtomicExecError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		BatchAtomic(gomock.Any(), gomock.Any()).
+		Return(1, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"operations": [
+		{"op": "delete", "id": 1},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	handler.BatchHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound)
+	problem.Detail = "operation 1 failed, nothing is saved"
+	problem.Errors = []utils.FieldError{
+		{Field: "operations[1]", Code: utils.CodeNotFound, Message: utils.MessageNotFound},
+	}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case4_PartialMultiStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		BatchPartial(gomock.Any(), []models.ProductOperation{
+			{Op: models.ProductOpCreate, Product: models.Product{Name: "Name 3", Price: 3}},
+			{Op: models.ProductOpDelete, Product: models.Product{Id: 2}},
+		}).
+		DoAndReturn(func(_ context.Context, ops []models.ProductOperation) ([]error, error) {
+			ops[0].Product.Id = 3
+			ops[0].Product.Version = 1
+			return []error{nil, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows}}, nil
+		})
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"mode": "partial", "operations": [
+		{"op": "create", "data": {"name": "Name 3", "price": 3}},
+		{"op": "create", "data": {"name": "Name 4"}},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	handler.BatchHandler(res, req)
+
+	invalid := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+	invalid.Errors = []utils.FieldError{
+		{Field: "operations[1].data.price", Code: "required", Message: models.ProductValidationPriceRequired},
+	}
+	notFound := utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound)
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusMultiStatus, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(BatchResponse{Data: []BatchResult{
+		{Index: 0, Op: models.ProductOpCreate, Status: http.StatusCreated, Data: &models.Product{Id: 3, Name: "Name 3", Price: 3, Version: 1}},
+		{Index: 1, Op: models.ProductOpCreate, Status: http.StatusUnprocessableEntity, Error: &invalid},
+		{Index: 2, Op: models.ProductOpDelete, Status: http.StatusNotFound, Error: &notFound},
+	}}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case5_PartialExecError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		BatchPartial(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("some error..."))
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"mode": "partial", "operations": [{"op": "delete", "id": 2}]}`)
+
+	handler.BatchHandler(res, req)
+
+	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+	), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case6_AtomicForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.create").Return(true)
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.destroy").Return(false)
+	policy.
+		EXPECT().
+		DeniedFields(gomock.Any(), ResourceProducts, models.ProductWritableFields).
+		Return([]string{"price"})
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"operations": [
+		{"op": "create", "data": {"name": "Name 3", "price": 3}},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	handler.BatchHandler(res, req)
+
+	problem := fieldsForbiddenProblem(req, []utils.FieldError{
+		{Field: "operations[0].data.price", Code: utils.CodeForbidden, Message: MessageFieldForbidden},
+		{Field: "operations[1].op", Code: utils.CodeForbidden, Message: MessageOpForbidden},
+	})
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusForbidden, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case7_PartialForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.create").Return(false)
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.destroy").Return(true)
+	mock.
+		EXPECT().
+		BatchPartial(gomock.Any(), []models.ProductOperation{
+			{Op: models.ProductOpDelete, Product: models.Product{Id: 2}},
+		}).
+		Return([]error{nil}, nil)
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"mode": "partial", "operations": [
+		{"op": "create", "data": {"name": "Name 3", "price": 3}},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	handler.BatchHandler(res, req)
+
+	forbidden := fieldsForbiddenProblem(req, []utils.FieldError{
+		{Field: "operations[0].op", Code: utils.CodeForbidden, Message: MessageOpForbidden},
+	})
+
+	require.Equal(t, http.StatusMultiStatus, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(BatchResponse{Data: []BatchResult{
+		{Index: 0, Op: models.ProductOpCreate, Status: http.StatusForbidden, Error: &forbidden},
+		{Index: 1, Op: models.ProductOpDelete, Status: http.StatusNoContent},
+	}}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case8_UpdateChangedFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	// Roles may change names only
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.update").Return(true).Times(2)
+	policy.
+		EXPECT().
+		DeniedFields(gomock.Any(), ResourceProducts, models.ProductWritableFields).
+		Return([]string{"price"}).
+		Times(2)
+	mock.
+		EXPECT().
+		FindByIds(gomock.Any(), []int{1, 2}).
+		Return(&[]models.Product{{Id: 1, Name: "Name 1", Price: 1}, {Id: 2, Name: "Name 2", Price: 2}}, nil)
+	policy.EXPECT().DeniedFields(gomock.Any(), ResourceProducts, []string{"name"}).Return([]string{})
+	policy.EXPECT().DeniedFields(gomock.Any(), ResourceProducts, []string{"name", "price"}).Return([]string{"price"})
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"operations": [
+		{"op": "update", "id": 1, "data": {"name": "New 1", "price": 1}},
+		{"op": "update", "id": 2, "data": {"name": "New 2", "price": 5}}
+	]}`)
+
+	handler.BatchHandler(res, req)
+
+	// The unchanged price is allowed like with PUT
+	problem := fieldsForbiddenProblem(req, []utils.FieldError{
+		{Field: "operations[1].data.price", Code: utils.CodeForbidden, Message: MessageFieldForbidden},
+	})
+
+	require.Equal(t, http.StatusForbidden, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_BatchHandler_Case9_UpdatePinsVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.update").Return(true)
+	policy.
+		EXPECT().
+		DeniedFields(gomock.Any(), ResourceProducts, models.ProductWritableFields).
+		Return([]string{"price"})
+	mock.
+		EXPECT().
+		FindByIds(gomock.Any(), []int{1}).
+		Return(&[]models.Product{{Id: 1, Name: "Name 1", Price: 1, Version: 3}}, nil)
+	policy.EXPECT().DeniedFields(gomock.Any(), ResourceProducts, []string{"name"}).Return([]string{})
+	// The price is allowed as unchanged in version 3 only
+	mock.
+		EXPECT().
+		BatchAtomic(gomock.Any(), []models.ProductOperation{
+			{Op: models.ProductOpUpdate, Product: models.Product{Id: 1, Name: "New 1", Price: 1, Version: 3}},
+		}).
+		Return(0, repos.ErrStale)
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"operations": [{"op": "update", "id": 1, "version": 0, "data": {"name": "New 1", "price": 1}}]}`)
+
+	handler.BatchHandler(res, req)
+
+	require.Equal(t, http.StatusConflict, res.Result().StatusCode)
+}
+
+func Test_Product_BatchHandler_Case10_FindByIdsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	policy.EXPECT().AllowsRoute(gomock.Any(), "products.update").Return(true)
+	policy.
+		EXPECT().
+		DeniedFields(gomock.Any(), ResourceProducts, models.ProductWritableFields).
+		Return([]string{"price"})
+	mock.
+		EXPECT().
+		FindByIds(gomock.Any(), []int{1}).
+		Return(nil, errors.New("some error..."))
+
+	res := httptest.NewRecorder()
+	req := newBatchRequest(`{"operations": [{"op": "update", "id": 1, "data": {"name": "New 1", "price": 1}}]}`)
+
+	handler.BatchHandler(res, req)
+
+	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
+}
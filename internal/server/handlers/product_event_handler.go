@@ -0,0 +1,71 @@
+//go:generate mockgen -destination mock_handlers/product_event_repo.go . ProductEventRepo
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+type ProductEventRepo interface {
+	Dead(ctx context.Context, list *query.List) (*[]models.DeadProductEvent, int, error)
+	Redeliver(ctx context.Context, id int64) (*models.ProductEvent, error)
+}
+
+type ProductEventHandler struct {
+	logger           *zap.Logger
+	productEventRepo ProductEventRepo
+}
+
+func NewProductEventHandler(logger *zap.Logger, productEventRepo ProductEventRepo) *ProductEventHandler {
+	return &ProductEventHandler{
+		logger:           logger,
+		productEventRepo: productEventRepo,
+	}
+}
+
+// DeadHandler returns page of outbox events which are not published after
+// all attempts, the total of meta is the number of dead events
+func (p ProductEventHandler) DeadHandler(res http.ResponseWriter, req *http.Request) {
+	list, errs := query.Parse(req.URL.Query(), query.Schema{})
+	if len(errs) > 0 {
+		utils.ResponseBadRequest(res, req, queryErrors(errs))
+		return
+	}
+
+	events, total, err := p.productEventRepo.Dead(req.Context(), list)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: events,
+		Meta: list.Meta(total, nil),
+	})
+}
+
+// RedeliverHandler schedules the dead event to be published again with all
+// attempts, it's published in background (202)
+func (p ProductEventHandler) RedeliverHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+	if err != nil {
+		responseError(p.logger, res, req, errInvalidID)
+		return
+	}
+
+	event, err := p.productEventRepo.Redeliver(req.Context(), id)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseAccepted(res, event)
+}
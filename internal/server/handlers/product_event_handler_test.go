@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewProductEventHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	repo := repos.NewProductEventRepo(&pgxpool.Pool{})
+
+	handler := NewProductEventHandler(logger, repo)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, repo, handler.productEventRepo)
+}
+
+func newDeadProductEvent() models.DeadProductEvent {
+	lastError := "connection refused"
+	return models.DeadProductEvent{
+		ProductEvent: models.ProductEvent{
+			Id:        7,
+			Type:      models.ProductEventUpdated,
+			ProductId: 1,
+			Version:   2,
+			Data:      &models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 2},
+			RequestId: "request-1",
+			CreatedAt: time.Date(2021, 8, 3, 10, 0, 0, 0, time.UTC),
+		},
+		LastError: &lastError,
+		DeadAt:    time.Date(2021, 8, 4, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_ProductEvent_DeadHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductEventRepo(ctrl)
+	handler := NewProductEventHandler(zaptest.NewLogger(t), mock)
+
+	events := []models.DeadProductEvent{newDeadProductEvent()}
+	mock.
+		EXPECT().
+		Dead(gomock.Any(), &query.List{Limit: 20}).
+		Return(&events, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/events/dead", nil)
+
+	handler.DeadHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, `{"data":[{"id":7,"type":"product.updated","product_id":1,"version":2,`+
+		`"data":{"id":1,"name":"Name 1","price":1},"request_id":"request-1","created_at":"2021-08-03T10:00:00Z",`+
+		`"last_error":"connection refused","dead_at":"2021-08-04T10:00:00Z"}],`+
+		`"meta":{"total":1,"page":1,"per_page":20,"total_pages":1,"next_cursor":null}}`, utils.BodyToString(res.Body))
+}
+
+func Test_ProductEvent_RedeliverHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductEventRepo(ctrl)
+	handler := NewProductEventHandler(zaptest.NewLogger(t), mock)
+
+	event := newDeadProductEvent().ProductEvent
+	mock.EXPECT().Redeliver(gomock.Any(), int64(7)).Return(&event, nil)
+	mock.EXPECT().Redeliver(gomock.Any(), int64(8)).Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")})
+
+	testCases := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "dead", id: "7", wantStatus: http.StatusAccepted},
+		{name: "not dead", id: "8", wantStatus: http.StatusNotFound},
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/admin/events/"+tc.id+"/redeliver", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			handler.RedeliverHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			if tc.wantStatus == http.StatusAccepted {
+				require.Equal(t, utils.DataToJson(event), utils.BodyToString(res.Body))
+			}
+		})
+	}
+}
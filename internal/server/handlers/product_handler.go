@@ -6,25 +6,55 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
 	"github.com/roman-wb/crud-products/pkg/utils"
 	"go.uber.org/zap"
 )
 
 type ProductRepo interface {
-	All(ctx context.Context) (*[]models.Product, error)
+	All(ctx context.Context, list *query.List) (*[]models.Product, int, error)
+	AllAfter(ctx context.Context, list *query.List) (*[]models.Product, error)
+	AllAsOf(ctx context.Context, at time.Time, list *query.List) (*[]models.Product, int, error)
 	Find(ctx context.Context, id int) (*models.Product, error)
+	FindByIds(ctx context.Context, ids []int) (*[]models.Product, error)
+	FindAsOf(ctx context.Context, id int, at time.Time) (*models.Product, error)
 	Create(ctx context.Context, product *models.Product) error
 	Update(ctx context.Context, product *models.Product) error
-	Destroy(ctx context.Context, id int) error
+	Destroy(ctx context.Context, id int, version int) error
+	Trash(ctx context.Context, list *query.List) (*[]models.Product, int, error)
+	Restore(ctx context.Context, id int) (*models.Product, error)
+	Purge(ctx context.Context, id int) error
+	BatchAtomic(ctx context.Context, ops []models.ProductOperation) (int, error)
+	BatchPartial(ctx context.Context, ops []models.ProductOperation) ([]error, error)
+}
+
+const ParamAsOf = "as_of"
+
+const MessageAsOfAfter = "cannot be combined with as_of"
+
+type ResponseList struct {
+	Data interface{} `json:"data"`
+	Meta interface{} `json:"meta"`
 }
 
 type ProductHandler struct {
 	logger      *zap.Logger
 	productRepo ProductRepo
+
+	// RequireIfMatch rejects writes without If-Match header (428)
+	RequireIfMatch bool
+
+	// PurgeEnabled allows to permanently delete trashed products
+	PurgeEnabled bool
+
+	// Policy denies changes of product fields (403), nil allows all
+	Policy ProductPolicy
 }
 
 func NewProductHandler(logger *zap.Logger, productRepo ProductRepo) *ProductHandler {
@@ -35,23 +65,91 @@ func NewProductHandler(logger *zap.Logger, productRepo ProductRepo) *ProductHand
 }
 
 func (p ProductHandler) IndexHandler(res http.ResponseWriter, req *http.Request) {
-	// Get all products
-	products, err := p.productRepo.All(context.Background())
+	// Parse pagination, sort and filters
+	list, errs := query.Parse(req.URL.Query(), models.ProductQuerySchema)
+	asOf, asOfErrors := parseAsOf(req.URL.Query())
+	fieldErrors := append(queryErrors(errs), asOfErrors...)
+	if len(fieldErrors) == 0 && asOf != nil && list.After != nil {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: query.ParamAfter, Code: query.CodeConflict, Message: MessageAsOfAfter})
+	}
+	if len(fieldErrors) > 0 {
+		utils.ResponseBadRequest(res, req, fieldErrors)
+		return
+	}
+
+	// Get page of products as they were at the time
+	if asOf != nil {
+		products, total, err := p.productRepo.AllAsOf(req.Context(), *asOf, list)
+		if err != nil {
+			responseError(p.logger, res, req, err)
+			return
+		}
+
+		utils.ResponseOK(res, ResponseList{
+			Data: products,
+			Meta: list.Meta(total, nil),
+		})
+		return
+	}
+
+	// Get page of products after the cursor
+	if list.After != nil {
+		products, err := p.productRepo.AllAfter(req.Context(), list)
+		if err != nil {
+			responseError(p.logger, res, req, err)
+			return
+		}
+
+		utils.ResponseOK(res, ResponseList{
+			Data: products,
+			Meta: list.CursorMeta(p.nextCursor(list, *products, len(*products) == list.Limit)),
+		})
+		return
+	}
+
+	// Get page of products
+	products, total, err := p.productRepo.All(req.Context(), list)
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseInternalError(res)
+		responseError(p.logger, res, req, err)
 		return
 	}
 
-	utils.ResponseOK(res, products)
+	hasNext := len(*products) == list.Limit && list.Offset+list.Limit < total
+	utils.ResponseOK(res, ResponseList{
+		Data: products,
+		Meta: list.Meta(total, p.nextCursor(list, *products, hasNext)),
+	})
 }
 
 func (p ProductHandler) ShowHandler(res http.ResponseWriter, req *http.Request) {
+	// Load product as it was at the time, past versions are not cached
+	asOf, fieldErrors := parseAsOf(req.URL.Query())
+	if len(fieldErrors) > 0 {
+		utils.ResponseBadRequest(res, req, fieldErrors)
+		return
+	}
+	if asOf != nil {
+		product, err := p.loadProductAsOf(req, *asOf)
+		if err != nil {
+			responseError(p.logger, res, req, err)
+			return
+		}
+
+		utils.ResponseOK(res, product)
+		return
+	}
+
 	// Load product
 	product, err := p.loadProduct(req)
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseNotFound(res)
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	// Check client cache
+	setETag(res, product)
+	if header := req.Header.Get(utils.HeaderIfNoneMatch); header != "" && matchETag(header, productETag(product), true) {
+		utils.ResponseNotModified(res)
 		return
 	}
 
@@ -66,102 +164,153 @@ func (p ProductHandler) CreateHandler(res http.ResponseWriter, req *http.Request
 	}
 	decoder := json.NewDecoder(req.Body)
 	if err := decoder.Decode(&params); err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseInternalError(res)
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+
+	// Creating sets all fields
+	if errors := p.deniedFields(req.Context(), "", models.ProductWritableFields); len(errors) > 0 {
+		utils.ResponseProblem(res, fieldsForbiddenProblem(req, errors))
 		return
 	}
 
 	// Fill and validate model
 	product := models.Product{}
 	product.Fill(params)
-	if messages := product.Validate(); len(messages) > 0 {
-		utils.ResponseInvalid(res, messages)
+	if errors := product.Validate(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
 		return
 	}
 
 	// Create product in repo
-	err := p.productRepo.Create(context.Background(), &product)
+	err := p.productRepo.Create(req.Context(), &product)
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseInternalError(res)
+		responseError(p.logger, res, req, err)
 		return
 	}
 
+	setETag(res, &product)
 	utils.ResponseCreate(res, product)
 }
 
 func (p ProductHandler) UpdateHandler(res http.ResponseWriter, req *http.Request) {
 	// Load product
 	product, err := p.loadProduct(req)
+	if err == nil {
+		err = p.checkIfMatch(req, product)
+	}
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseNotFound(res)
+		responseError(p.logger, res, req, err)
 		return
 	}
 
-	// Read JSON params to safe anonymous struct (mass assignment)
-	var params struct {
-		Name  string  `json:"name"`
-		Price float64 `json:"price"`
-	}
+	// Read JSON params to safe struct (mass assignment), PUT replaces the
+	// whole product so all fields are required
+	var params models.ProductParams
 	decoder := json.NewDecoder(req.Body)
 	if err := decoder.Decode(&params); err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseInternalError(res)
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+	if errors := params.ValidatePresence(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
 		return
 	}
 
-	// Fill and validate model
-	product.Fill(params)
-	if messages := product.Validate(); len(messages) > 0 {
-		utils.ResponseInvalid(res, messages)
+	// Apply params, only changed fields are authorized
+	current := *product
+	product.Apply(params)
+	if errors := p.deniedFields(req.Context(), "", product.ChangedFields(current)); len(errors) > 0 {
+		utils.ResponseProblem(res, fieldsForbiddenProblem(req, errors))
+		return
+	}
+
+	// Validate model
+	if errors := product.Validate(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
 		return
 	}
 
 	// Update product in repo
-	err = p.productRepo.Update(context.Background(), product)
+	err = p.productRepo.Update(req.Context(), product)
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseInternalError(res)
+		responseError(p.logger, res, req, err)
 		return
 	}
 
+	setETag(res, product)
 	utils.ResponseOK(res, product)
 }
 
 func (p ProductHandler) DestroyHandler(res http.ResponseWriter, req *http.Request) {
 	// Load product
 	product, err := p.loadProduct(req)
+	if err == nil {
+		err = p.checkIfMatch(req, product)
+	}
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseNotFound(res)
+		responseError(p.logger, res, req, err)
 		return
 	}
 
 	// Destroy product in repo
-	err = p.productRepo.Destroy(context.Background(), product.Id)
+	err = p.productRepo.Destroy(req.Context(), product.Id, product.Version)
 	if err != nil {
-		p.logger.Sugar().Error(err)
-		utils.ResponseInternalError(res)
+		responseError(p.logger, res, req, err)
 		return
 	}
 
 	utils.ResponseNoContent(res)
 }
 
+// nextCursor points after the last product of the page, nil if there are
+// no more products
+func (p ProductHandler) nextCursor(list *query.List, products []models.Product, hasNext bool) *string {
+	if !hasNext || len(products) == 0 {
+		return nil
+	}
+	cursor, err := query.NewCursor(list.Sort, products[len(products)-1])
+	if err != nil {
+		p.logger.Sugar().Error(err)
+		return nil
+	}
+	return &cursor
+}
+
 func (p ProductHandler) loadProduct(req *http.Request) (*models.Product, error) {
 	// Parse query
 	query := mux.Vars(req)
 	id, err := strconv.Atoi(query["id"])
 	if err != nil {
-		return nil, err
+		return nil, errInvalidID
 	}
 
 	// Find product
-	product, err := p.productRepo.Find(context.Background(), id)
+	product, err := p.productRepo.Find(req.Context(), id)
 	if err != nil {
 		return nil, err
 	}
 
 	return product, nil
 }
+
+func (p ProductHandler) loadProductAsOf(req *http.Request, at time.Time) (*models.Product, error) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		return nil, errInvalidID
+	}
+	return p.productRepo.FindAsOf(req.Context(), id, at)
+}
+
+// parseAsOf reads the time of point-in-time reads, nil if it's missing
+func parseAsOf(values url.Values) (*time.Time, []utils.FieldError) {
+	raw := values.Get(ParamAsOf)
+	if raw == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, []utils.FieldError{{Field: ParamAsOf, Code: query.CodeInvalid, Message: MessageInvalidTime}}
+	}
+	return &at, nil
+}
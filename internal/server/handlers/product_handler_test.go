@@ -7,13 +7,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/roman-wb/crud-products/internal/models"
 	"github.com/roman-wb/crud-products/internal/repos"
 	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
 	"github.com/roman-wb/crud-products/pkg/utils"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -39,19 +42,22 @@ func Test_Product_IndexHandler_Case1_ExecError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		All(context.Background()).
-		Return(nil, errors.New("some error..."))
+		All(gomock.Any(), &query.List{
+			Sort:  []query.Sort{{Field: "id"}},
+			Limit: query.DefaultPerPage,
+		}).
+		Return(nil, 0, errors.New("some error..."))
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 
 	handler.IndexHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageInternalError,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_IndexHandler_Case2_Success(t *testing.T) {
@@ -63,7 +69,12 @@ func Test_Product_IndexHandler_Case2_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		All(context.Background()).
+		All(gomock.Any(), &query.List{
+			Sort:    []query.Sort{{Field: "price", Desc: true}, {Field: "name"}},
+			Filters: []query.Filter{{Field: "name", Op: query.OpContains, Value: "Name"}},
+			Limit:   2,
+			Offset:  2,
+		}).
 		Return(&[]models.Product{
 			{
 				Id:    1,
@@ -75,18 +86,26 @@ func Test_Product_IndexHandler_Case2_Success(t *testing.T) {
 				Name:  "Name 2",
 				Price: 200.99,
 			},
-		}, nil)
+		}, 5, nil)
 
 	res := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/", nil)
+	req, _ := http.NewRequest("GET", "/?page=2&per_page=2&sort=-price,name&name_contains=Name", nil)
 
 	handler.IndexHandler(res, req)
 
 	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	nextCursor, _ := query.NewCursor(
+		[]query.Sort{{Field: "price", Desc: true}, {Field: "name"}},
+		models.Product{Id: 2, Name: "Name 2", Price: 200.99},
+	)
+
 	require.Equal(t, http.StatusOK, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson([]models.Product{
-		{Id: 1, Name: "Name 1", Price: 100.00},
-		{Id: 2, Name: "Name 2", Price: 200.99},
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: []models.Product{
+			{Id: 1, Name: "Name 1", Price: 100.00},
+			{Id: 2, Name: "Name 2", Price: 200.99},
+		},
+		Meta: query.Meta{Total: 5, Page: 2, PerPage: 2, TotalPages: 3, NextCursor: &nextCursor},
 	}), utils.BodyToString(res.Body))
 }
 
@@ -99,8 +118,11 @@ func Test_Product_IndexHandler_Case3_Blank(t *testing.T) {
 
 	mock.
 		EXPECT().
-		All(context.Background()).
-		Return(&[]models.Product{}, nil)
+		All(gomock.Any(), &query.List{
+			Sort:  []query.Sort{{Field: "id"}},
+			Limit: query.DefaultPerPage,
+		}).
+		Return(&[]models.Product{}, 0, nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
@@ -109,7 +131,99 @@ func Test_Product_IndexHandler_Case3_Blank(t *testing.T) {
 
 	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusOK, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson([]models.Product{}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: []models.Product{},
+		Meta: query.Meta{Total: 0, Page: 1, PerPage: query.DefaultPerPage, TotalPages: 0},
+	}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_IndexHandler_Case4_InvalidQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?per_page=1000&sort=secret", nil)
+
+	handler.IndexHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{
+		{Field: "per_page", Code: query.CodeOutOfRange, Message: "must be between 1 and 100"},
+		{Field: "sort", Code: query.CodeUnknown, Message: `unknown sort field "secret"`},
+	}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_IndexHandler_Case5_AfterCursor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	sort := []query.Sort{{Field: "price", Desc: true}}
+	cursor, _ := query.NewCursor(sort, models.Product{Id: 7, Name: "Name 7", Price: 300})
+
+	mock.
+		EXPECT().
+		AllAfter(gomock.Any(), &query.List{
+			Sort:  sort,
+			Limit: 2,
+			After: &query.Cursor{Sort: sort, Values: []interface{}{300.0, 7}},
+		}).
+		Return(&[]models.Product{
+			{Id: 1, Name: "Name 1", Price: 200.99},
+			{Id: 2, Name: "Name 2", Price: 100.00},
+		}, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?limit=2&after="+cursor, nil)
+
+	handler.IndexHandler(res, req)
+
+	nextCursor, _ := query.NewCursor(sort, models.Product{Id: 2, Name: "Name 2", Price: 100.00})
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: []models.Product{
+			{Id: 1, Name: "Name 1", Price: 200.99},
+			{Id: 2, Name: "Name 2", Price: 100.00},
+		},
+		Meta: query.CursorMeta{Limit: 2, NextCursor: &nextCursor},
+	}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_IndexHandler_Case6_AfterCursorLastPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	cursor, _ := query.NewCursor([]query.Sort{{Field: "id"}}, models.Product{Id: 7})
+
+	mock.
+		EXPECT().
+		AllAfter(gomock.Any(), gomock.Any()).
+		Return(&[]models.Product{{Id: 8, Name: "Name 8", Price: 1}}, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?limit=2&after="+cursor, nil)
+
+	handler.IndexHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: []models.Product{{Id: 8, Name: "Name 8", Price: 1}},
+		Meta: query.CursorMeta{Limit: 2},
+	}), utils.BodyToString(res.Body))
 }
 
 func Test_Product_ShowHandler_Case1_ParseQueryError(t *testing.T) {
@@ -125,11 +239,12 @@ func Test_Product_ShowHandler_Case1_ParseQueryError(t *testing.T) {
 
 	handler.ShowHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
-	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageNotFound,
-	}), utils.BodyToString(res.Body))
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_ShowHandler_Case2_FindError(t *testing.T) {
@@ -141,8 +256,8 @@ func Test_Product_ShowHandler_Case2_FindError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
-		Return(nil, errors.New("some error..."))
+		Find(gomock.Any(), 1).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
@@ -150,11 +265,11 @@ func Test_Product_ShowHandler_Case2_FindError(t *testing.T) {
 
 	handler.ShowHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageNotFound,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_ShowHandler_Case3_Success(t *testing.T) {
@@ -166,7 +281,7 @@ func Test_Product_ShowHandler_Case3_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -200,11 +315,12 @@ func Test_Product_CreateHandler_Case1_ParseJsonError(t *testing.T) {
 
 	handler.CreateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
-	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageInternalError,
-	}), utils.BodyToString(res.Body))
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeInvalidJSON, utils.MessageBadRequest)
+	problem.Detail = "EOF"
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_CreateHandler_Case2_InvalidParams(t *testing.T) {
@@ -224,12 +340,15 @@ func Test_Product_CreateHandler_Case2_InvalidParams(t *testing.T) {
 
 	handler.CreateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+	problem.Errors = []utils.FieldError{
+		{Field: "name", Code: "required", Message: models.ProductValidationNameRequired},
+		{Field: "price", Code: "min", Message: models.ProductValidationPriceGte},
+	}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusUnprocessableEntity, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson([]string{
-		models.ProductValidationNameRequired,
-		models.ProductValidationPriceGte,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_CreateHandler_Case3_ExecError(t *testing.T) {
@@ -241,7 +360,7 @@ func Test_Product_CreateHandler_Case3_ExecError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Create(context.Background(), &models.Product{
+		Create(gomock.Any(), &models.Product{
 			Name:  "Name 1",
 			Price: 100.00,
 		}).
@@ -257,11 +376,11 @@ func Test_Product_CreateHandler_Case3_ExecError(t *testing.T) {
 
 	handler.CreateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageInternalError,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_CreateHandler_Case4_Success(t *testing.T) {
@@ -273,7 +392,7 @@ func Test_Product_CreateHandler_Case4_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Create(context.Background(), &models.Product{
+		Create(gomock.Any(), &models.Product{
 			Name:  "Name 1",
 			Price: 100.00,
 		}).
@@ -310,11 +429,12 @@ func Test_Product_UpdateHandler_Case1_ParseQueryError(t *testing.T) {
 
 	handler.UpdateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
-	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageNotFound,
-	}), utils.BodyToString(res.Body))
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_UpdateHandler_Case2_FindError(t *testing.T) {
@@ -326,8 +446,8 @@ func Test_Product_UpdateHandler_Case2_FindError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
-		Return(nil, errors.New("some error..."))
+		Find(gomock.Any(), 1).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
@@ -335,11 +455,11 @@ func Test_Product_UpdateHandler_Case2_FindError(t *testing.T) {
 
 	handler.UpdateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageNotFound,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_UpdateHandler_Case3_ParseJsonError(t *testing.T) {
@@ -351,7 +471,7 @@ func Test_Product_UpdateHandler_Case3_ParseJsonError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -364,11 +484,12 @@ func Test_Product_UpdateHandler_Case3_ParseJsonError(t *testing.T) {
 
 	handler.UpdateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
-	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageInternalError,
-	}), utils.BodyToString(res.Body))
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeInvalidJSON, utils.MessageBadRequest)
+	problem.Detail = "EOF"
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_UpdateHandler_Case4_InvalidParams(t *testing.T) {
@@ -380,7 +501,7 @@ func Test_Product_UpdateHandler_Case4_InvalidParams(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -398,12 +519,15 @@ func Test_Product_UpdateHandler_Case4_InvalidParams(t *testing.T) {
 
 	handler.UpdateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+	problem.Errors = []utils.FieldError{
+		{Field: "name", Code: "required", Message: models.ProductValidationNameRequired},
+		{Field: "price", Code: "min", Message: models.ProductValidationPriceGte},
+	}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusUnprocessableEntity, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson([]string{
-		models.ProductValidationNameRequired,
-		models.ProductValidationPriceGte,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_UpdateHandler_Case5_ExecError(t *testing.T) {
@@ -415,7 +539,7 @@ func Test_Product_UpdateHandler_Case5_ExecError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -424,7 +548,7 @@ func Test_Product_UpdateHandler_Case5_ExecError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Update(context.Background(), &models.Product{
+		Update(gomock.Any(), &models.Product{
 			Id:    1,
 			Name:  "Name 1 - update",
 			Price: 999.00,
@@ -442,11 +566,11 @@ func Test_Product_UpdateHandler_Case5_ExecError(t *testing.T) {
 
 	handler.UpdateHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageInternalError,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_UpdateHandler_Case6_Success(t *testing.T) {
@@ -458,7 +582,7 @@ func Test_Product_UpdateHandler_Case6_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -467,7 +591,7 @@ func Test_Product_UpdateHandler_Case6_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Update(context.Background(), &models.Product{
+		Update(gomock.Any(), &models.Product{
 			Id:    1,
 			Name:  "Name 1 - update",
 			Price: 999.00,
@@ -494,6 +618,42 @@ func Test_Product_UpdateHandler_Case6_Success(t *testing.T) {
 	}), utils.BodyToString(res.Body))
 }
 
+func Test_Product_UpdateHandler_Case7_MissingParams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		Find(gomock.Any(), 1).
+		Return(&models.Product{
+			Id:    1,
+			Name:  "Name 1",
+			Price: 100.00,
+		}, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/", bytes.NewBufferString(`
+		{
+			"name": "Name 1 - update"
+		}
+	`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.UpdateHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+	problem.Errors = []utils.FieldError{
+		{Field: "price", Code: "required", Message: models.ProductValidationPriceRequired},
+	}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusUnprocessableEntity, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
 func Test_Product_DestroyHandler_Case1_ParseQueryError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -507,11 +667,12 @@ func Test_Product_DestroyHandler_Case1_ParseQueryError(t *testing.T) {
 
 	handler.DestroyHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
-	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageNotFound,
-	}), utils.BodyToString(res.Body))
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
 }
 
 func Test_Product_DestroyHandler_Case2_FindError(t *testing.T) {
@@ -523,8 +684,8 @@ func Test_Product_DestroyHandler_Case2_FindError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
-		Return(nil, errors.New("some error..."))
+		Find(gomock.Any(), 1).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
@@ -532,11 +693,11 @@ func Test_Product_DestroyHandler_Case2_FindError(t *testing.T) {
 
 	handler.DestroyHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageNotFound,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_DestroyHandler_Case3_ExecError(t *testing.T) {
@@ -548,7 +709,7 @@ func Test_Product_DestroyHandler_Case3_ExecError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -557,7 +718,7 @@ func Test_Product_DestroyHandler_Case3_ExecError(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Destroy(context.Background(), 1).
+		Destroy(gomock.Any(), 1, 0).
 		Return(errors.New("some error..."))
 
 	res := httptest.NewRecorder()
@@ -566,11 +727,11 @@ func Test_Product_DestroyHandler_Case3_ExecError(t *testing.T) {
 
 	handler.DestroyHandler(res, req)
 
-	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
 	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, utils.DataToJson(utils.ResponseMessage{
-		Message: utils.MessageInternalError,
-	}), utils.BodyToString(res.Body))
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+	), utils.BodyToString(res.Body))
 }
 
 func Test_Product_DestroyHandler_Case4_Success(t *testing.T) {
@@ -582,7 +743,7 @@ func Test_Product_DestroyHandler_Case4_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Find(context.Background(), 1).
+		Find(gomock.Any(), 1).
 		Return(&models.Product{
 			Id:    1,
 			Name:  "Name 1",
@@ -591,7 +752,7 @@ func Test_Product_DestroyHandler_Case4_Success(t *testing.T) {
 
 	mock.
 		EXPECT().
-		Destroy(context.Background(), 1).
+		Destroy(gomock.Any(), 1, 0).
 		Return(nil)
 
 	res := httptest.NewRecorder()
@@ -604,3 +765,286 @@ func Test_Product_DestroyHandler_Case4_Success(t *testing.T) {
 	require.Equal(t, http.StatusNoContent, res.Result().StatusCode)
 	require.Equal(t, "", utils.BodyToString(res.Body))
 }
+
+func Test_Product_DestroyHandler_Case5_DestroyNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		Find(gomock.Any(), 1).
+		Return(&models.Product{
+			Id:    1,
+			Name:  "Name 1",
+			Price: 100.00,
+		}, nil)
+
+	mock.
+		EXPECT().
+		Destroy(gomock.Any(), 1, 0).
+		Return(&repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.DestroyHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound),
+	), utils.BodyToString(res.Body))
+}
+
+func Test_Product_ShowHandler_Case4_Unavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		Find(gomock.Any(), 1).
+		Return(nil, &repos.Error{Kind: repos.ErrUnavailable, Err: context.DeadlineExceeded})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.ShowHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusServiceUnavailable, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusServiceUnavailable, utils.CodeUnavailable, utils.MessageUnavailable),
+	), utils.BodyToString(res.Body))
+}
+
+func Test_Product_ShowHandler_Case5_RequestContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	mock.
+		EXPECT().
+		Find(req.Context(), 1).
+		Return(nil, &repos.Error{Kind: repos.ErrTimeout, Err: context.DeadlineExceeded})
+
+	res := httptest.NewRecorder()
+
+	handler.ShowHandler(res, req)
+
+	require.Equal(t, http.StatusGatewayTimeout, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusGatewayTimeout, utils.CodeTimeout, utils.MessageTimeout),
+	), utils.BodyToString(res.Body))
+}
+
+func Test_Product_IndexHandler_Case7_AsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	products := []models.Product{{Id: 1, Name: "Name 1", Price: 100, Version: 1}}
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.
+		EXPECT().
+		AllAsOf(gomock.Any(), at, &query.List{Sort: models.ProductQuerySchema.DefaultSort, Limit: query.DefaultPerPage}).
+		Return(&products, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products?as_of=2021-01-01T00:00:00Z", nil)
+
+	handler.IndexHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: products,
+		Meta: query.Meta{Total: 1, Page: 1, PerPage: query.DefaultPerPage, TotalPages: 1},
+	}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_IndexHandler_Case8_AsOfInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	cursor, err := query.NewCursor([]query.Sort{{Field: "id"}}, models.Product{Id: 1})
+	require.Nil(t, err)
+
+	testCases := []struct {
+		name       string
+		query      string
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:       "invalid time",
+			query:      "/products?as_of=2021-01-01",
+			wantErrors: []utils.FieldError{{Field: ParamAsOf, Code: query.CodeInvalid, Message: MessageInvalidTime}},
+		},
+		{
+			name:       "with cursor",
+			query:      "/products?as_of=2021-01-01T00:00:00Z&after=" + cursor,
+			wantErrors: []utils.FieldError{{Field: query.ParamAfter, Code: query.CodeConflict, Message: MessageAsOfAfter}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.query, nil)
+
+			handler.IndexHandler(res, req)
+
+			problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+			problem.Errors = tc.wantErrors
+
+			require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_Product_ShowHandler_Case6_AsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	product := &models.Product{Id: 1, Name: "Name 1", Price: 100, Version: 1}
+	mock.
+		EXPECT().
+		FindAsOf(gomock.Any(), 1, time.Date(2021, 1, 1, 3, 0, 0, 0, time.UTC)).
+		Return(product, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/1?as_of=2021-01-01T03:00:00Z", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.ShowHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, "", res.Header().Get(utils.HeaderETag))
+	require.Equal(t, utils.DataToJson(product), utils.BodyToString(res.Body))
+}
+
+func Test_Product_ShowHandler_Case7_AsOfNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		FindAsOf(gomock.Any(), 1, gomock.Any()).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/1?as_of=2000-01-01T00:00:00Z", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.ShowHandler(res, req)
+
+	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound)), utils.BodyToString(res.Body))
+}
+
+func Test_Product_CreateHandler_Case5_FieldsForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	policy.
+		EXPECT().
+		DeniedFields(gomock.Any(), ResourceProducts, models.ProductWritableFields).
+		Return([]string{"price"})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(`{"name": "Name 1", "price": 100.00}`))
+
+	handler.CreateHandler(res, req)
+
+	problem := fieldsForbiddenProblem(req, []utils.FieldError{
+		{Field: "price", Code: utils.CodeForbidden, Message: MessageFieldForbidden},
+	})
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusForbidden, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_UpdateHandler_Case8_ChangedFields(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		changed    []string
+		denied     []string
+		wantStatus int
+	}{
+		{name: "unchanged price", body: `{"name": "Name 1 - update", "price": 100.00}`, changed: []string{"name"}, denied: []string{}, wantStatus: http.StatusOK},
+		{name: "changed price", body: `{"name": "Name 1", "price": 999.00}`, changed: []string{"price"}, denied: []string{"price"}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			policy := mock_handlers.NewMockProductPolicy(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+			handler.Policy = policy
+
+			mock.
+				EXPECT().
+				Find(gomock.Any(), 1).
+				Return(&models.Product{Id: 1, Name: "Name 1", Price: 100.00}, nil)
+			policy.
+				EXPECT().
+				DeniedFields(gomock.Any(), ResourceProducts, tc.changed).
+				Return(tc.denied)
+			if len(tc.denied) == 0 {
+				mock.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/", bytes.NewBufferString(tc.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.UpdateHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			if tc.wantStatus == http.StatusForbidden {
+				problem := fieldsForbiddenProblem(req, []utils.FieldError{
+					{Field: "price", Code: utils.CodeForbidden, Message: MessageFieldForbidden},
+				})
+				require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+			}
+		})
+	}
+}
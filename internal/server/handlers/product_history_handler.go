@@ -0,0 +1,106 @@
+//go:generate mockgen -destination mock_handlers/product_history_repo.go . ProductHistoryRepo
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const ParamFrom = "from"
+const ParamTo = "to"
+
+const MessageInvalidTime = "must be RFC 3339 time, e.g. 2021-07-24T00:00:00Z"
+const MessageTimeRange = "must be after from"
+
+type ProductHistoryRepo interface {
+	History(ctx context.Context, productId int, list *query.List) (*[]models.ProductAudit, int, error)
+	Changes(ctx context.Context, from time.Time, to time.Time, list *query.List) (*[]models.ProductAudit, int, error)
+}
+
+type ProductHistoryHandler struct {
+	logger             *zap.Logger
+	productHistoryRepo ProductHistoryRepo
+}
+
+func NewProductHistoryHandler(logger *zap.Logger, productHistoryRepo ProductHistoryRepo) *ProductHistoryHandler {
+	return &ProductHistoryHandler{
+		logger:             logger,
+		productHistoryRepo: productHistoryRepo,
+	}
+}
+
+// HistoryHandler returns page of changes of the product, newest first
+func (p ProductHistoryHandler) HistoryHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(p.logger, res, req, errInvalidID)
+		return
+	}
+
+	// Parse pagination (changes are sorted by time)
+	list, errs := query.Parse(req.URL.Query(), query.Schema{})
+	if len(errs) > 0 {
+		utils.ResponseBadRequest(res, req, queryErrors(errs))
+		return
+	}
+
+	audits, total, err := p.productHistoryRepo.History(req.Context(), id, list)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: audits,
+		Meta: list.Meta(total, nil),
+	})
+}
+
+// ChangesHandler returns page of changes of all products made in the time
+// window [from, to), newest first. `to` is now by default.
+func (p ProductHistoryHandler) ChangesHandler(res http.ResponseWriter, req *http.Request) {
+	values := req.URL.Query()
+	fieldErrors := []utils.FieldError{}
+
+	from, err := time.Parse(time.RFC3339, values.Get(ParamFrom))
+	if err != nil {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: ParamFrom, Code: query.CodeInvalid, Message: MessageInvalidTime})
+	}
+	to := time.Now()
+	if raw := values.Get(ParamTo); raw != "" {
+		to, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			fieldErrors = append(fieldErrors, utils.FieldError{Field: ParamTo, Code: query.CodeInvalid, Message: MessageInvalidTime})
+		}
+	}
+	if len(fieldErrors) == 0 && !to.After(from) {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: ParamTo, Code: query.CodeOutOfRange, Message: MessageTimeRange})
+	}
+
+	list, errs := query.Parse(values, query.Schema{})
+	fieldErrors = append(fieldErrors, queryErrors(errs)...)
+	if len(fieldErrors) > 0 {
+		utils.ResponseBadRequest(res, req, fieldErrors)
+		return
+	}
+
+	audits, total, err := p.productHistoryRepo.Changes(req.Context(), from, to, list)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: audits,
+		Meta: list.Meta(total, nil),
+	})
+}
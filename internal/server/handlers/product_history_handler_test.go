@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewProductHistoryHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	repo := repos.NewProductAuditRepo(&pgxpool.Pool{})
+
+	handler := NewProductHistoryHandler(logger, repo)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, repo, handler.productHistoryRepo)
+}
+
+func newProductAudits() []models.ProductAudit {
+	return []models.ProductAudit{{
+		Id:        2,
+		ProductId: 1,
+		Action:    models.ProductAuditUpdate,
+		Actor:     "alice",
+		RequestId: "request-1",
+		Changes:   map[string]models.FieldChange{"price": {Before: 1.0, After: 2.0}},
+		CreatedAt: time.Date(2021, 7, 24, 10, 0, 0, 0, time.UTC),
+	}}
+}
+
+func Test_ProductHistory_HistoryHandler_Case1_InvalidParams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductHistoryRepo(ctrl)
+	handler := NewProductHistoryHandler(zaptest.NewLogger(t), mock)
+
+	testCases := []struct {
+		name       string
+		id         string
+		query      string
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:       "invalid id",
+			id:         "abc",
+			query:      "/products/abc/history",
+			wantErrors: []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}},
+		},
+		{
+			name:       "invalid pagination",
+			id:         "1",
+			query:      "/products/1/history?limit=0",
+			wantErrors: []utils.FieldError{{Field: "limit", Code: query.CodeOutOfRange, Message: "must be between 1 and 100"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			handler.HistoryHandler(res, req)
+
+			problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+			problem.Errors = tc.wantErrors
+
+			require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_ProductHistory_HistoryHandler_Case2_ExecError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductHistoryRepo(ctrl)
+	handler := NewProductHistoryHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		History(gomock.Any(), 1, gomock.Any()).
+		Return(nil, 0, errors.New("some error..."))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/1/history", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.HistoryHandler(res, req)
+
+	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
+}
+
+func Test_ProductHistory_HistoryHandler_Case3_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductHistoryRepo(ctrl)
+	handler := NewProductHistoryHandler(zaptest.NewLogger(t), mock)
+
+	audits := newProductAudits()
+	list := &query.List{Limit: 10, Offset: 10}
+	mock.
+		EXPECT().
+		History(gomock.Any(), 1, list).
+		Return(&audits, 11, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/1/history?page=2&per_page=10", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.HistoryHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, `{"data":[{"id":2,"product_id":1,"action":"update","actor":"alice","request_id":"request-1",`+
+		`"changes":{"price":{"before":1,"after":2}},"created_at":"2021-07-24T10:00:00Z"}],`+
+		`"meta":{"total":11,"page":2,"per_page":10,"total_pages":2,"next_cursor":null}}`, utils.BodyToString(res.Body))
+}
+
+func Test_ProductHistory_ChangesHandler_Case1_InvalidParams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductHistoryRepo(ctrl)
+	handler := NewProductHistoryHandler(zaptest.NewLogger(t), mock)
+
+	testCases := []struct {
+		name       string
+		query      string
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:  "missing from",
+			query: "/products/history?limit=0",
+			wantErrors: []utils.FieldError{
+				{Field: ParamFrom, Code: query.CodeInvalid, Message: MessageInvalidTime},
+				{Field: "limit", Code: query.CodeOutOfRange, Message: "must be between 1 and 100"},
+			},
+		},
+		{
+			name:       "invalid to",
+			query:      "/products/history?from=2021-07-24T00:00:00Z&to=yesterday",
+			wantErrors: []utils.FieldError{{Field: ParamTo, Code: query.CodeInvalid, Message: MessageInvalidTime}},
+		},
+		{
+			name:       "empty window",
+			query:      "/products/history?from=2021-07-24T00:00:00Z&to=2021-07-24T00:00:00Z",
+			wantErrors: []utils.FieldError{{Field: ParamTo, Code: query.CodeOutOfRange, Message: MessageTimeRange}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.query, nil)
+
+			handler.ChangesHandler(res, req)
+
+			problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+			problem.Errors = tc.wantErrors
+
+			require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_ProductHistory_ChangesHandler_Case2_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductHistoryRepo(ctrl)
+	handler := NewProductHistoryHandler(zaptest.NewLogger(t), mock)
+
+	audits := newProductAudits()
+	from := time.Date(2021, 7, 24, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 7, 25, 0, 0, 0, 0, time.UTC)
+	mock.
+		EXPECT().
+		Changes(gomock.Any(), from, to, &query.List{Limit: query.DefaultPerPage}).
+		Return(&audits, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/history?from=2021-07-24T00:00:00Z&to=2021-07-25T00:00:00Z", nil)
+
+	handler.ChangesHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: audits,
+		Meta: query.Meta{Total: 1, Page: 1, PerPage: query.DefaultPerPage, TotalPages: 1},
+	}), utils.BodyToString(res.Body))
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+const CodePatchTestFailed = "patch_test_failed"
+const CodeReadOnly = "read_only"
+
+const MessagePatchTestFailed = "Patch test failed"
+const MessageReadOnly = "is read only"
+const MessageInvalidType = "has invalid type"
+
+// AcceptPatch lists media types supported by PatchHandler
+var AcceptPatch = strings.Join([]string{
+	utils.ContentTypeMergePatchJSON,
+	utils.ContentTypeJSONPatchJSON,
+}, ", ")
+
+// PatchHandler changes a product with JSON Merge Patch (RFC 7396) or JSON
+// Patch (RFC 6902). Plain application/json is treated as merge patch.
+func (p ProductHandler) PatchHandler(res http.ResponseWriter, req *http.Request) {
+	// Check media type
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(utils.HeaderContentType))
+	if err != nil || (mediaType != utils.ContentTypeMergePatchJSON &&
+		mediaType != utils.ContentTypeJSONPatchJSON &&
+		mediaType != utils.ContentTypeJSON) {
+		res.Header().Set(utils.HeaderAcceptPatch, AcceptPatch)
+		utils.ResponseUnsupportedMediaType(res, req,
+			fmt.Sprintf("Content-Type %q is not supported", req.Header.Get(utils.HeaderContentType)))
+		return
+	}
+
+	// Load product
+	product, err := p.loadProduct(req)
+	if err == nil {
+		err = p.checkIfMatch(req, product)
+	}
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	// Read patch
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+
+	// Apply patch to JSON document of the product
+	doc, err := json.Marshal(product)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+	var patched []byte
+	if mediaType == utils.ContentTypeJSONPatchJSON {
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			utils.ResponseInvalidJSON(res, req, err.Error())
+			return
+		}
+		patched, err = patch.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			problem := utils.NewProblem(req, http.StatusConflict, CodePatchTestFailed, MessagePatchTestFailed)
+			problem.Detail = err.Error()
+			utils.ResponseProblem(res, problem)
+			return
+		}
+		if err != nil {
+			problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+			problem.Detail = err.Error()
+			utils.ResponseProblem(res, problem)
+			return
+		}
+	} else {
+		patched, err = jsonpatch.MergePatch(doc, body)
+		if err != nil {
+			utils.ResponseInvalidJSON(res, req, err.Error())
+			return
+		}
+	}
+
+	// Read patched document to safe struct (mass assignment), unknown
+	// fields are ignored
+	var result struct {
+		Id interface{} `json:"id"`
+		models.ProductParams
+	}
+	if err := json.Unmarshal(patched, &result); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			utils.ResponseInvalid(res, req, []utils.FieldError{
+				{Field: typeErr.Field, Code: query.CodeInvalid, Message: MessageInvalidType},
+			})
+			return
+		}
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+	if id, ok := result.Id.(float64); !ok || id != float64(product.Id) {
+		utils.ResponseInvalid(res, req, []utils.FieldError{
+			{Field: "id", Code: CodeReadOnly, Message: MessageReadOnly},
+		})
+		return
+	}
+
+	// Patched document must still be a whole product
+	if errors := result.ValidatePresence(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
+		return
+	}
+
+	// Apply patch, only changed fields are authorized
+	current := *product
+	product.Apply(result.ProductParams)
+	if errors := p.deniedFields(req.Context(), "", product.ChangedFields(current)); len(errors) > 0 {
+		utils.ResponseProblem(res, fieldsForbiddenProblem(req, errors))
+		return
+	}
+
+	// Validate model
+	if errors := product.Validate(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
+		return
+	}
+
+	// Update product in repo
+	err = p.productRepo.Update(req.Context(), product)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	setETag(res, product)
+	utils.ResponseOK(res, product)
+}
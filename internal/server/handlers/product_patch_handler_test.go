@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newPatchRequest(contentType string, body string) *http.Request {
+	req, _ := http.NewRequest("PATCH", "/products/1", bytes.NewBufferString(body))
+	req.Header.Set(utils.HeaderContentType, contentType)
+	return mux.SetURLVars(req, map[string]string{"id": "1"})
+}
+
+func expectFindProduct(mock *mock_handlers.MockProductRepo) {
+	mock.
+		EXPECT().
+		Find(gomock.Any(), 1).
+		Return(&models.Product{
+			Id:    1,
+			Name:  "Name 1",
+			Price: 100.00,
+		}, nil)
+}
+
+func Test_Product_PatchHandler_Case1_UnsupportedMediaType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	res := httptest.NewRecorder()
+	req := newPatchRequest("text/plain", `name=Name`)
+
+	handler.PatchHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMediaType, utils.MessageUnsupportedMediaType)
+	problem.Detail = `Content-Type "text/plain" is not supported`
+
+	require.Equal(t, AcceptPatch, res.Header().Get(utils.HeaderAcceptPatch))
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusUnsupportedMediaType, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_PatchHandler_Case2_ParseQueryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	res := httptest.NewRecorder()
+	req := newPatchRequest(utils.ContentTypeMergePatchJSON, `{}`)
+	req = mux.SetURLVars(req, map[string]string{"id": "abc"})
+
+	handler.PatchHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_PatchHandler_Case3_MergePatchSuccess(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+	}{
+		{name: "merge patch", contentType: utils.ContentTypeMergePatchJSON},
+		{name: "merge patch with charset", contentType: utils.ContentTypeMergePatchJSON + "; charset=utf-8"},
+		{name: "json", contentType: utils.ContentTypeJSON},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+			expectFindProduct(mock)
+			mock.
+				EXPECT().
+				Update(gomock.Any(), &models.Product{
+					Id:    1,
+					Name:  "Name 1 - update",
+					Price: 100.00,
+				}).
+				Return(nil)
+
+			res := httptest.NewRecorder()
+			req := newPatchRequest(tc.contentType, `{"name": "Name 1 - update", "unknown": true}`)
+
+			handler.PatchHandler(res, req)
+
+			require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+			require.Equal(t, http.StatusOK, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(&models.Product{
+				Id:    1,
+				Name:  "Name 1 - update",
+				Price: 100.00,
+			}), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_Product_PatchHandler_Case4_MergePatchInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantProblem func(req *http.Request) utils.Problem
+	}{
+		{
+			name:       "invalid json",
+			body:       `[`,
+			wantStatus: http.StatusBadRequest,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeInvalidJSON, utils.MessageBadRequest)
+				problem.Detail = "Invalid JSON Patch"
+				return problem
+			},
+		},
+		{
+			name:       "null removes required field",
+			body:       `{"price": null}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "price", Code: "required", Message: models.ProductValidationPriceRequired},
+				}
+				return problem
+			},
+		},
+		{
+			name:       "invalid value",
+			body:       `{"price": -1}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "price", Code: "min", Message: models.ProductValidationPriceGte},
+				}
+				return problem
+			},
+		},
+		{
+			name:       "invalid type",
+			body:       `{"price": "abc"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "price", Code: query.CodeInvalid, Message: MessageInvalidType},
+				}
+				return problem
+			},
+		},
+		{
+			name:       "read only id",
+			body:       `{"id": 2}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "id", Code: CodeReadOnly, Message: MessageReadOnly},
+				}
+				return problem
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+			expectFindProduct(mock)
+
+			res := httptest.NewRecorder()
+			req := newPatchRequest(utils.ContentTypeMergePatchJSON, tc.body)
+
+			handler.PatchHandler(res, req)
+
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(tc.wantProblem(req)), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_Product_PatchHandler_Case5_JSONPatchSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	expectFindProduct(mock)
+	mock.
+		EXPECT().
+		Update(gomock.Any(), &models.Product{
+			Id:    1,
+			Name:  "Name 1",
+			Price: 150.00,
+		}).
+		Return(nil)
+
+	res := httptest.NewRecorder()
+	req := newPatchRequest(utils.ContentTypeJSONPatchJSON, `[
+		{"op": "test", "path": "/price", "value": 100},
+		{"op": "replace", "path": "/price", "value": 150}
+	]`)
+
+	handler.PatchHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(&models.Product{
+		Id:    1,
+		Name:  "Name 1",
+		Price: 150.00,
+	}), utils.BodyToString(res.Body))
+}
+
+func Test_Product_PatchHandler_Case6_JSONPatchInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantProblem func(req *http.Request) utils.Problem
+	}{
+		{
+			name:       "invalid patch document",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeInvalidJSON, utils.MessageBadRequest)
+				problem.Detail = "json: cannot unmarshal object into Go value of type jsonpatch.Patch"
+				return problem
+			},
+		},
+		{
+			name:       "test failed",
+			body:       `[{"op": "test", "path": "/price", "value": 50}]`,
+			wantStatus: http.StatusConflict,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusConflict, CodePatchTestFailed, MessagePatchTestFailed)
+				problem.Detail = "testing value /price failed: test failed"
+				return problem
+			},
+		},
+		{
+			name:       "missing path",
+			body:       `[{"op": "replace", "path": "/missing", "value": 50}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Detail = "replace operation does not apply: doc is missing key: /missing: missing value"
+				return problem
+			},
+		},
+		{
+			name:       "remove required field",
+			body:       `[{"op": "remove", "path": "/name"}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "name", Code: "required", Message: models.ProductValidationNameRequired},
+				}
+				return problem
+			},
+		},
+		{
+			name:       "replace id",
+			body:       `[{"op": "replace", "path": "/id", "value": 2}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnprocessableEntity, utils.CodeInvalid, utils.MessageInvalid)
+				problem.Errors = []utils.FieldError{
+					{Field: "id", Code: CodeReadOnly, Message: MessageReadOnly},
+				}
+				return problem
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+			expectFindProduct(mock)
+
+			res := httptest.NewRecorder()
+			req := newPatchRequest(utils.ContentTypeJSONPatchJSON, tc.body)
+
+			handler.PatchHandler(res, req)
+
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(tc.wantProblem(req)), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_Product_PatchHandler_Case7_FieldsForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	policy := mock_handlers.NewMockProductPolicy(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+	handler.Policy = policy
+
+	expectFindProduct(mock)
+	policy.
+		EXPECT().
+		DeniedFields(gomock.Any(), ResourceProducts, []string{"price"}).
+		Return([]string{"price"})
+
+	res := httptest.NewRecorder()
+	req := newPatchRequest(utils.ContentTypeJSONPatchJSON, `[{"op": "replace", "path": "/price", "value": 1}]`)
+
+	handler.PatchHandler(res, req)
+
+	problem := fieldsForbiddenProblem(req, []utils.FieldError{
+		{Field: "price", Code: utils.CodeForbidden, Message: MessageFieldForbidden},
+	})
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusForbidden, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
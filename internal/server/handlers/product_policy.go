@@ -0,0 +1,45 @@
+//go:generate mockgen -destination mock_handlers/product_policy.go . ProductPolicy
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+// ResourceProducts is the resource of product fields in the policy
+const ResourceProducts = "products"
+
+const MessageFieldForbidden = "may not be changed with your roles"
+const MessageFieldsForbidden = "Roles don't allow to change the fields"
+const MessageOpForbidden = "is not allowed with your roles"
+
+// ProductPolicy authorizes product writes by roles of the request principal
+// (see server.Policy)
+type ProductPolicy interface {
+	AllowsRoute(ctx context.Context, route string) bool
+	DeniedFields(ctx context.Context, resource string, fields []string) []string
+}
+
+// deniedFields returns errors of product fields the request may not change,
+// prefix is the path of the product in the body. Nil policy allows all.
+func (p ProductHandler) deniedFields(ctx context.Context, prefix string, fields []string) []utils.FieldError {
+	errors := []utils.FieldError{}
+	if p.Policy == nil {
+		return errors
+	}
+	for _, field := range p.Policy.DeniedFields(ctx, ResourceProducts, fields) {
+		errors = append(errors, utils.FieldError{Field: prefix + field, Code: utils.CodeForbidden, Message: MessageFieldForbidden})
+	}
+	return errors
+}
+
+// fieldsForbiddenProblem lists the fields in a 403 problem
+func fieldsForbiddenProblem(req *http.Request, errors []utils.FieldError) utils.Problem {
+	problem := utils.NewProblem(req, http.StatusForbidden, utils.CodeForbidden, utils.MessageForbidden)
+	problem.Detail = MessageFieldsForbidden
+	problem.Errors = errors
+	return problem
+}
@@ -0,0 +1,61 @@
+//go:generate mockgen -destination mock_handlers/product_search_repo.go . ProductSearchRepo
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+	"go.uber.org/zap"
+)
+
+const ParamSearchText = "q"
+const SearchTextMaxLength = 250
+
+type ProductSearchRepo interface {
+	Search(ctx context.Context, text string, list *query.List) (*[]models.ProductSearchResult, int, error)
+}
+
+type ProductSearchHandler struct {
+	logger            *zap.Logger
+	productSearchRepo ProductSearchRepo
+}
+
+func NewProductSearchHandler(logger *zap.Logger, productSearchRepo ProductSearchRepo) *ProductSearchHandler {
+	return &ProductSearchHandler{
+		logger:            logger,
+		productSearchRepo: productSearchRepo,
+	}
+}
+
+func (p ProductSearchHandler) SearchHandler(res http.ResponseWriter, req *http.Request) {
+	// Parse search text and pagination (results are sorted by rank)
+	values := req.URL.Query()
+	text := strings.TrimSpace(values.Get(ParamSearchText))
+	fieldErrors := validation.Validate(
+		validation.Field(ParamSearchText, text, validation.Required(), validation.MaxLength(SearchTextMaxLength)),
+	)
+	list, errs := query.Parse(values, query.Schema{})
+	fieldErrors = append(fieldErrors, queryErrors(errs)...)
+	if len(fieldErrors) > 0 {
+		utils.ResponseBadRequest(res, req, fieldErrors)
+		return
+	}
+
+	// Search products
+	results, total, err := p.productSearchRepo.Search(req.Context(), text, list)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: results,
+		Meta: list.Meta(total, nil),
+	})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/roman-wb/crud-products/pkg/validation"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewProductSearchHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	repo := repos.NewProductRepo(&pgxpool.Pool{})
+
+	handler := NewProductSearchHandler(logger, repo)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, repo, handler.productSearchRepo)
+}
+
+func Test_ProductSearch_SearchHandler_Case1_InvalidParams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductSearchRepo(ctrl)
+	handler := NewProductSearchHandler(zaptest.NewLogger(t), mock)
+
+	testCases := []struct {
+		name       string
+		query      string
+		wantErrors []utils.FieldError
+	}{
+		{
+			name:  "blank text",
+			query: "/?q=%20&limit=0",
+			wantErrors: []utils.FieldError{
+				{Field: "q", Code: validation.CodeRequired, Message: "is required"},
+				{Field: "limit", Code: query.CodeOutOfRange, Message: "must be between 1 and 100"},
+			},
+		},
+		{
+			name:       "too long text",
+			query:      "/?q=" + strings.Repeat("a", SearchTextMaxLength+1),
+			wantErrors: []utils.FieldError{{Field: "q", Code: validation.CodeMaxLength, Message: "must be at most 250 characters"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.query, nil)
+
+			handler.SearchHandler(res, req)
+
+			problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+			problem.Errors = tc.wantErrors
+
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+			require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+			require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_ProductSearch_SearchHandler_Case2_ExecError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductSearchRepo(ctrl)
+	handler := NewProductSearchHandler(zaptest.NewLogger(t), mock)
+
+	mock.
+		EXPECT().
+		Search(gomock.Any(), "apple", &query.List{Limit: query.DefaultPerPage}).
+		Return(nil, 0, errors.New("some error..."))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?q=apple", nil)
+
+	handler.SearchHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(
+		utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError),
+	), utils.BodyToString(res.Body))
+}
+
+func Test_ProductSearch_SearchHandler_Case3_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductSearchRepo(ctrl)
+	handler := NewProductSearchHandler(zaptest.NewLogger(t), mock)
+
+	results := []models.ProductSearchResult{
+		{
+			Product: models.Product{Id: 1, Name: "Green apple", Price: 10},
+			Rank:    1.06,
+			Snippet: "Green <mark>apple</mark>",
+		},
+		{
+			Product: models.Product{Id: 2, Name: "Pineapple", Price: 20},
+			Rank:    0.5,
+			Snippet: "Pineapple",
+		},
+	}
+
+	mock.
+		EXPECT().
+		Search(gomock.Any(), "apple", &query.List{Limit: 2, Offset: 2}).
+		Return(&results, 4, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?q=+apple+&page=2&per_page=2", nil)
+
+	handler.SearchHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	body := utils.BodyToString(res.Body)
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: results,
+		Meta: query.Meta{Total: 4, Page: 2, PerPage: 2, TotalPages: 2},
+	}), body)
+	require.Contains(t, body, `{"id":1,"name":"Green apple","price":10,"rank":1.06,`)
+}
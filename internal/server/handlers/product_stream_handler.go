@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/internal/stream"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const HeaderLastEventID = "Last-Event-ID"
+const ContentTypeEventStream = "text/event-stream"
+
+// EventReset tells the client that events since Last-Event-ID are lost,
+// reload products and continue with the stream
+const EventReset = "reset"
+
+const MessageInvalidEventID = "must be an event id"
+
+const CodeHTTPVersionNotSupported = "http_version_not_supported"
+const MessageStreamHTTP1 = "Stream requires HTTP/1.1"
+
+const DefaultStreamHeartbeat = 15 * time.Second
+
+// StreamWriteTimeout limits a write to the stream, a slower client is
+// disconnected
+const StreamWriteTimeout = 5 * time.Second
+
+// StreamRetry is the reconnection delay of EventSource
+const StreamRetry = 3 * time.Second
+
+type ProductStreamHandler struct {
+	logger *zap.Logger
+	hub    *stream.Hub
+
+	// Heartbeat is the interval of comments keeping idle streams open
+	Heartbeat time.Duration
+}
+
+func NewProductStreamHandler(logger *zap.Logger, hub *stream.Hub) *ProductStreamHandler {
+	return &ProductStreamHandler{
+		logger:    logger,
+		hub:       hub,
+		Heartbeat: DefaultStreamHeartbeat,
+	}
+}
+
+// StreamHandler streams product events as Server-Sent Events until the
+// client disconnects. The connection is hijacked from the server and its
+// deadlines are cleared, so the stream sets its own write deadlines instead
+// of the server WriteTimeout. Hijacking needs HTTP/1.1, other protocols
+// (HTTP/2) get 505.
+func (p ProductStreamHandler) StreamHandler(res http.ResponseWriter, req *http.Request) {
+	var lastEventID *int64
+	if raw := req.Header.Get(HeaderLastEventID); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			utils.ResponseBadRequest(res, req, []utils.FieldError{{Field: HeaderLastEventID, Code: query.CodeInvalid, Message: MessageInvalidEventID}})
+			return
+		}
+		lastEventID = &id
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok || req.ProtoMajor != 1 {
+		problem := utils.NewProblem(req, http.StatusHTTPVersionNotSupported, CodeHTTPVersionNotSupported, MessageStreamHTTP1)
+		utils.ResponseProblem(res, problem)
+		return
+	}
+	header := res.Header().Clone()
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		p.logger.Sugar().Error(err)
+		return
+	}
+	defer conn.Close()
+	// Clear deadlines of the server (ReadTimeout and WriteTimeout)
+	conn.SetDeadline(time.Time{}) //nolint:errcheck
+
+	subscription, replay, found := p.hub.Subscribe(requestctx.Tenant(req.Context()), lastEventID)
+	defer subscription.Close()
+
+	// The request context is not canceled on disconnect after hijacking
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, buf.Reader) //nolint:errcheck
+		cancel()
+	}()
+
+	writer := &eventWriter{conn: conn, buf: buf.Writer}
+	header.Set(utils.HeaderContentType, ContentTypeEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "close")
+	header.Set("X-Accel-Buffering", "no")
+	err = writer.head(header)
+	if err == nil && !found {
+		err = writer.write(fmt.Sprintf("event: %s\ndata: {}\n\n", EventReset))
+	}
+	for i := 0; err == nil && i < len(replay); i++ {
+		err = writer.event(replay[i])
+	}
+
+	heartbeat := time.NewTicker(p.Heartbeat)
+	defer heartbeat.Stop()
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			err = writer.write(": heartbeat\n\n")
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// Dropped subscribers resume with Last-Event-ID
+				return
+			}
+			err = writer.event(event)
+		}
+	}
+	p.logger.Sugar().Infow("stream closed", "error", err)
+}
+
+// eventWriter writes the response to the hijacked connection, each write
+// has StreamWriteTimeout
+type eventWriter struct {
+	conn net.Conn
+	buf  *bufio.Writer
+}
+
+func (w *eventWriter) head(header http.Header) error {
+	w.buf.WriteString("HTTP/1.1 200 OK\r\n") //nolint:errcheck
+	header.Write(w.buf)                      //nolint:errcheck
+	w.buf.WriteString("\r\n")                //nolint:errcheck
+	return w.write(fmt.Sprintf("retry: %d\n\n", StreamRetry.Milliseconds()))
+}
+
+func (w *eventWriter) event(event models.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return w.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data))
+}
+
+func (w *eventWriter) write(chunk string) error {
+	err := w.conn.SetWriteDeadline(time.Now().Add(StreamWriteTimeout))
+	if err != nil {
+		return err
+	}
+	w.buf.WriteString(chunk) //nolint:errcheck
+	return w.buf.Flush()
+}
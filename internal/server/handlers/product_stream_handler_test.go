@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/stream"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewProductStreamHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	hub := stream.NewHub(stream.DefaultReplaySize)
+
+	handler := NewProductStreamHandler(logger, hub)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, hub, handler.hub)
+	require.Equal(t, DefaultStreamHeartbeat, handler.Heartbeat)
+}
+
+func newStreamEvent(id int64) models.ProductEvent {
+	return models.ProductEvent{
+		Id:        id,
+		Type:      models.ProductEventUpdated,
+		ProductId: 1,
+		Version:   int(id),
+		Data:      &models.Product{Id: 1, Name: "Name 1", Price: 1, Version: int(id)},
+		CreatedAt: time.Date(2021, 7, 28, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// openStream starts the stream and reads the retry hint
+func openStream(t *testing.T, url string, lastEventID string) (*http.Response, *bufio.Reader) {
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set(HeaderLastEventID, lastEventID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+
+	reader := bufio.NewReader(res.Body)
+	require.Equal(t, "retry: 3000\n\n", readStreamMessage(t, reader))
+	return res, reader
+}
+
+func readStreamMessage(t *testing.T, reader *bufio.Reader) string {
+	message := ""
+	for {
+		line, err := reader.ReadString('\n')
+		require.Nil(t, err)
+		message += line
+		if line == "\n" {
+			return message
+		}
+	}
+}
+
+func Test_ProductStream_StreamHandler_Case1_InvalidLastEventID(t *testing.T) {
+	handler := NewProductStreamHandler(zaptest.NewLogger(t), stream.NewHub(stream.DefaultReplaySize))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/stream", nil)
+	req.Header.Set(HeaderLastEventID, "abc")
+
+	handler.StreamHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{{Field: HeaderLastEventID, Code: query.CodeInvalid, Message: MessageInvalidEventID}}
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_ProductStream_StreamHandler_Case2_NotHijacker(t *testing.T) {
+	handler := NewProductStreamHandler(zaptest.NewLogger(t), stream.NewHub(stream.DefaultReplaySize))
+
+	// Recorder doesn't support hijacking like HTTP/2 response writers
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/stream", nil)
+
+	handler.StreamHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusHTTPVersionNotSupported, CodeHTTPVersionNotSupported, MessageStreamHTTP1)
+	require.Equal(t, http.StatusHTTPVersionNotSupported, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_ProductStream_StreamHandler_Case3_Live(t *testing.T) {
+	hub := stream.NewHub(stream.DefaultReplaySize)
+	handler := NewProductStreamHandler(zaptest.NewLogger(t), hub)
+	handler.Heartbeat = 50 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamHandler))
+	defer server.Close()
+
+	res, reader := openStream(t, server.URL, "")
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, ContentTypeEventStream, res.Header.Get(utils.HeaderContentType))
+	require.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
+
+	hub.Publish(newStreamEvent(7))
+	require.Equal(t, "id: 7\nevent: product.updated\ndata: "+utils.DataToJson(newStreamEvent(7))+"\n\n", readStreamMessage(t, reader))
+
+	require.Equal(t, ": heartbeat\n\n", readStreamMessage(t, reader))
+}
+
+func Test_ProductStream_StreamHandler_Case4_Resume(t *testing.T) {
+	hub := stream.NewHub(stream.DefaultReplaySize)
+	handler := NewProductStreamHandler(zaptest.NewLogger(t), hub)
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamHandler))
+	defer server.Close()
+
+	hub.Publish(newStreamEvent(7))
+	hub.Publish(newStreamEvent(9))
+
+	// Buffered events after Last-Event-ID are replayed
+	res, reader := openStream(t, server.URL, "7")
+	defer res.Body.Close()
+	require.True(t, strings.HasPrefix(readStreamMessage(t, reader), "id: 9\n"))
+
+	// Unknown Last-Event-ID resets the client
+	res, reader = openStream(t, server.URL, "1")
+	defer res.Body.Close()
+	require.Equal(t, "event: reset\ndata: {}\n\n", readStreamMessage(t, reader))
+}
+
+func Test_ProductStream_StreamHandler_Case5_Dropped(t *testing.T) {
+	hub := stream.NewHub(stream.DefaultReplaySize)
+	handler := NewProductStreamHandler(zaptest.NewLogger(t), hub)
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamHandler))
+	defer server.Close()
+
+	res, reader := openStream(t, server.URL, "")
+	defer res.Body.Close()
+
+	hub.Reset()
+
+	_, err := reader.ReadString('\n')
+	require.NotNil(t, err)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+const MessageTrashAfter = "is not supported for trash"
+const MessagePurgeDisabled = "Purge is disabled"
+
+// TrashHandler returns page of soft deleted products, the trash is small so
+// only offset pagination is supported
+func (p ProductHandler) TrashHandler(res http.ResponseWriter, req *http.Request) {
+	// Parse pagination, sort and filters
+	list, errs := query.Parse(req.URL.Query(), models.ProductQuerySchema)
+	if len(errs) > 0 {
+		utils.ResponseBadRequest(res, req, queryErrors(errs))
+		return
+	}
+	if list.After != nil {
+		utils.ResponseBadRequest(res, req, []utils.FieldError{
+			{Field: query.ParamAfter, Code: query.CodeInvalid, Message: MessageTrashAfter},
+		})
+		return
+	}
+
+	// Get page of trashed products
+	products, total, err := p.productRepo.Trash(req.Context(), list)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: products,
+		Meta: list.Meta(total, nil),
+	})
+}
+
+// RestoreHandler brings the soft deleted product back
+func (p ProductHandler) RestoreHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(p.logger, res, req, errInvalidID)
+		return
+	}
+
+	// Restore product in repo
+	product, err := p.productRepo.Restore(req.Context(), id)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	setETag(res, product)
+	utils.ResponseOK(res, product)
+}
+
+// PurgeHandler permanently deletes the soft deleted product, it's allowed
+// only if PurgeEnabled
+func (p ProductHandler) PurgeHandler(res http.ResponseWriter, req *http.Request) {
+	if !p.PurgeEnabled {
+		utils.ResponseForbidden(res, req, MessagePurgeDisabled)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(p.logger, res, req, errInvalidID)
+		return
+	}
+
+	// Purge product in repo
+	err = p.productRepo.Purge(req.Context(), id)
+	if err != nil {
+		responseError(p.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseNoContent(res)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_Product_TrashHandler_Case1_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	deletedAt := time.Date(2021, 7, 23, 10, 0, 0, 0, time.UTC)
+	products := []models.Product{{Id: 1, Name: "Name 1", Price: 1, Version: 2, DeletedAt: &deletedAt}}
+	list, _ := query.Parse(url.Values{"sort": {"-id"}}, models.ProductQuerySchema)
+	mock.
+		EXPECT().
+		Trash(gomock.Any(), list).
+		Return(&products, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/trash?sort=-id", nil)
+
+	handler.TrashHandler(res, req)
+
+	require.Equal(t, utils.ContentTypeJSON, res.Header().Values(utils.HeaderContentType)[0])
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	body := utils.BodyToString(res.Body)
+	require.Equal(t, utils.DataToJson(ResponseList{Data: products, Meta: list.Meta(1, nil)}), body)
+	require.Contains(t, body, `"deleted_at":"2021-07-23T10:00:00Z"`)
+}
+
+func Test_Product_TrashHandler_Case2_After(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockProductRepo(ctrl)
+	handler := NewProductHandler(zaptest.NewLogger(t), mock)
+
+	cursor, err := query.NewCursor([]query.Sort{{Field: "id"}}, models.Product{Id: 1})
+	require.Nil(t, err)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/trash?after="+cursor, nil)
+
+	handler.TrashHandler(res, req)
+
+	problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+	problem.Errors = []utils.FieldError{{Field: query.ParamAfter, Code: query.CodeInvalid, Message: MessageTrashAfter}}
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(problem), utils.BodyToString(res.Body))
+}
+
+func Test_Product_RestoreHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		id         string
+		mock       func(mock *mock_handlers.MockProductRepo)
+		wantStatus int
+		wantBody   func(req *http.Request) string
+	}{
+		{
+			name:       "invalid id",
+			id:         "abc",
+			mock:       func(mock *mock_handlers.MockProductRepo) {},
+			wantStatus: http.StatusBadRequest,
+			wantBody: func(req *http.Request) string {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+				problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: MessageInvalidID}}
+				return utils.DataToJson(problem)
+			},
+		},
+		{
+			name: "not in trash",
+			id:   "1",
+			mock: func(mock *mock_handlers.MockProductRepo) {
+				mock.EXPECT().Restore(gomock.Any(), 1).Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody: func(req *http.Request) string {
+				return utils.DataToJson(utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound))
+			},
+		},
+		{
+			name: "success",
+			id:   "1",
+			mock: func(mock *mock_handlers.MockProductRepo) {
+				mock.EXPECT().Restore(gomock.Any(), 1).Return(&models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 3}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody: func(req *http.Request) string {
+				return utils.DataToJson(models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 3})
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+			tc.mock(mock)
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/products/"+tc.id+"/restore", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			handler.RestoreHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, tc.wantBody(req), utils.BodyToString(res.Body))
+			if tc.wantStatus == http.StatusOK {
+				require.Equal(t, `"3"`, res.Header().Get(utils.HeaderETag))
+			}
+		})
+	}
+}
+
+func Test_Product_PurgeHandler(t *testing.T) {
+	testCases := []struct {
+		name         string
+		purgeEnabled bool
+		mock         func(mock *mock_handlers.MockProductRepo)
+		wantStatus   int
+		wantBody     func(req *http.Request) string
+	}{
+		{
+			name:         "disabled",
+			purgeEnabled: false,
+			mock:         func(mock *mock_handlers.MockProductRepo) {},
+			wantStatus:   http.StatusForbidden,
+			wantBody: func(req *http.Request) string {
+				problem := utils.NewProblem(req, http.StatusForbidden, utils.CodeForbidden, utils.MessageForbidden)
+				problem.Detail = MessagePurgeDisabled
+				return utils.DataToJson(problem)
+			},
+		},
+		{
+			name:         "not in trash",
+			purgeEnabled: true,
+			mock: func(mock *mock_handlers.MockProductRepo) {
+				mock.EXPECT().Purge(gomock.Any(), 1).Return(&repos.Error{Kind: repos.ErrNotFound, Err: pgx.ErrNoRows})
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody: func(req *http.Request) string {
+				return utils.DataToJson(utils.NewProblem(req, http.StatusNotFound, utils.CodeNotFound, utils.MessageNotFound))
+			},
+		},
+		{
+			name:         "internal error",
+			purgeEnabled: true,
+			mock: func(mock *mock_handlers.MockProductRepo) {
+				mock.EXPECT().Purge(gomock.Any(), 1).Return(errors.New("some error..."))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody: func(req *http.Request) string {
+				return utils.DataToJson(utils.NewProblem(req, http.StatusInternalServerError, utils.CodeInternalError, utils.MessageInternalError))
+			},
+		},
+		{
+			name:         "success",
+			purgeEnabled: true,
+			mock: func(mock *mock_handlers.MockProductRepo) {
+				mock.EXPECT().Purge(gomock.Any(), 1).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+			wantBody:   func(req *http.Request) string { return "" },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_handlers.NewMockProductRepo(ctrl)
+			handler := NewProductHandler(zaptest.NewLogger(t), mock)
+			handler.PurgeEnabled = tc.purgeEnabled
+			tc.mock(mock)
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/products/trash/1", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.PurgeHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, tc.wantBody(req), utils.BodyToString(res.Body))
+		})
+	}
+}
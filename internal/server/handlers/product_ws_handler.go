@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/internal/stream"
+	"go.uber.org/zap"
+)
+
+// WebSocket keepalive: the server pings every WSPingPeriod, a client not
+// answering with pong in WSPongWait is disconnected
+const WSPongWait = 60 * time.Second
+const WSPingPeriod = WSPongWait * 9 / 10
+const WSWriteWait = StreamWriteTimeout
+
+// Limits of a client
+const WSMaxMessageSize = 4096
+const WSMaxProductIds = 1000
+const WSMaxPriceRanges = 100
+
+// Ops of client messages
+const WSOpSubscribe = "subscribe"
+const WSOpUnsubscribe = "unsubscribe"
+
+// Types of server messages, product diffs have event types
+const WSTypeSubscriptions = "subscriptions"
+const WSTypeError = "error"
+
+const MessageWSUnknownOp = "op must be subscribe or unsubscribe"
+const MessageWSEmpty = "product_ids or price_min/price_max are required"
+const MessageWSPriceRange = "price_min must not be greater than price_max"
+const MessageWSLimit = "too many subscriptions"
+
+// PriceRange bounds are inclusive, nil is unbounded
+type PriceRange struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+func (r PriceRange) contains(price float64) bool {
+	return (r.Min == nil || price >= *r.Min) && (r.Max == nil || price <= *r.Max)
+}
+
+func (r PriceRange) equal(other PriceRange) bool {
+	return equalBound(r.Min, other.Min) && equalBound(r.Max, other.Max)
+}
+
+func equalBound(a *float64, b *float64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// wsRequest is a client message, e.g. `{"op": "subscribe", "product_ids": [1, 2]}`
+// or `{"op": "subscribe", "price_min": 10, "price_max": 20}`. Unsubscribe
+// without ids and prices unsubscribes from everything.
+type wsRequest struct {
+	Op         string   `json:"op"`
+	ProductIds []int    `json:"product_ids"`
+	PriceMin   *float64 `json:"price_min"`
+	PriceMax   *float64 `json:"price_max"`
+}
+
+type wsSubscriptions struct {
+	Type        string       `json:"type"`
+	ProductIds  []int        `json:"product_ids"`
+	PriceRanges []PriceRange `json:"price_ranges"`
+}
+
+type wsError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// wsEvent is the diff of a followed product
+type wsEvent struct {
+	Type      string                        `json:"type"`
+	EventId   int64                         `json:"event_id"`
+	ProductId int                           `json:"product_id"`
+	Version   int                           `json:"version"`
+	Changes   map[string]models.FieldChange `json:"changes"`
+}
+
+type ProductWSHandler struct {
+	logger   *zap.Logger
+	hub      *stream.Hub
+	upgrader websocket.Upgrader
+}
+
+func NewProductWSHandler(logger *zap.Logger, hub *stream.Hub) *ProductWSHandler {
+	return &ProductWSHandler{
+		logger: logger,
+		hub:    hub,
+	}
+}
+
+// WSHandler sends diffs of products the client follows by id or price range.
+// A client lagging behind is disconnected (close code 1013), it reloads
+// the products and reconnects.
+func (p ProductWSHandler) WSHandler(res http.ResponseWriter, req *http.Request) {
+	// Subscribe before the upgrade, so no event is missed once connected
+	subscription, _, _ := p.hub.Subscribe(requestctx.Tenant(req.Context()), nil)
+	defer subscription.Close()
+
+	// Upgrade responds with an error itself
+	conn, err := p.upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		p.logger.Sugar().Infow("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	filter := newProductFilter()
+	replies := make(chan interface{})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		readWS(conn, filter, replies, stop)
+	}()
+
+	ping := time.NewTicker(WSPingPeriod)
+	defer ping.Stop()
+	for {
+		var message interface{}
+		select {
+		case <-done:
+			return
+		case message = <-replies:
+		case <-ping.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WSWriteWait))
+			if err != nil {
+				return
+			}
+			continue
+		case event, ok := <-subscription.Events:
+			if !ok {
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow")
+				conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(WSWriteWait)) //nolint:errcheck
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			message = wsEvent{
+				Type:      event.Type,
+				EventId:   event.Id,
+				ProductId: event.ProductId,
+				Version:   event.Version,
+				Changes:   event.Changes,
+			}
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(WSWriteWait)) //nolint:errcheck
+		if err := conn.WriteJSON(message); err != nil {
+			return
+		}
+	}
+}
+
+// readWS applies client messages to the filter and sends replies until the
+// connection fails or stop is closed
+func readWS(conn *websocket.Conn, filter *productFilter, replies chan<- interface{}, stop <-chan struct{}) {
+	conn.SetReadLimit(WSMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(WSPongWait)) //nolint:errcheck
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(WSPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var reply interface{}
+		var request wsRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			reply = wsError{Type: WSTypeError, Message: err.Error()}
+		} else {
+			reply = filter.apply(request)
+		}
+
+		select {
+		case replies <- reply:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// productFilter follows products by id and by price ranges. A product
+// matches a range if the price before or after the change is in it, so the
+// client gets the change moving the product out. Deleted products have no
+// price, they match if they are followed by id or matched a range before.
+type productFilter struct {
+	mu     sync.Mutex
+	ids    map[int]bool
+	ranges []PriceRange
+	seen   map[int]bool
+}
+
+func newProductFilter() *productFilter {
+	return &productFilter{
+		ids:  map[int]bool{},
+		seen: map[int]bool{},
+	}
+}
+
+// apply changes subscriptions, it returns subscriptions or the error
+func (f *productFilter) apply(request wsRequest) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hasRange := request.PriceMin != nil || request.PriceMax != nil
+	priceRange := PriceRange{Min: request.PriceMin, Max: request.PriceMax}
+	switch request.Op {
+	case WSOpSubscribe:
+		if len(request.ProductIds) == 0 && !hasRange {
+			return wsError{Type: WSTypeError, Message: MessageWSEmpty}
+		}
+		if hasRange && priceRange.Min != nil && priceRange.Max != nil && *priceRange.Min > *priceRange.Max {
+			return wsError{Type: WSTypeError, Message: MessageWSPriceRange}
+		}
+		if len(f.ids)+len(request.ProductIds) > WSMaxProductIds || (hasRange && len(f.ranges) >= WSMaxPriceRanges) {
+			return wsError{Type: WSTypeError, Message: MessageWSLimit}
+		}
+		for _, id := range request.ProductIds {
+			f.ids[id] = true
+		}
+		if hasRange && f.findRange(priceRange) < 0 {
+			f.ranges = append(f.ranges, priceRange)
+		}
+	case WSOpUnsubscribe:
+		if len(request.ProductIds) == 0 && !hasRange {
+			f.ids, f.ranges, f.seen = map[int]bool{}, nil, map[int]bool{}
+		}
+		for _, id := range request.ProductIds {
+			delete(f.ids, id)
+		}
+		if i := f.findRange(priceRange); hasRange && i >= 0 {
+			f.ranges = append(f.ranges[:i], f.ranges[i+1:]...)
+		}
+	default:
+		return wsError{Type: WSTypeError, Message: MessageWSUnknownOp}
+	}
+
+	subscriptions := wsSubscriptions{Type: WSTypeSubscriptions, ProductIds: []int{}, PriceRanges: []PriceRange{}}
+	for id := range f.ids {
+		subscriptions.ProductIds = append(subscriptions.ProductIds, id)
+	}
+	sort.Ints(subscriptions.ProductIds)
+	subscriptions.PriceRanges = append(subscriptions.PriceRanges, f.ranges...)
+	return subscriptions
+}
+
+func (f *productFilter) match(event models.ProductEvent) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	followed := f.ids[event.ProductId]
+	if event.Data == nil {
+		seen := f.seen[event.ProductId]
+		delete(f.seen, event.ProductId)
+		return followed || seen
+	}
+
+	inRange := f.inRange(event.Data.Price)
+	if before, ok := event.Changes["price"].Before.(float64); ok && f.inRange(before) {
+		followed = true
+	}
+	if inRange {
+		f.seen[event.ProductId] = true
+	} else {
+		delete(f.seen, event.ProductId)
+	}
+	return followed || inRange
+}
+
+func (f *productFilter) inRange(price float64) bool {
+	for _, priceRange := range f.ranges {
+		if priceRange.contains(price) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *productFilter) findRange(priceRange PriceRange) int {
+	for i, existing := range f.ranges {
+		if existing.equal(priceRange) {
+			return i
+		}
+	}
+	return -1
+}
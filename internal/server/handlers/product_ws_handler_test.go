@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/stream"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewProductWSHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	hub := stream.NewHub(stream.DefaultReplaySize)
+
+	handler := NewProductWSHandler(logger, hub)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, hub, handler.hub)
+}
+
+func float64Ptr(value float64) *float64 {
+	return &value
+}
+
+func newPriceEvent(id int64, productId int, before float64, after float64) models.ProductEvent {
+	event := models.ProductEvent{
+		Id:        id,
+		Type:      models.ProductEventUpdated,
+		ProductId: productId,
+		Version:   2,
+		Data:      &models.Product{Id: productId, Name: "Name", Price: after, Version: 2},
+	}
+	if before != after {
+		event.Changes = map[string]models.FieldChange{"price": {Before: before, After: after}}
+	}
+	return event
+}
+
+func Test_productFilter_apply(t *testing.T) {
+	filter := newProductFilter()
+
+	testCases := []struct {
+		name    string
+		request wsRequest
+		want    interface{}
+	}{
+		{
+			name:    "unknown op",
+			request: wsRequest{Op: "follow"},
+			want:    wsError{Type: WSTypeError, Message: MessageWSUnknownOp},
+		},
+		{
+			name:    "empty subscribe",
+			request: wsRequest{Op: WSOpSubscribe},
+			want:    wsError{Type: WSTypeError, Message: MessageWSEmpty},
+		},
+		{
+			name:    "invalid range",
+			request: wsRequest{Op: WSOpSubscribe, PriceMin: float64Ptr(20), PriceMax: float64Ptr(10)},
+			want:    wsError{Type: WSTypeError, Message: MessageWSPriceRange},
+		},
+		{
+			name:    "too many ids",
+			request: wsRequest{Op: WSOpSubscribe, ProductIds: make([]int, WSMaxProductIds+1)},
+			want:    wsError{Type: WSTypeError, Message: MessageWSLimit},
+		},
+		{
+			name:    "subscribe",
+			request: wsRequest{Op: WSOpSubscribe, ProductIds: []int{2, 1}, PriceMin: float64Ptr(10)},
+			want: wsSubscriptions{
+				Type:        WSTypeSubscriptions,
+				ProductIds:  []int{1, 2},
+				PriceRanges: []PriceRange{{Min: float64Ptr(10)}},
+			},
+		},
+		{
+			name:    "unsubscribe",
+			request: wsRequest{Op: WSOpUnsubscribe, ProductIds: []int{2}, PriceMin: float64Ptr(10)},
+			want:    wsSubscriptions{Type: WSTypeSubscriptions, ProductIds: []int{1}, PriceRanges: []PriceRange{}},
+		},
+		{
+			name:    "unsubscribe all",
+			request: wsRequest{Op: WSOpUnsubscribe},
+			want:    wsSubscriptions{Type: WSTypeSubscriptions, ProductIds: []int{}, PriceRanges: []PriceRange{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, filter.apply(tc.request))
+		})
+	}
+}
+
+func Test_productFilter_match(t *testing.T) {
+	filter := newProductFilter()
+	filter.apply(wsRequest{Op: WSOpSubscribe, ProductIds: []int{1}, PriceMin: float64Ptr(10), PriceMax: float64Ptr(20)})
+
+	deleted := func(productId int) models.ProductEvent {
+		return models.ProductEvent{Type: models.ProductEventDeleted, ProductId: productId}
+	}
+
+	testCases := []struct {
+		name  string
+		event models.ProductEvent
+		want  bool
+	}{
+		{name: "followed id", event: newPriceEvent(1, 1, 100, 100), want: true},
+		{name: "other product", event: newPriceEvent(2, 2, 100, 100), want: false},
+		{name: "in range", event: newPriceEvent(3, 3, 15, 15), want: true},
+		{name: "moved in range", event: newPriceEvent(4, 4, 5, 15), want: true},
+		{name: "moved out of range", event: newPriceEvent(5, 3, 15, 30), want: true},
+		{name: "out of range", event: newPriceEvent(6, 3, 30, 40), want: false},
+		{name: "deleted followed id", event: deleted(1), want: true},
+		{name: "deleted seen in range", event: deleted(4), want: true},
+		{name: "deleted out of range", event: deleted(3), want: false},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, filter.match(tc.event), tc.name)
+	}
+}
+
+func Test_ProductWS_WSHandler_Case1_Subscribe(t *testing.T) {
+	hub := stream.NewHub(stream.DefaultReplaySize)
+	handler := NewProductWSHandler(zaptest.NewLogger(t), hub)
+	server := httptest.NewServer(http.HandlerFunc(handler.WSHandler))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Nil(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+	// Invalid messages are answered with errors
+	require.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(`{`)))
+	var gotError wsError
+	require.Nil(t, conn.ReadJSON(&gotError))
+	require.Equal(t, WSTypeError, gotError.Type)
+
+	require.Nil(t, conn.WriteJSON(wsRequest{Op: WSOpSubscribe, ProductIds: []int{1}}))
+	var gotSubscriptions wsSubscriptions
+	require.Nil(t, conn.ReadJSON(&gotSubscriptions))
+	require.Equal(t, []int{1}, gotSubscriptions.ProductIds)
+
+	// Only diffs of followed products are sent
+	hub.Publish(newPriceEvent(1, 2, 10, 20))
+	hub.Publish(newPriceEvent(2, 1, 10, 20))
+	_, data, err := conn.ReadMessage()
+	require.Nil(t, err)
+	require.Equal(t, `{"type":"product.updated","event_id":2,"product_id":1,"version":2,"changes":{"price":{"before":10,"after":20}}}`,
+		strings.TrimSpace(string(data)))
+}
+
+func Test_ProductWS_WSHandler_Case2_SlowConsumer(t *testing.T) {
+	hub := stream.NewHub(stream.DefaultReplaySize)
+	handler := NewProductWSHandler(zaptest.NewLogger(t), hub)
+	server := httptest.NewServer(http.HandlerFunc(handler.WSHandler))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.Nil(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+	// Dropped subscribers are closed with "try again later"
+	hub.Reset()
+
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater))
+}
@@ -0,0 +1,221 @@
+//go:generate mockgen -destination mock_handlers/webhook_repo.go . WebhookRepo
+
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// WebhookSecretBytes is the size of generated secrets
+const WebhookSecretBytes = 32
+
+type WebhookRepo interface {
+	All(ctx context.Context, list *query.List) (*[]models.WebhookSubscription, int, error)
+	Find(ctx context.Context, id int) (*models.WebhookSubscription, error)
+	Create(ctx context.Context, subscription *models.WebhookSubscription) error
+	Update(ctx context.Context, subscription *models.WebhookSubscription) error
+	Destroy(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, subscriptionId int, list *query.List) (*[]models.WebhookDelivery, int, error)
+	Redeliver(ctx context.Context, subscriptionId int, id int64) (*models.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	logger      *zap.Logger
+	webhookRepo WebhookRepo
+}
+
+func NewWebhookHandler(logger *zap.Logger, webhookRepo WebhookRepo) *WebhookHandler {
+	return &WebhookHandler{
+		logger:      logger,
+		webhookRepo: webhookRepo,
+	}
+}
+
+func (w WebhookHandler) IndexHandler(res http.ResponseWriter, req *http.Request) {
+	list, errs := query.Parse(req.URL.Query(), query.Schema{})
+	if len(errs) > 0 {
+		utils.ResponseBadRequest(res, req, queryErrors(errs))
+		return
+	}
+
+	subscriptions, total, err := w.webhookRepo.All(req.Context(), list)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: subscriptions,
+		Meta: list.Meta(total, nil),
+	})
+}
+
+// CreateHandler creates an active subscription, a missing secret is
+// generated. The secret is returned only here.
+func (w WebhookHandler) CreateHandler(res http.ResponseWriter, req *http.Request) {
+	var params models.WebhookSubscriptionParams
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+
+	subscription := models.WebhookSubscription{Active: true}
+	subscription.Apply(params)
+	if subscription.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			responseError(w.logger, res, req, err)
+			return
+		}
+		subscription.Secret = secret
+	}
+	if errors := subscription.Validate(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
+		return
+	}
+
+	err := w.webhookRepo.Create(req.Context(), &subscription)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseCreate(res, subscription)
+}
+
+func (w WebhookHandler) ShowHandler(res http.ResponseWriter, req *http.Request) {
+	subscription, err := w.loadSubscription(req)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, subscription)
+}
+
+// UpdateHandler changes fields present in the body, e.g. `{"active": false}`
+// pauses deliveries. A new secret is not returned.
+func (w WebhookHandler) UpdateHandler(res http.ResponseWriter, req *http.Request) {
+	subscription, err := w.loadSubscription(req)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	var params models.WebhookSubscriptionParams
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return
+	}
+
+	subscription.Apply(params)
+	if errors := subscription.Validate(); len(errors) > 0 {
+		utils.ResponseInvalid(res, req, errors)
+		return
+	}
+
+	err = w.webhookRepo.Update(req.Context(), subscription)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	subscription.Secret = ""
+	utils.ResponseOK(res, subscription)
+}
+
+func (w WebhookHandler) DestroyHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		responseError(w.logger, res, req, errInvalidID)
+		return
+	}
+
+	err = w.webhookRepo.Destroy(req.Context(), id)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseNoContent(res)
+}
+
+// DeliveriesHandler returns page of deliveries of the subscription, newest
+// first
+func (w WebhookHandler) DeliveriesHandler(res http.ResponseWriter, req *http.Request) {
+	subscription, err := w.loadSubscription(req)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	list, errs := query.Parse(req.URL.Query(), query.Schema{})
+	if len(errs) > 0 {
+		utils.ResponseBadRequest(res, req, queryErrors(errs))
+		return
+	}
+
+	deliveries, total, err := w.webhookRepo.Deliveries(req.Context(), subscription.Id, list)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseOK(res, ResponseList{
+		Data: deliveries,
+		Meta: list.Meta(total, nil),
+	})
+}
+
+// RedeliverHandler schedules the delivery to be sent again with all
+// attempts, it's sent in background (202)
+func (w WebhookHandler) RedeliverHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	subscriptionId, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		responseError(w.logger, res, req, errInvalidID)
+		return
+	}
+	id, err := strconv.ParseInt(vars["delivery_id"], 10, 64)
+	if err != nil {
+		utils.ResponseBadRequest(res, req, []utils.FieldError{{Field: "delivery_id", Code: query.CodeInvalid, Message: MessageInvalidID}})
+		return
+	}
+
+	delivery, err := w.webhookRepo.Redeliver(req.Context(), subscriptionId, id)
+	if err != nil {
+		responseError(w.logger, res, req, err)
+		return
+	}
+
+	utils.ResponseAccepted(res, delivery)
+}
+
+func (w WebhookHandler) loadSubscription(req *http.Request) (*models.WebhookSubscription, error) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		return nil, errInvalidID
+	}
+	return w.webhookRepo.Find(req.Context(), id)
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, WebhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/server/handlers/mock_handlers"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_NewWebhookHandler(t *testing.T) {
+	logger := &zap.Logger{}
+	repo := repos.NewWebhookRepo(&pgxpool.Pool{})
+
+	handler := NewWebhookHandler(logger, repo)
+
+	require.Equal(t, logger, handler.logger)
+	require.Equal(t, repo, handler.webhookRepo)
+}
+
+func newWebhookSubscription() *models.WebhookSubscription {
+	return &models.WebhookSubscription{
+		Id:        1,
+		URL:       "https://example.com/hook",
+		Events:    []string{models.ProductEventCreated},
+		Active:    true,
+		CreatedAt: time.Date(2021, 7, 27, 10, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2021, 7, 27, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_Webhook_IndexHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	subscriptions := []models.WebhookSubscription{*newWebhookSubscription()}
+	mock.
+		EXPECT().
+		All(gomock.Any(), &query.List{Limit: 20}).
+		Return(&subscriptions, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/webhooks", nil)
+
+	handler.IndexHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, `{"data":[{"id":1,"url":"https://example.com/hook","events":["product.created"],"active":true,`+
+		`"created_at":"2021-07-27T10:00:00Z","updated_at":"2021-07-27T10:00:00Z"}],`+
+		`"meta":{"total":1,"page":1,"per_page":20,"total_pages":1,"next_cursor":null}}`, utils.BodyToString(res.Body))
+}
+
+func Test_Webhook_CreateHandler_Case1_Invalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "invalid json", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "invalid url", body: `{"url":"ftp://example.com","events":["product.created"]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown event", body: `{"url":"https://example.com","events":["order.created"]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "short secret", body: `{"url":"https://example.com","events":["product.created"],"secret":"abc"}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/webhooks", strings.NewReader(tc.body))
+
+			handler.CreateHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, utils.ContentTypeProblemJSON, res.Header().Values(utils.HeaderContentType)[0])
+		})
+	}
+}
+
+func Test_Webhook_CreateHandler_Case2_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	var created models.WebhookSubscription
+	mock.
+		EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ interface{}, subscription *models.WebhookSubscription) error {
+			subscription.Id = 1
+			created = *subscription
+			return nil
+		})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/webhooks", strings.NewReader(`{"url":"https://example.com/hook","events":["product.created"]}`))
+
+	handler.CreateHandler(res, req)
+
+	require.Equal(t, http.StatusCreated, res.Result().StatusCode)
+	require.True(t, created.Active)
+	require.Equal(t, 2*WebhookSecretBytes, len(created.Secret))
+	require.Equal(t, utils.DataToJson(created), utils.BodyToString(res.Body))
+}
+
+func Test_Webhook_ShowHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	mock.EXPECT().Find(gomock.Any(), 1).Return(newWebhookSubscription(), nil)
+	mock.EXPECT().Find(gomock.Any(), 2).Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")})
+
+	testCases := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+		{name: "not found", id: "2", wantStatus: http.StatusNotFound},
+		{name: "success", id: "1", wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/webhooks/"+tc.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			handler.ShowHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+		})
+	}
+}
+
+func Test_Webhook_UpdateHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	want := newWebhookSubscription()
+	want.Active = false
+	want.Secret = "fedcba9876543210"
+	mock.EXPECT().Find(gomock.Any(), 1).Return(newWebhookSubscription(), nil)
+	mock.EXPECT().Update(gomock.Any(), want).Return(nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/webhooks/1", strings.NewReader(`{"active":false,"secret":"fedcba9876543210"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.UpdateHandler(res, req)
+
+	want.Secret = ""
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(want), utils.BodyToString(res.Body))
+}
+
+func Test_Webhook_DestroyHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	mock.EXPECT().Destroy(gomock.Any(), 1).Return(nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/webhooks/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.DestroyHandler(res, req)
+
+	require.Equal(t, http.StatusNoContent, res.Result().StatusCode)
+}
+
+func Test_Webhook_DeliveriesHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	deliveries := []models.WebhookDelivery{{Id: 5, SubscriptionId: 1, EventId: 10, Status: models.WebhookDeliveryDead}}
+	mock.EXPECT().Find(gomock.Any(), 1).Return(newWebhookSubscription(), nil)
+	mock.EXPECT().Deliveries(gomock.Any(), 1, &query.List{Limit: 20}).Return(&deliveries, 1, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/webhooks/1/deliveries", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	handler.DeliveriesHandler(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Equal(t, utils.DataToJson(ResponseList{
+		Data: deliveries,
+		Meta: query.Meta{Total: 1, Page: 1, PerPage: 20, TotalPages: 1},
+	}), utils.BodyToString(res.Body))
+}
+
+func Test_Webhook_RedeliverHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_handlers.NewMockWebhookRepo(ctrl)
+	handler := NewWebhookHandler(zaptest.NewLogger(t), mock)
+
+	delivery := &models.WebhookDelivery{Id: 5, SubscriptionId: 1, EventId: 10, Status: models.WebhookDeliveryPending}
+	mock.EXPECT().Redeliver(gomock.Any(), 1, int64(5)).Return(delivery, nil)
+
+	testCases := []struct {
+		name       string
+		deliveryId string
+		wantStatus int
+	}{
+		{name: "invalid delivery id", deliveryId: "abc", wantStatus: http.StatusBadRequest},
+		{name: "success", deliveryId: "5", wantStatus: http.StatusAccepted},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/webhooks/1/deliveries/"+tc.deliveryId+"/redeliver", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "delivery_id": tc.deliveryId})
+
+			handler.RedeliverHandler(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+//go:generate mockgen -destination mock_server/idempotency_repo.go . IdempotencyRepo
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+const IdempotencyKeyMaxLength = 255
+
+const CodeIdempotencyKeyInvalid = "idempotency_key_invalid"
+const CodeIdempotencyKeyReused = "idempotency_key_reused"
+const CodeIdempotencyKeyInFlight = "idempotency_key_in_flight"
+
+const MessageIdempotencyKeyInvalid = "must be 1-255 characters"
+const MessageIdempotencyKeyReused = "Idempotency-Key is already used for another request"
+const MessageIdempotencyKeyInFlight = "Request with the same Idempotency-Key is in progress"
+const MessageIdempotencyKeySecret = "is not supported, the response contains a secret which is never stored"
+
+// idempotencyHeaders are response headers stored for replay
+var idempotencyHeaders = []string{utils.HeaderContentType, utils.HeaderLocation, utils.HeaderETag}
+
+type IdempotencyRepo interface {
+	Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration, lease time.Duration) (*models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key string, status int, headers http.Header, body []byte) error
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency replays stored responses of mutating requests retried with
+// the same Idempotency-Key header by the same actor. Reusing a key for a
+// different request returns 422, a retry while the first request is in
+// flight returns 409 until the lease of the key ends (so it must exceed
+// request deadlines), then the retry takes over the key.
+// Server errors (5xx) are not stored so they can be retried. Routes named in
+// secretRoutes return secrets (e.g. API keys), their requests with the
+// header are rejected with 400 rather than stored.
+func Idempotency(logger *zap.Logger, repo IdempotencyRepo, ttl time.Duration, lease time.Duration, secretRoutes map[string]bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if key == "" || req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+				next.ServeHTTP(res, req)
+				return
+			}
+			if len(key) > IdempotencyKeyMaxLength {
+				utils.ResponseBadRequest(res, req, []utils.FieldError{
+					{Field: HeaderIdempotencyKey, Code: CodeIdempotencyKeyInvalid, Message: MessageIdempotencyKeyInvalid},
+				})
+				return
+			}
+			if route := mux.CurrentRoute(req); route != nil && secretRoutes[route.GetName()] {
+				utils.ResponseBadRequest(res, req, []utils.FieldError{
+					{Field: HeaderIdempotencyKey, Code: CodeIdempotencyKeyInvalid, Message: MessageIdempotencyKeySecret},
+				})
+				return
+			}
+
+			// Fingerprint of the request, body is restored for the handler
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				utils.ResponseInvalidJSON(res, req, err.Error())
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(req, body)
+
+			// Reserve key or load the stored response
+			existing, reserved, err := repo.Begin(req.Context(), key, fingerprint, ttl, lease)
+			if err != nil {
+				logger.Sugar().Error(err)
+				if errors.Is(err, repos.ErrUnavailable) {
+					utils.ResponseUnavailable(res, req)
+				} else {
+					utils.ResponseInternalError(res, req)
+				}
+				return
+			}
+			if !reserved {
+				replayIdempotent(res, req, existing, fingerprint)
+				return
+			}
+
+			// Run request and store the response, the key is released on
+			// panic so the request can be retried
+			recorder := &responseRecorder{ResponseWriter: res}
+			defer func() {
+				if rec := recover(); rec != nil {
+					releaseIdempotent(logger, repo, req, key)
+					panic(rec)
+				}
+			}()
+			next.ServeHTTP(recorder, req)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			if recorder.status >= http.StatusInternalServerError {
+				releaseIdempotent(logger, repo, req, key)
+				return
+			}
+			headers := http.Header{}
+			for _, name := range idempotencyHeaders {
+				if values := res.Header().Values(name); len(values) > 0 {
+					headers[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			err = repo.Complete(requestctx.Detach(req.Context()), key, recorder.status, headers, recorder.body.Bytes())
+			if err != nil {
+				logger.Sugar().Error(err)
+			}
+		})
+	}
+}
+
+func replayIdempotent(res http.ResponseWriter, req *http.Request, existing *models.IdempotencyKey, fingerprint string) {
+	if existing.Fingerprint != fingerprint {
+		problem := utils.NewProblem(req, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, MessageIdempotencyKeyReused)
+		utils.ResponseProblem(res, problem)
+		return
+	}
+	if existing.Status == nil {
+		problem := utils.NewProblem(req, http.StatusConflict, CodeIdempotencyKeyInFlight, MessageIdempotencyKeyInFlight)
+		utils.ResponseProblem(res, problem)
+		return
+	}
+
+	for name, values := range existing.Headers {
+		for _, value := range values {
+			res.Header().Add(name, value)
+		}
+	}
+	res.Header().Set(HeaderIdempotentReplayed, "true")
+	res.WriteHeader(*existing.Status)
+	//nolint:errcheck
+	res.Write(existing.Body)
+}
+
+// releaseIdempotent frees the key even if the request is cancelled, the
+// context keeps the tenant of the key
+func releaseIdempotent(logger *zap.Logger, repo IdempotencyRepo, req *http.Request, key string) {
+	if err := repo.Release(requestctx.Detach(req.Context()), key); err != nil {
+		logger.Sugar().Error(err)
+	}
+}
+
+// requestFingerprint identifies the request by method, path and body
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder copies the response status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/internal/server/mock_server"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const testTTL = DefaultIdempotencyTTL
+const testLease = DefaultIdempotencyLease
+
+func newIdempotencyRequest(key string, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/products", bytes.NewBufferString(body))
+	req.Header.Set(HeaderIdempotencyKey, key)
+	return req
+}
+
+// createHandler responds like ProductHandler.CreateHandler and counts calls
+func createHandler(calls *int, status int) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(req.Body)
+		res.Header().Set(utils.HeaderETag, `"1"`)
+		res.Header().Set("X-Other", "skip")
+		res.Header().Set(utils.HeaderContentType, utils.ContentTypeJSON)
+		res.WriteHeader(status)
+		//nolint:errcheck
+		res.Write(body)
+	})
+}
+
+func Test_Idempotency_WithoutKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockIdempotencyRepo(ctrl)
+	calls := 0
+	handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(createHandler(&calls, http.StatusCreated))
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/products", bytes.NewBufferString(`{"name":"A"}`))
+
+	handler.ServeHTTP(res, req)
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusCreated, res.Result().StatusCode)
+}
+
+func Test_Idempotency_FirstRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockIdempotencyRepo(ctrl)
+	calls := 0
+	handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(createHandler(&calls, http.StatusCreated))
+
+	req := newIdempotencyRequest("key-1", `{"name":"A"}`)
+	fingerprint := requestFingerprint(req, []byte(`{"name":"A"}`))
+
+	mock.
+		EXPECT().
+		Begin(gomock.Any(), "key-1", fingerprint, testTTL, testLease).
+		Return(nil, true, nil)
+	mock.
+		EXPECT().
+		Complete(gomock.Any(), "key-1", http.StatusCreated, http.Header{
+			utils.HeaderContentType: {utils.ContentTypeJSON},
+			"Etag":                  {`"1"`},
+		}, []byte(`{"name":"A"}`)).
+		Return(nil)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusCreated, res.Result().StatusCode)
+	require.Equal(t, `{"name":"A"}`, utils.BodyToString(res.Body))
+}
+
+func Test_Idempotency_ServerErrorReleasesKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockIdempotencyRepo(ctrl)
+	calls := 0
+	handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(createHandler(&calls, http.StatusServiceUnavailable))
+
+	mock.
+		EXPECT().
+		Begin(gomock.Any(), "key-1", gomock.Any(), testTTL, testLease).
+		Return(nil, true, nil)
+	mock.
+		EXPECT().
+		Release(gomock.Any(), "key-1").
+		Return(nil)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, newIdempotencyRequest("key-1", `{}`))
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusServiceUnavailable, res.Result().StatusCode)
+}
+
+func Test_Idempotency_PanicReleasesKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockIdempotencyRepo(ctrl)
+	handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("some panic...")
+	}))
+
+	mock.
+		EXPECT().
+		Begin(gomock.Any(), "key-1", gomock.Any(), testTTL, testLease).
+		Return(nil, true, nil)
+	mock.
+		EXPECT().
+		Release(gomock.Any(), "key-1").
+		Return(nil)
+
+	require.PanicsWithValue(t, "some panic...", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotencyRequest("key-1", `{}`))
+	})
+}
+
+// tenantCtx matches a live context of the tenant, so writes of the key
+// reach its row even when the request is cancelled
+type tenantCtx string
+
+func (m tenantCtx) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && ctx.Err() == nil && requestctx.Tenant(ctx) == string(m)
+}
+
+func (m tenantCtx) String() string {
+	return "live context of tenant " + string(m)
+}
+
+func Test_Idempotency_Tenant(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status int
+	}{
+		{name: "complete", status: http.StatusCreated},
+		{name: "release", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_server.NewMockIdempotencyRepo(ctrl)
+			ctx, cancel := context.WithCancel(requestctx.WithTenant(context.Background(), "acme"))
+			defer cancel()
+			handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				// Client is gone once the response is written
+				res.WriteHeader(tc.status)
+				cancel()
+			}))
+
+			mock.
+				EXPECT().
+				Begin(tenantCtx("acme"), "key-1", gomock.Any(), testTTL, testLease).
+				Return(nil, true, nil)
+			if tc.status < http.StatusInternalServerError {
+				mock.
+					EXPECT().
+					Complete(tenantCtx("acme"), "key-1", tc.status, gomock.Any(), gomock.Any()).
+					Return(nil)
+			} else {
+				mock.
+					EXPECT().
+					Release(tenantCtx("acme"), "key-1").
+					Return(nil)
+			}
+
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, newIdempotencyRequest("key-1", `{}`).WithContext(ctx))
+
+			require.Equal(t, tc.status, res.Result().StatusCode)
+		})
+	}
+}
+
+func Test_Idempotency_StoredKey(t *testing.T) {
+	req := newIdempotencyRequest("key-1", `{"name":"A"}`)
+	fingerprint := requestFingerprint(req, []byte(`{"name":"A"}`))
+	status := http.StatusCreated
+
+	testCases := []struct {
+		name        string
+		existing    *models.IdempotencyKey
+		wantStatus  int
+		wantBody    string
+		wantHeaders http.Header
+	}{
+		{
+			name: "replay",
+			existing: &models.IdempotencyKey{
+				Key:         "key-1",
+				Fingerprint: fingerprint,
+				Status:      &status,
+				Headers:     http.Header{utils.HeaderContentType: {utils.ContentTypeJSON}, utils.HeaderETag: {`"1"`}},
+				Body:        []byte(`{"id":1}`),
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"id":1}`,
+			wantHeaders: http.Header{
+				utils.HeaderContentType:  {utils.ContentTypeJSON},
+				"Etag":                   {`"1"`},
+				HeaderIdempotentReplayed: {"true"},
+			},
+		},
+		{
+			name: "different request",
+			existing: &models.IdempotencyKey{
+				Key:         "key-1",
+				Fingerprint: "other",
+				Status:      &status,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody: utils.DataToJson(
+				utils.NewProblem(req, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, MessageIdempotencyKeyReused),
+			),
+			wantHeaders: http.Header{utils.HeaderContentType: {utils.ContentTypeProblemJSON}},
+		},
+		{
+			name: "in flight",
+			existing: &models.IdempotencyKey{
+				Key:         "key-1",
+				Fingerprint: fingerprint,
+			},
+			wantStatus: http.StatusConflict,
+			wantBody: utils.DataToJson(
+				utils.NewProblem(req, http.StatusConflict, CodeIdempotencyKeyInFlight, MessageIdempotencyKeyInFlight),
+			),
+			wantHeaders: http.Header{utils.HeaderContentType: {utils.ContentTypeProblemJSON}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_server.NewMockIdempotencyRepo(ctrl)
+			calls := 0
+			handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(createHandler(&calls, http.StatusCreated))
+
+			mock.
+				EXPECT().
+				Begin(gomock.Any(), "key-1", fingerprint, testTTL, testLease).
+				Return(tc.existing, false, nil)
+
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, newIdempotencyRequest("key-1", `{"name":"A"}`))
+
+			require.Equal(t, 0, calls)
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, tc.wantHeaders, res.Header())
+			require.Equal(t, tc.wantBody, utils.BodyToString(res.Body))
+		})
+	}
+}
+
+func Test_Idempotency_SecretRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_server.NewMockIdempotencyRepo(ctrl)
+	calls := 0
+	router := mux.NewRouter()
+	router.Handle("/admin/keys", createHandler(&calls, http.StatusCreated)).Name("keys.create")
+	router.Handle("/products", createHandler(&calls, http.StatusCreated)).Name("products.create")
+	router.Use(Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, map[string]bool{"keys.create": true}))
+
+	// Responses with secrets are never stored
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/keys", bytes.NewBufferString(`{"name":"CI"}`))
+	req.Header.Set(HeaderIdempotencyKey, "key-1")
+	router.ServeHTTP(res, req)
+
+	require.Equal(t, 0, calls)
+	require.Equal(t, http.StatusBadRequest, res.Result().StatusCode)
+
+	// The route works without the header
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("POST", "/admin/keys", bytes.NewBufferString(`{"name":"CI"}`)))
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusCreated, res.Result().StatusCode)
+
+	// Other routes are stored
+	mock.EXPECT().Begin(gomock.Any(), "key-1", gomock.Any(), testTTL, testLease).Return(nil, true, nil)
+	mock.EXPECT().Complete(gomock.Any(), "key-1", http.StatusCreated, gomock.Any(), gomock.Any()).Return(nil)
+	router.ServeHTTP(httptest.NewRecorder(), newIdempotencyRequest("key-1", `{"name":"A"}`))
+
+	require.Equal(t, 2, calls)
+}
+
+func Test_Idempotency_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		key        string
+		beginErr   error
+		wantStatus int
+	}{
+		{name: "too long key", key: strings.Repeat("a", IdempotencyKeyMaxLength+1), wantStatus: http.StatusBadRequest},
+		{name: "unavailable", key: "key-1", beginErr: &repos.Error{Kind: repos.ErrUnavailable, Err: context.DeadlineExceeded}, wantStatus: http.StatusServiceUnavailable},
+		{name: "unknown", key: "key-1", beginErr: errors.New("some error..."), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mock_server.NewMockIdempotencyRepo(ctrl)
+			calls := 0
+			handler := Idempotency(zaptest.NewLogger(t), mock, testTTL, testLease, nil)(createHandler(&calls, http.StatusCreated))
+
+			if tc.beginErr != nil {
+				mock.
+					EXPECT().
+					Begin(gomock.Any(), tc.key, gomock.Any(), testTTL, testLease).
+					Return(nil, false, tc.beginErr)
+			}
+
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, newIdempotencyRequest(tc.key, `{}`))
+
+			require.Equal(t, 0, calls)
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+		})
+	}
+}
+
+func Test_requestFingerprint(t *testing.T) {
+	req1 := httptest.NewRequest("POST", "/products", nil)
+	req2 := httptest.NewRequest("PUT", "/products", nil)
+
+	require.Equal(t, requestFingerprint(req1, []byte(`{}`)), requestFingerprint(req1, []byte(`{}`)))
+	require.NotEqual(t, requestFingerprint(req1, []byte(`{}`)), requestFingerprint(req1, []byte(`{"a":1}`)))
+	require.NotEqual(t, requestFingerprint(req1, []byte(`{}`)), requestFingerprint(req2, []byte(`{}`)))
+	require.Len(t, requestFingerprint(req1, nil), 64)
+}
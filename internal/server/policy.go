@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+// RoleAdmin is allowed every route and field without a policy entry, the
+// admin key has it
+const RoleAdmin = "admin"
+
+// PolicyAll matches every route or field
+const PolicyAll = "*"
+
+const MessageRouteForbidden = "Roles don't allow route "
+
+// Policy is the role-based access control of routes and fields, it's
+// loaded from a JSON file (POLICY_FILE):
+//
+//	{"roles": {"editor": {"routes": ["products.*"], "fields": {"products": ["name"]}}}}
+//
+// Routes are route names, `products.*` matches routes with the prefix.
+// Fields are fields of the resource a role may change. A principal is
+// allowed what any of its roles allows, a nil policy allows everything.
+type Policy struct {
+	Roles map[string]PolicyRole `json:"roles"`
+}
+
+type PolicyRole struct {
+	Routes []string            `json:"routes"`
+	Fields map[string][]string `json:"fields"`
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy rejects unknown keys, so typos don't silently deny
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, err
+	}
+	for name, role := range policy.Roles {
+		for _, route := range role.Routes {
+			if route == "" {
+				return nil, fmt.Errorf("role %s: blank route", name)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// AllowsRoute checks that a role of the principal of ctx allows the route
+func (p *Policy) AllowsRoute(ctx context.Context, route string) bool {
+	if p == nil {
+		return true
+	}
+	return p.any(ctx, func(role PolicyRole) bool {
+		return matchAny(role.Routes, route)
+	})
+}
+
+// DeniedFields returns fields of the resource which no role of the
+// principal of ctx may change
+func (p *Policy) DeniedFields(ctx context.Context, resource string, fields []string) []string {
+	denied := []string{}
+	if p == nil {
+		return denied
+	}
+	for _, field := range fields {
+		allowed := p.any(ctx, func(role PolicyRole) bool {
+			return matchAny(role.Fields[resource], field)
+		})
+		if !allowed {
+			denied = append(denied, field)
+		}
+	}
+	return denied
+}
+
+func (p *Policy) any(ctx context.Context, allows func(role PolicyRole) bool) bool {
+	principal := requestctx.PrincipalFrom(ctx)
+	if principal == nil {
+		return false
+	}
+	for _, name := range principal.Roles {
+		if name == RoleAdmin {
+			return true
+		}
+		if role, ok := p.Roles[name]; ok && allows(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny matches the value with patterns: exact value, PolicyAll or
+// a prefix ending with `.*`
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == PolicyAll || pattern == value ||
+			(strings.HasSuffix(pattern, ".*") && strings.HasPrefix(value, pattern[:len(pattern)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks the route of authenticated requests against the policy
+// (403 otherwise), public routes are not checked. Fields are checked by
+// handlers.
+func Authorize(policy *Policy) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			route := mux.CurrentRoute(req)
+			if policy == nil || route == nil || requestctx.PrincipalFrom(req.Context()) == nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+			if !policy.AllowsRoute(req.Context(), route.GetName()) {
+				utils.ResponseForbidden(res, req, MessageRouteForbidden+route.GetName())
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
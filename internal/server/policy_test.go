@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{"roles": {
+	"viewer": {"routes": ["products.index", "products.show"]},
+	"editor": {"routes": ["products.*"], "fields": {"products": ["name"]}},
+	"pricing-manager": {"routes": ["products.patch"], "fields": {"products": ["price"]}}
+}}`
+
+func withRoles(roles ...string) context.Context {
+	return requestctx.WithPrincipal(context.Background(), &requestctx.Principal{Subject: "user-1", Roles: roles})
+}
+
+func Test_ParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	require.Equal(t, []string{"name"}, policy.Roles["editor"].Fields["products"])
+
+	for _, invalid := range []string{`{`, `{"roles": {"viewer": {"route": ["products.index"]}}}`, `{"roles": {"viewer": {"routes": [""]}}}`} {
+		_, err := ParsePolicy([]byte(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func Test_LoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0600))
+
+	policy, err := LoadPolicy(path)
+	require.NoError(t, err)
+	require.Len(t, policy.Roles, 3)
+
+	_, err = LoadPolicy(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+// Test_LoadPolicy_Sample fails when the sample policy names unknown routes
+func Test_LoadPolicy_Sample(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.sample.json")
+	require.NoError(t, err)
+
+	for name, role := range policy.Roles {
+		for _, route := range role.Routes {
+			_, ok := routeScopes[route]
+			require.Truef(t, ok, "role %s has unknown route %s", name, route)
+		}
+	}
+}
+
+func Test_Policy_AllowsRoute(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		ctx   context.Context
+		route string
+		want  bool
+	}{
+		{name: "anonymous", ctx: context.Background(), route: "products.index", want: false},
+		{name: "no roles", ctx: withRoles(), route: "products.index", want: false},
+		{name: "unknown role", ctx: withRoles("guest"), route: "products.index", want: false},
+		{name: "exact", ctx: withRoles("viewer"), route: "products.show", want: true},
+		{name: "not listed", ctx: withRoles("viewer"), route: "products.update", want: false},
+		{name: "prefix", ctx: withRoles("editor"), route: "products.update", want: true},
+		{name: "prefix of other resource", ctx: withRoles("editor"), route: "webhooks.index", want: false},
+		{name: "any role", ctx: withRoles("viewer", "pricing-manager"), route: "products.patch", want: true},
+		{name: "admin", ctx: withRoles(RoleAdmin), route: "keys.create", want: true},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, policy.AllowsRoute(tc.ctx, tc.route), tc.name)
+	}
+
+	var nilPolicy *Policy
+	require.True(t, nilPolicy.AllowsRoute(context.Background(), "products.update"))
+}
+
+func Test_Policy_DeniedFields(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	fields := []string{"name", "price"}
+
+	require.Equal(t, []string{"name", "price"}, policy.DeniedFields(withRoles("viewer"), "products", fields))
+	require.Equal(t, []string{"price"}, policy.DeniedFields(withRoles("editor"), "products", fields))
+	require.Equal(t, []string{"name"}, policy.DeniedFields(withRoles("pricing-manager"), "products", fields))
+	require.Equal(t, []string{}, policy.DeniedFields(withRoles("editor", "pricing-manager"), "products", fields))
+	require.Equal(t, []string{"name"}, policy.DeniedFields(withRoles("editor"), "webhooks", []string{"name"}))
+	require.Equal(t, []string{}, policy.DeniedFields(withRoles(RoleAdmin), "products", fields))
+
+	var nilPolicy *Policy
+	require.Equal(t, []string{}, nilPolicy.DeniedFields(context.Background(), "products", fields))
+}
+
+func Test_Authorize(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		policy     *Policy
+		ctx        context.Context
+		route      string
+		wantStatus int
+	}{
+		{name: "allowed", policy: policy, ctx: withRoles("viewer"), route: "products.index", wantStatus: http.StatusOK},
+		{name: "denied", policy: policy, ctx: withRoles("viewer"), route: "products.update", wantStatus: http.StatusForbidden},
+		{name: "public", policy: policy, ctx: context.Background(), route: "health", wantStatus: http.StatusOK},
+		{name: "without policy", ctx: withRoles(), route: "products.update", wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		router := mux.NewRouter()
+		router.HandleFunc("/", func(http.ResponseWriter, *http.Request) {}).Name(tc.route)
+		router.Use(Authorize(tc.policy))
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil).WithContext(tc.ctx)
+
+		router.ServeHTTP(res, req)
+
+		require.Equal(t, tc.wantStatus, res.Result().StatusCode, tc.name)
+	}
+}
@@ -0,0 +1,185 @@
+//go:generate mockgen -destination mock_server/rate_limit_store.go . RateLimitStore
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/ratelimit"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const HeaderRateLimitLimit = "RateLimit-Limit"
+const HeaderRateLimitRemaining = "RateLimit-Remaining"
+const HeaderRateLimitReset = "RateLimit-Reset"
+const HeaderRateLimitPolicy = "RateLimit-Policy"
+
+const RateLimitStoreMemory = "memory"
+const RateLimitStorePostgres = "postgres"
+
+// ClientIPPrefix prefixes the IP of anonymous clients in bucket keys and
+// RATE_LIMIT_CLIENTS
+const ClientIPPrefix = "ip:"
+
+const MessageRateLimited = "Rate limit of %d requests per %s is exceeded"
+
+// RateLimitStore keeps token buckets, ratelimit.MemoryStore is local to the
+// instance and repos.RateLimitRepo is shared by instances
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error)
+	DeleteIdle(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// rateLimitAllRoutes is the route of IP buckets in bucket keys
+const rateLimitAllRoutes = "*"
+
+// RateLimits are limits of clients. A client is the actor of the request
+// (e.g. `api_key:7`) or `ip:<address>` for anonymous requests. Clients
+// overrides the limit of a client on all routes, Routes limits a client on
+// a route by name, Default limits a client on other routes. Nil Default
+// doesn't limit them.
+//
+// IP limits every remote IP on all routes before authentication, so
+// requests with missing or guessed keys are limited too. Clients overrides
+// it for `ip:<address>` clients.
+type RateLimits struct {
+	Default *ratelimit.Limit
+	IP      *ratelimit.Limit
+	Routes  map[string]ratelimit.Limit
+	Clients map[string]ratelimit.Limit
+}
+
+// Enabled reports whether any limit is set
+func (l RateLimits) Enabled() bool {
+	return l.Default != nil || l.IP != nil || len(l.Routes) > 0 || len(l.Clients) > 0
+}
+
+// MaxPeriod is the longest period, buckets idle for it are full
+func (l RateLimits) MaxPeriod() time.Duration {
+	var max time.Duration
+	for _, limit := range []*ratelimit.Limit{l.Default, l.IP} {
+		if limit != nil && limit.Period > max {
+			max = limit.Period
+		}
+	}
+	for _, limits := range []map[string]ratelimit.Limit{l.Routes, l.Clients} {
+		for _, limit := range limits {
+			if limit.Period > max {
+				max = limit.Period
+			}
+		}
+	}
+	return max
+}
+
+// bucket returns the bucket key and the limit of the client on the route
+func (l RateLimits) bucket(client string, route string) (string, ratelimit.Limit, bool) {
+	if limit, ok := l.Clients[client]; ok {
+		return client, limit, true
+	}
+	if limit, ok := l.Routes[route]; ok {
+		return client + " " + route, limit, true
+	}
+	if l.Default != nil {
+		return client, *l.Default, true
+	}
+	return "", ratelimit.Limit{}, false
+}
+
+// ipBucket returns the bucket key and the limit of the IP client on all
+// routes, its key differs from the bucket of anonymous requests
+func (l RateLimits) ipBucket(client string) (string, ratelimit.Limit, bool) {
+	if limit, ok := l.Clients[client]; ok {
+		return client + " " + rateLimitAllRoutes, limit, true
+	}
+	if l.IP != nil {
+		return client + " " + rateLimitAllRoutes, *l.IP, true
+	}
+	return "", ratelimit.Limit{}, false
+}
+
+// RateLimit takes a token from the bucket of the client for every request,
+// requests of an empty bucket get 429 with Retry-After. Responses carry
+// RateLimit-* headers of the bucket. Store errors are logged and the
+// request is let through, so the limiter doesn't take the API down.
+func RateLimit(logger *zap.Logger, store RateLimitStore, limits RateLimits) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			var name string
+			if route := mux.CurrentRoute(req); route != nil {
+				name = route.GetName()
+			}
+			key, limit, ok := limits.bucket(rateLimitClient(req), name)
+			if !ok || takeToken(logger, store, res, req, key, limit) {
+				next.ServeHTTP(res, req)
+			}
+		})
+	}
+}
+
+// RateLimitIP limits remote IPs with limits.IP, it runs before Auth so
+// unauthenticated requests don't reach the key lookup once the bucket is
+// empty. RateLimit limits authenticated clients after it.
+func RateLimitIP(logger *zap.Logger, store RateLimitStore, limits RateLimits) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			key, limit, ok := limits.ipBucket(remoteIPClient(req))
+			if !ok || takeToken(logger, store, res, req, key, limit) {
+				next.ServeHTTP(res, req)
+			}
+		})
+	}
+}
+
+// takeToken takes a token from the bucket and sets RateLimit-* headers, it
+// responds 429 and returns false if the bucket is empty
+func takeToken(logger *zap.Logger, store RateLimitStore, res http.ResponseWriter, req *http.Request, key string, limit ratelimit.Limit) bool {
+	result, err := store.Take(req.Context(), key, limit)
+	if err != nil {
+		logger.Error("rate limit", zap.String("key", key), zap.Error(err))
+		return true
+	}
+
+	res.Header().Set(HeaderRateLimitLimit, strconv.Itoa(limit.Requests))
+	res.Header().Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	res.Header().Set(HeaderRateLimitReset, strconv.Itoa(seconds(result.Reset)))
+	res.Header().Set(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Period)))
+	if !result.Allowed {
+		res.Header().Set(utils.HeaderRetryAfter, strconv.Itoa(int(math.Max(1, float64(seconds(result.RetryAfter))))))
+		utils.ResponseTooManyRequests(res, req, fmt.Sprintf(MessageRateLimited, limit.Requests, limit.Period))
+		return false
+	}
+	return true
+}
+
+// rateLimitClient is the actor of authenticated requests and the remote IP
+// of anonymous ones
+func rateLimitClient(req *http.Request) string {
+	if actor := requestctx.Actor(req.Context()); actor != requestctx.AnonymousActor {
+		return actor
+	}
+	return remoteIPClient(req)
+}
+
+// remoteIPClient is `ip:<address>` of the remote address
+func remoteIPClient(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return ClientIPPrefix + host
+}
+
+// seconds rounds up, so clients don't retry too early
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
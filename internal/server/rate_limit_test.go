@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/ratelimit"
+	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/internal/server/mock_server"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_RateLimits_Bucket(t *testing.T) {
+	defaultLimit := ratelimit.Limit{Requests: 60, Period: time.Minute}
+	limits := RateLimits{
+		Default: &defaultLimit,
+		Routes:  map[string]ratelimit.Limit{"products.index": {Requests: 10, Period: time.Minute}},
+		Clients: map[string]ratelimit.Limit{"api_key:7": {Requests: 1000, Period: time.Hour}},
+	}
+
+	testCases := []struct {
+		name      string
+		limits    RateLimits
+		client    string
+		route     string
+		wantKey   string
+		wantLimit ratelimit.Limit
+		wantOk    bool
+	}{
+		{name: "client", limits: limits, client: "api_key:7", route: "products.index", wantKey: "api_key:7", wantLimit: limits.Clients["api_key:7"], wantOk: true},
+		{name: "route", limits: limits, client: "ip:10.0.0.1", route: "products.index", wantKey: "ip:10.0.0.1 products.index", wantLimit: limits.Routes["products.index"], wantOk: true},
+		{name: "default", limits: limits, client: "ip:10.0.0.1", route: "products.show", wantKey: "ip:10.0.0.1", wantLimit: defaultLimit, wantOk: true},
+		{name: "unlimited", limits: RateLimits{Routes: limits.Routes}, client: "ip:10.0.0.1", route: "products.show", wantOk: false},
+	}
+
+	for _, tc := range testCases {
+		key, limit, ok := tc.limits.bucket(tc.client, tc.route)
+		require.Equal(t, tc.wantKey, key, tc.name)
+		require.Equal(t, tc.wantLimit, limit, tc.name)
+		require.Equal(t, tc.wantOk, ok, tc.name)
+	}
+
+	require.True(t, limits.Enabled())
+	require.False(t, RateLimits{}.Enabled())
+	require.Equal(t, time.Hour, limits.MaxPeriod())
+
+	// IP buckets don't share keys with buckets of anonymous requests
+	_, _, ok := limits.ipBucket("ip:10.0.0.1")
+	require.False(t, ok)
+	ipLimit := ratelimit.Limit{Requests: 600, Period: 2 * time.Hour}
+	limits.IP = &ipLimit
+	key, limit, ok := limits.ipBucket("ip:10.0.0.1")
+	require.Equal(t, "ip:10.0.0.1 *", key)
+	require.Equal(t, ipLimit, limit)
+	require.True(t, ok)
+	require.Equal(t, 2*time.Hour, limits.MaxPeriod())
+}
+
+func Test_RateLimit(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/products", func(http.ResponseWriter, *http.Request) {}).Name("products.index")
+	router.HandleFunc("/health", func(http.ResponseWriter, *http.Request) {}).Name("health")
+	router.Use(RateLimit(zaptest.NewLogger(t), ratelimit.NewMemoryStore(), RateLimits{
+		Routes: map[string]ratelimit.Limit{"products.index": {Requests: 2, Period: time.Minute}},
+	}))
+
+	serve := func(path string, remoteAddr string, actor string) *http.Response {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		if actor != "" {
+			req = req.WithContext(requestctx.WithActor(context.Background(), actor))
+		}
+		router.ServeHTTP(res, req)
+		return res.Result()
+	}
+
+	for _, wantRemaining := range []string{"1", "0"} {
+		res := serve("/products", "10.0.0.1:1234", "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "2", res.Header.Get(HeaderRateLimitLimit))
+		require.Equal(t, wantRemaining, res.Header.Get(HeaderRateLimitRemaining))
+		require.Equal(t, "2;w=60", res.Header.Get(HeaderRateLimitPolicy))
+	}
+
+	res := serve("/products", "10.0.0.1:5678", "")
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.Equal(t, "30", res.Header.Get(utils.HeaderRetryAfter))
+	require.Equal(t, "60", res.Header.Get(HeaderRateLimitReset))
+	require.Equal(t, utils.ContentTypeProblemJSON, res.Header.Get(utils.HeaderContentType))
+
+	// Other clients and routes have their own buckets
+	require.Equal(t, http.StatusOK, serve("/products", "10.0.0.2:1234", "").StatusCode)
+	require.Equal(t, http.StatusOK, serve("/products", "10.0.0.1:1234", "api_key:7").StatusCode)
+	res = serve("/health", "10.0.0.1:1234", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Empty(t, res.Header.Get(HeaderRateLimitLimit))
+}
+
+func Test_RateLimit_StoreError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_server.NewMockRateLimitStore(ctrl)
+	store.EXPECT().Take(gomock.Any(), "ip:10.0.0.1", gomock.Any()).Return(ratelimit.Result{}, errors.New("down"))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/products", func(http.ResponseWriter, *http.Request) {}).Name("products.index")
+	router.Use(RateLimit(zaptest.NewLogger(t), store, RateLimits{Default: &ratelimit.Limit{Requests: 1, Period: time.Second}}))
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/products", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	router.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Result().StatusCode)
+	require.Empty(t, res.Result().Header.Get(HeaderRateLimitLimit))
+}
+
+func Test_RateLimitIP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The key lookup of the second guess is never reached
+	repo := mock_server.NewMockAuthRepo(ctrl)
+	repo.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("guess")).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")}).Times(1)
+
+	store := ratelimit.NewMemoryStore()
+	limits := RateLimits{
+		IP:      &ratelimit.Limit{Requests: 1, Period: time.Minute},
+		Clients: map[string]ratelimit.Limit{"ip:10.0.0.9": {Requests: 100, Period: time.Minute}},
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/products", func(http.ResponseWriter, *http.Request) {}).Name("products.index")
+	router.Use(RateLimitIP(zaptest.NewLogger(t), store, limits))
+	router.Use(Auth(zaptest.NewLogger(t), repo, nil, "", map[string]string{"products.index": models.ScopeProductsRead}))
+	router.Use(RateLimit(zaptest.NewLogger(t), store, limits))
+
+	serve := func(remoteAddr string) *http.Response {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(HeaderAuthorization, "Bearer guess")
+		router.ServeHTTP(res, req)
+		return res.Result()
+	}
+
+	require.Equal(t, http.StatusUnauthorized, serve("10.0.0.1:1234").StatusCode)
+	res := serve("10.0.0.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.Equal(t, "1;w=60", res.Header.Get(HeaderRateLimitPolicy))
+
+	// Clients overrides the IP limit
+	repo.EXPECT().Authenticate(gomock.Any(), models.HashAPIKey("guess")).
+		Return(nil, &repos.Error{Kind: repos.ErrNotFound, Err: errors.New("no rows")}).Times(2)
+	require.Equal(t, http.StatusUnauthorized, serve("10.0.0.9:1234").StatusCode)
+	require.Equal(t, http.StatusUnauthorized, serve("10.0.0.9:1234").StatusCode)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+)
+
+const HeaderRequestID = "X-Request-Id"
+const RequestIDMaxLength = 128
+
+// RequestID takes the request id from X-Request-Id header (e.g. set by a
+// proxy) or generates a new one. The id is returned in the response header
+// and kept in the request context, so audit records refer to the request.
+func RequestID() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			id := req.Header.Get(HeaderRequestID)
+			if id == "" || len(id) > RequestIDMaxLength {
+				id = newRequestID()
+			}
+
+			res.Header().Set(HeaderRequestID, id)
+			next.ServeHTTP(res, req.WithContext(requestctx.WithRequestID(req.Context(), id)))
+		})
+	}
+}
+
+func newRequestID() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
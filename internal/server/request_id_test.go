@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RequestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "from header", header: "request-1", wantSame: true},
+		{name: "generated", header: "", wantSame: false},
+		{name: "too long", header: strings.Repeat("a", RequestIDMaxLength+1), wantSame: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotID string
+			router := mux.NewRouter()
+			router.HandleFunc("/", func(res http.ResponseWriter, req *http.Request) {
+				gotID = requestctx.RequestID(req.Context())
+			})
+			router.Use(RequestID())
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(HeaderRequestID, tc.header)
+			router.ServeHTTP(res, req)
+
+			require.Equal(t, gotID, res.Header().Get(HeaderRequestID))
+			if tc.wantSame {
+				require.Equal(t, tc.header, gotID)
+			} else {
+				require.Len(t, gotID, 32)
+			}
+		})
+	}
+}
@@ -4,30 +4,133 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/purini-to/zapmw"
+	"github.com/roman-wb/crud-products/internal/jwt"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/openapi"
 	"github.com/roman-wb/crud-products/internal/repos"
 	h "github.com/roman-wb/crud-products/internal/server/handlers"
+	"github.com/roman-wb/crud-products/internal/stream"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-func NewRouter(logger *zap.Logger, repos *repos.Repos) *mux.Router {
+// routeScopes are API key scopes required by routes (see Auth), blank scope
+// is public. Routes missing here are forbidden.
+var routeScopes = map[string]string{
+	"home":                "",
+	"health":              "",
+	"openapi":             "",
+	"docs":                "",
+	"products.index":      models.ScopeProductsRead,
+	"products.create":     models.ScopeProductsWrite,
+	"products.stream":     models.ScopeProductsRead,
+	"products.search":     models.ScopeProductsRead,
+	"products.batch":      models.ScopeProductsWrite,
+	"products.changes":    models.ScopeProductsRead,
+	"products.trash":      models.ScopeProductsRead,
+	"products.purge":      models.ScopeProductsPurge,
+	"products.show":       models.ScopeProductsRead,
+	"products.update":     models.ScopeProductsWrite,
+	"products.patch":      models.ScopeProductsWrite,
+	"products.destroy":    models.ScopeProductsWrite,
+	"products.history":    models.ScopeProductsRead,
+	"products.restore":    models.ScopeProductsWrite,
+	"ws":                  models.ScopeProductsRead,
+	"webhooks.index":      models.ScopeWebhooksRead,
+	"webhooks.create":     models.ScopeWebhooksWrite,
+	"webhooks.show":       models.ScopeWebhooksRead,
+	"webhooks.update":     models.ScopeWebhooksWrite,
+	"webhooks.destroy":    models.ScopeWebhooksWrite,
+	"webhooks.deliveries": models.ScopeWebhooksRead,
+	"webhooks.redeliver":  models.ScopeWebhooksWrite,
+	"keys.index":          models.ScopeAdmin,
+	"keys.create":         models.ScopeAdmin,
+	"keys.show":           models.ScopeAdmin,
+	"keys.rotate":         models.ScopeAdmin,
+	"keys.revoke":         models.ScopeAdmin,
+	"events.dead":         models.ScopeAdmin,
+	"events.redeliver":    models.ScopeAdmin,
+}
+
+// secretRoutes return secrets which are only stored hashed, so their
+// responses are never kept for Idempotency-Key replays
+var secretRoutes = map[string]bool{
+	"keys.create": true,
+	"keys.rotate": true,
+}
+
+// NewRouter builds the API, nil verifier disables JWT authentication, nil
+// policy disables role checks and nil limiter disables rate limits
+func NewRouter(logger *zap.Logger, config *Config, repos *repos.Repos, hub *stream.Hub, verifier *jwt.Verifier, policy *Policy, limiter RateLimitStore) *mux.Router {
 	productHandler := h.NewProductHandler(logger, repos.Product)
+	productHandler.RequireIfMatch = config.RequireIfMatch
+	productHandler.PurgeEnabled = config.PurgeEnabled
+	if policy != nil {
+		productHandler.Policy = policy
+	}
+	productSearchHandler := h.NewProductSearchHandler(logger, repos.Product)
+	productHistoryHandler := h.NewProductHistoryHandler(logger, repos.ProductAudit)
+	productStreamHandler := h.NewProductStreamHandler(logger, hub)
+	productStreamHandler.Heartbeat = config.StreamHeartbeat
+	productWSHandler := h.NewProductWSHandler(logger, hub)
+	webhookHandler := h.NewWebhookHandler(logger, repos.Webhook)
+	apiKeyHandler := h.NewAPIKeyHandler(logger, repos.APIKey)
+	productEventHandler := h.NewProductEventHandler(logger, repos.ProductEvent)
 
 	router := mux.NewRouter()
-	router.HandleFunc("/", h.HomeHandler).Methods("GET")
-	router.HandleFunc("/health", h.HealthHandler).Methods("GET")
-	router.HandleFunc("/products", productHandler.IndexHandler).Methods("GET")
-	router.HandleFunc("/products", productHandler.CreateHandler).Methods("POST", "PUT", "PATCH")
-	router.HandleFunc("/products/{id}", productHandler.ShowHandler).Methods("GET")
-	router.HandleFunc("/products/{id}", productHandler.UpdateHandler).Methods("POST", "PUT", "PATCH")
-	router.HandleFunc("/products/{id}", productHandler.DestroyHandler).Methods("DELETE")
+	router.HandleFunc("/", h.HomeHandler).Methods("GET").Name("home")
+	router.HandleFunc("/health", h.HealthHandler).Methods("GET").Name("health")
+	router.HandleFunc("/openapi.json", h.OpenAPIHandler).Methods("GET").Name("openapi")
+	router.HandleFunc("/docs", h.DocsHandler).Methods("GET").Name("docs")
+	router.HandleFunc("/products", productHandler.IndexHandler).Methods("GET").Name("products.index")
+	router.HandleFunc("/products", productHandler.CreateHandler).Methods("POST", "PUT", "PATCH").Name("products.create")
+	router.HandleFunc("/products/stream", productStreamHandler.StreamHandler).Methods("GET").Name("products.stream")
+	router.HandleFunc("/products/search", productSearchHandler.SearchHandler).Methods("GET").Name("products.search")
+	router.HandleFunc("/products/batch", productHandler.BatchHandler).Methods("POST").Name("products.batch")
+	router.HandleFunc("/products/history", productHistoryHandler.ChangesHandler).Methods("GET").Name("products.changes")
+	router.HandleFunc("/products/trash", productHandler.TrashHandler).Methods("GET").Name("products.trash")
+	router.HandleFunc("/products/trash/{id}", productHandler.PurgeHandler).Methods("DELETE").Name("products.purge")
+	router.HandleFunc("/products/{id}", productHandler.ShowHandler).Methods("GET").Name("products.show")
+	router.HandleFunc("/products/{id}", productHandler.UpdateHandler).Methods("POST", "PUT").Name("products.update")
+	router.HandleFunc("/products/{id}", productHandler.PatchHandler).Methods("PATCH").Name("products.patch")
+	router.HandleFunc("/products/{id}", productHandler.DestroyHandler).Methods("DELETE").Name("products.destroy")
+	router.HandleFunc("/products/{id}/history", productHistoryHandler.HistoryHandler).Methods("GET").Name("products.history")
+	router.HandleFunc("/products/{id}/restore", productHandler.RestoreHandler).Methods("POST").Name("products.restore")
+	router.HandleFunc("/ws", productWSHandler.WSHandler).Methods("GET").Name("ws")
+	router.HandleFunc("/webhooks", webhookHandler.IndexHandler).Methods("GET").Name("webhooks.index")
+	router.HandleFunc("/webhooks", webhookHandler.CreateHandler).Methods("POST").Name("webhooks.create")
+	router.HandleFunc("/webhooks/{id}", webhookHandler.ShowHandler).Methods("GET").Name("webhooks.show")
+	router.HandleFunc("/webhooks/{id}", webhookHandler.UpdateHandler).Methods("PUT", "PATCH").Name("webhooks.update")
+	router.HandleFunc("/webhooks/{id}", webhookHandler.DestroyHandler).Methods("DELETE").Name("webhooks.destroy")
+	router.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.DeliveriesHandler).Methods("GET").Name("webhooks.deliveries")
+	router.HandleFunc("/webhooks/{id}/deliveries/{delivery_id}/redeliver", webhookHandler.RedeliverHandler).Methods("POST").Name("webhooks.redeliver")
+	router.HandleFunc("/admin/keys", apiKeyHandler.IndexHandler).Methods("GET").Name("keys.index")
+	router.HandleFunc("/admin/keys", apiKeyHandler.CreateHandler).Methods("POST").Name("keys.create")
+	router.HandleFunc("/admin/keys/{id}", apiKeyHandler.ShowHandler).Methods("GET").Name("keys.show")
+	router.HandleFunc("/admin/keys/{id}", apiKeyHandler.RevokeHandler).Methods("DELETE").Name("keys.revoke")
+	router.HandleFunc("/admin/keys/{id}/rotate", apiKeyHandler.RotateHandler).Methods("POST").Name("keys.rotate")
+	router.HandleFunc("/admin/events/dead", productEventHandler.DeadHandler).Methods("GET").Name("events.dead")
+	router.HandleFunc("/admin/events/{id}/redeliver", productEventHandler.RedeliverHandler).Methods("POST").Name("events.redeliver")
 
 	router.Use(handlers.RecoveryHandler())
+	router.Use(RequestID())
 	router.Use(
 		zapmw.WithZap(logger),
 		zapmw.Request(zapcore.InfoLevel, "request"),
 		zapmw.Recoverer(zapcore.ErrorLevel, "recover", zapmw.RecovererDefault),
 	)
+	router.Use(Deadline(config.QueryTimeout, config.RouteTimeouts))
+	if limiter != nil && config.RateLimits.IP != nil {
+		router.Use(RateLimitIP(logger, limiter, config.RateLimits))
+	}
+	router.Use(Auth(logger, repos.APIKey, verifier, config.AdminKey, routeScopes))
+	router.Use(Tenant())
+	if limiter != nil && config.RateLimits.Enabled() {
+		router.Use(RateLimit(logger, limiter, config.RateLimits))
+	}
+	router.Use(Authorize(policy))
+	router.Use(Idempotency(logger, repos.Idempotency, config.IdempotencyTTL, config.IdempotencyLease, secretRoutes))
+	router.Use(Validation(logger, openapi.MustLoad(), config.ValidateResponses))
 
 	return router
 }
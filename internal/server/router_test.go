@@ -3,10 +3,14 @@ package server
 import (
 	"fmt"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/openapi"
 	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/stream"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,6 +41,16 @@ func Test_NewRouter(t *testing.T) {
 			query:  "/health",
 			want:   false,
 		},
+		{
+			method: "GET",
+			query:  "/openapi.json",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/docs",
+			want:   true,
+		},
 		{
 			method: "GET",
 			query:  "/products",
@@ -62,6 +76,51 @@ func Test_NewRouter(t *testing.T) {
 			query:  "/products",
 			want:   false,
 		},
+		{
+			method: "GET",
+			query:  "/products/search?q=apple",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/products/stream",
+			want:   true,
+		},
+		{
+			method: "POST",
+			query:  "/products/batch",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/products/history?from=2021-07-24T00:00:00Z",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/products/1/history",
+			want:   true,
+		},
+		{
+			method: "DELETE",
+			query:  "/products/1/history",
+			want:   false,
+		},
+		{
+			method: "GET",
+			query:  "/products/trash",
+			want:   true,
+		},
+		{
+			method: "DELETE",
+			query:  "/products/trash/1",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/products/trash/1",
+			want:   false,
+		},
 		{
 			method: "GET",
 			query:  "/products/1",
@@ -87,9 +146,114 @@ func Test_NewRouter(t *testing.T) {
 			query:  "/products/1",
 			want:   true,
 		},
+		{
+			method: "POST",
+			query:  "/products/1/restore",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/products/1/restore",
+			want:   false,
+		},
+		{
+			method: "GET",
+			query:  "/ws",
+			want:   true,
+		},
+		{
+			method: "POST",
+			query:  "/ws",
+			want:   false,
+		},
+		{
+			method: "GET",
+			query:  "/webhooks",
+			want:   true,
+		},
+		{
+			method: "POST",
+			query:  "/webhooks",
+			want:   true,
+		},
+		{
+			method: "DELETE",
+			query:  "/webhooks",
+			want:   false,
+		},
+		{
+			method: "GET",
+			query:  "/webhooks/1",
+			want:   true,
+		},
+		{
+			method: "PUT",
+			query:  "/webhooks/1",
+			want:   true,
+		},
+		{
+			method: "PATCH",
+			query:  "/webhooks/1",
+			want:   true,
+		},
+		{
+			method: "DELETE",
+			query:  "/webhooks/1",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/webhooks/1/deliveries",
+			want:   true,
+		},
+		{
+			method: "POST",
+			query:  "/webhooks/1/deliveries",
+			want:   false,
+		},
+		{
+			method: "POST",
+			query:  "/webhooks/1/deliveries/5/redeliver",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/webhooks/1/deliveries/5/redeliver",
+			want:   false,
+		},
+		{
+			method: "GET",
+			query:  "/admin/keys",
+			want:   true,
+		},
+		{
+			method: "POST",
+			query:  "/admin/keys",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/admin/keys/1",
+			want:   true,
+		},
+		{
+			method: "DELETE",
+			query:  "/admin/keys/1",
+			want:   true,
+		},
+		{
+			method: "POST",
+			query:  "/admin/keys/1/rotate",
+			want:   true,
+		},
+		{
+			method: "GET",
+			query:  "/admin/keys/1/rotate",
+			want:   false,
+		},
 	}
 
-	router := NewRouter(nil, repos.NewRepos(nil))
+	router := NewRouter(nil, &Config{}, repos.NewRepos(nil), stream.NewHub(0), nil, nil, nil)
 
 	for _, tc := range testCases {
 		tc := tc
@@ -105,3 +269,68 @@ func Test_NewRouter(t *testing.T) {
 		})
 	}
 }
+
+// Test_NewRouter_Scopes fails when a route doesn't declare its scope, such
+// routes are forbidden by Auth
+func Test_NewRouter_Scopes(t *testing.T) {
+	router := NewRouter(nil, &Config{}, repos.NewRepos(nil), stream.NewHub(0), nil, nil, nil)
+
+	names := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		name := route.GetName()
+		names[name] = true
+		_, ok := routeScopes[name]
+		require.Truef(t, ok, "route %q has no scope", name)
+		return nil
+	})
+	require.NoError(t, err)
+
+	for name := range routeScopes {
+		require.Truef(t, names[name], "scope of unknown route %q", name)
+	}
+}
+
+// Test_NewRouter_OpenAPI fails when a route is missing from the OpenAPI
+// document or the document has a route which is not registered
+func Test_NewRouter_OpenAPI(t *testing.T) {
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+
+	router := NewRouter(nil, &Config{}, repos.NewRepos(nil), stream.NewHub(0), nil, nil, nil)
+
+	var routes []string
+	err = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+
+			operation, ok := doc.Paths[path][strings.ToLower(method)]
+			require.Truef(t, ok, "route %s %s is not in the document", method, path)
+			// Aliases have the route name with a suffix, e.g. products.update.post
+			name := route.GetName()
+			require.Truef(t, operation.OperationID == name || strings.HasPrefix(operation.OperationID, name+"."),
+				"operationId %q of %s %s doesn't match route name %q", operation.OperationID, method, path, name)
+			require.NotEmptyf(t, operation.Responses, "%s %s has no responses", method, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	require.Equal(t, routes, documented)
+}
@@ -2,21 +2,25 @@ package server
 
 import (
 	"net/http"
-	"os"
 	"time"
 
 	defaultLogger "log"
 
+	"github.com/roman-wb/crud-products/internal/jwt"
 	"github.com/roman-wb/crud-products/internal/repos"
+	"github.com/roman-wb/crud-products/internal/stream"
 	"go.uber.org/zap"
 )
 
 const Timeout = 5 * time.Second
 
-func Run(logger *zap.Logger, repos *repos.Repos) *http.Server {
-	router := NewRouter(logger, repos)
+// Run starts the server. Timeouts are deadlines of the connection, so
+// /products/stream and /ws hijack the connection and clear them, then limit
+// each write instead. Hijacking works with HTTP/1.1 only.
+func Run(logger *zap.Logger, config *Config, repos *repos.Repos, hub *stream.Hub, verifier *jwt.Verifier, policy *Policy, limiter RateLimitStore) *http.Server {
+	router := NewRouter(logger, config, repos, hub, verifier, policy, limiter)
 	server := http.Server{
-		Addr:         os.Getenv("LISTEN_ADDR"),
+		Addr:         config.ListenAddr,
 		Handler:      router,
 		WriteTimeout: Timeout,
 		ReadTimeout:  Timeout,
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+const HeaderTenantID = "X-Tenant-ID"
+
+const CodeTenantInvalid = "tenant_invalid"
+
+const MessageTenantInvalid = "must be 1-64 lowercase letters, digits, '-' or '_'"
+const MessageTenantForbidden = "Credentials don't allow tenant "
+
+// Tenant scopes the request to the tenant of the principal, principals
+// without tenant and with the admin scope (e.g. the admin key) choose it
+// with X-Tenant-ID. Requests without either use DefaultTenant. Repos read
+// it from the context and Postgres row level security hides rows of other
+// tenants.
+func Tenant() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			header := req.Header.Get(HeaderTenantID)
+			if header != "" && !models.IsTenant(header) {
+				utils.ResponseBadRequest(res, req, []utils.FieldError{
+					{Field: HeaderTenantID, Code: CodeTenantInvalid, Message: MessageTenantInvalid},
+				})
+				return
+			}
+
+			tenant := requestctx.DefaultTenant
+			principal := requestctx.PrincipalFrom(req.Context())
+			switch {
+			case principal != nil && principal.Tenant != "":
+				if header != "" && header != principal.Tenant {
+					utils.ResponseForbidden(res, req, MessageTenantForbidden+header)
+					return
+				}
+				tenant = principal.Tenant
+			case header != "":
+				if principal == nil || !principal.HasScope(models.ScopeAdmin) {
+					utils.ResponseForbidden(res, req, MessageTenantForbidden+header)
+					return
+				}
+				tenant = header
+			}
+
+			next.ServeHTTP(res, req.WithContext(requestctx.WithTenant(req.Context(), tenant)))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/requestctx"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Tenant(t *testing.T) {
+	withTenant := func(tenant string) *requestctx.Principal {
+		return &requestctx.Principal{Subject: "api_key:1", Tenant: tenant}
+	}
+	admin := &requestctx.Principal{Subject: AdminActor, Scopes: []string{models.ScopeAdmin}}
+
+	testCases := []struct {
+		name       string
+		principal  *requestctx.Principal
+		header     string
+		wantStatus int
+		wantTenant string
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantTenant: requestctx.DefaultTenant},
+		{name: "header without principal", header: "acme", wantStatus: http.StatusForbidden},
+		{name: "principal without tenant", principal: withTenant(""), wantStatus: http.StatusOK, wantTenant: requestctx.DefaultTenant},
+		{name: "principal without tenant and header", principal: withTenant(""), header: "acme", wantStatus: http.StatusForbidden},
+		{name: "admin", principal: admin, wantStatus: http.StatusOK, wantTenant: requestctx.DefaultTenant},
+		{name: "admin header", principal: admin, header: "acme", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "principal", principal: withTenant("acme"), wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "same header", principal: withTenant("acme"), header: "acme", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "other header", principal: withTenant("acme"), header: "globex", wantStatus: http.StatusForbidden},
+		{name: "invalid header", header: "Acme Inc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotTenant string
+			handler := Tenant()(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				gotTenant = requestctx.Tenant(req.Context())
+			}))
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.principal != nil {
+				req = req.WithContext(requestctx.WithPrincipal(context.Background(), tc.principal))
+			}
+			if tc.header != "" {
+				req.Header.Set(HeaderTenantID, tc.header)
+			}
+
+			handler.ServeHTTP(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			require.Equal(t, tc.wantTenant, gotTenant)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/openapi"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Validation checks path, query and header params, content type and JSON
+// body of requests against the OpenAPI document before handlers. Violations
+// return 400 (415 for a content type the operation doesn't accept), rules
+// of models are still checked by handlers (422). With checkResponses (dev
+// and test) responses are checked too and violations are logged.
+func Validation(logger *zap.Logger, doc *openapi.Document, checkResponses bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			route := mux.CurrentRoute(req)
+			if route == nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+			path, err := route.GetPathTemplate()
+			operation := doc.Operation(path, req.Method)
+			if err != nil || operation == nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			if fieldErrors := doc.ValidateParams(operation, req, mux.Vars(req)); len(fieldErrors) > 0 {
+				utils.ResponseBadRequest(res, req, fieldErrors)
+				return
+			}
+			if operation.RequestBody != nil && !validRequestBody(res, req, doc, operation) {
+				return
+			}
+
+			// Streams hijack the connection, their responses aren't recorded
+			if !checkResponses || streamRoutes[route.GetName()] {
+				next.ServeHTTP(res, req)
+				return
+			}
+			recorder := &responseRecorder{ResponseWriter: res}
+			next.ServeHTTP(recorder, req)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			contentType := res.Header().Get(utils.HeaderContentType)
+			if fieldErrors := doc.ValidateResponse(operation, recorder.status, contentType, recorder.body.Bytes()); len(fieldErrors) > 0 {
+				logger.Warn("response doesn't match OpenAPI document",
+					zap.String("route", route.GetName()),
+					zap.String("method", req.Method),
+					zap.Int("status", recorder.status),
+					zap.Any("errors", fieldErrors),
+				)
+			}
+		})
+	}
+}
+
+// validRequestBody checks the content type and the body and responds on
+// violation, the body is buffered for the handler
+func validRequestBody(res http.ResponseWriter, req *http.Request, doc *openapi.Document, operation *openapi.Operation) bool {
+	if !operation.RequestBody.Required && req.ContentLength == 0 {
+		return true
+	}
+
+	contentType := req.Header.Get(utils.HeaderContentType)
+	schema, ok := doc.RequestSchema(operation, contentType)
+	if !ok {
+		if req.Method == http.MethodPatch {
+			res.Header().Set(utils.HeaderAcceptPatch, acceptPatch(operation))
+		}
+		utils.ResponseUnsupportedMediaType(res, req, fmt.Sprintf("Content-Type %q is not supported", contentType))
+		return false
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return false
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	value, err := openapi.DecodeJSON(body)
+	if err != nil {
+		utils.ResponseInvalidJSON(res, req, err.Error())
+		return false
+	}
+	if fieldErrors := doc.Validate(schema, value, ""); len(fieldErrors) > 0 {
+		utils.ResponseBadRequest(res, req, fieldErrors)
+		return false
+	}
+	return true
+}
+
+func acceptPatch(operation *openapi.Operation) string {
+	mediaTypes := make([]string, 0, len(operation.RequestBody.Content))
+	for mediaType := range operation.RequestBody.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return strings.Join(mediaTypes, ", ")
+}
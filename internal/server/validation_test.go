@@ -0,0 +1,191 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/roman-wb/crud-products/internal/openapi"
+	"github.com/roman-wb/crud-products/pkg/query"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func Test_Validation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantProblem func(req *http.Request) utils.Problem
+		wantHeader  map[string]string
+	}{
+		{
+			name:       "valid",
+			method:     "GET",
+			target:     "/products/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid path param",
+			method:     "GET",
+			target:     "/products/abc",
+			wantStatus: http.StatusBadRequest,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+				problem.Errors = []utils.FieldError{{Field: "id", Code: query.CodeInvalid, Message: "must be an integer"}}
+				return problem
+			},
+		},
+		{
+			name:        "valid body",
+			method:      "POST",
+			target:      "/products",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name": "Apple", "price": 10}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "unsupported media type",
+			method:      "POST",
+			target:      "/products",
+			contentType: "text/plain",
+			body:        `{"name": "Apple", "price": 10}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMediaType, utils.MessageUnsupportedMediaType)
+				problem.Detail = `Content-Type "text/plain" is not supported`
+				return problem
+			},
+		},
+		{
+			name:        "unsupported patch media type",
+			method:      "PATCH",
+			target:      "/products/1",
+			contentType: "text/plain",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantHeader: map[string]string{
+				utils.HeaderAcceptPatch: "application/json, application/json-patch+json, application/merge-patch+json",
+			},
+		},
+		{
+			name:        "invalid json",
+			method:      "POST",
+			target:      "/products",
+			contentType: utils.ContentTypeJSON,
+			body:        `{"name":`,
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeInvalidJSON, utils.MessageBadRequest)
+				problem.Detail = "unexpected EOF"
+				return problem
+			},
+		},
+		{
+			name:        "invalid body",
+			method:      "POST",
+			target:      "/products",
+			contentType: utils.ContentTypeJSON,
+			body:        `{"name": "Apple", "price": "10"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: func(req *http.Request) utils.Problem {
+				problem := utils.NewProblem(req, http.StatusBadRequest, utils.CodeBadRequest, utils.MessageBadRequest)
+				problem.Errors = []utils.FieldError{{Field: "price", Code: query.CodeInvalid, Message: "must be a number"}}
+				return problem
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotBody string
+			handler := func(res http.ResponseWriter, req *http.Request) {
+				body, _ := io.ReadAll(req.Body)
+				gotBody = string(body)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/products", handler).Methods("POST").Name("products.create")
+			router.HandleFunc("/products/{id}", handler).Methods("GET").Name("products.show")
+			router.HandleFunc("/products/{id}", handler).Methods("PATCH").Name("products.patch")
+			router.Use(Validation(zap.NewNop(), openapi.MustLoad(), false))
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set(utils.HeaderContentType, tc.contentType)
+			}
+			router.ServeHTTP(res, req)
+
+			require.Equal(t, tc.wantStatus, res.Result().StatusCode)
+			for name, value := range tc.wantHeader {
+				require.Equal(t, value, res.Header().Get(name))
+			}
+			if tc.wantStatus == http.StatusOK {
+				require.Equal(t, tc.body, gotBody)
+				return
+			}
+			if tc.wantProblem != nil {
+				require.Equal(t, utils.DataToJson(tc.wantProblem(req)), utils.BodyToString(res.Body))
+			}
+		})
+	}
+}
+
+func Test_Validation_Responses(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		response func(res http.ResponseWriter)
+		wantLogs int
+	}{
+		{
+			name:     "valid",
+			path:     "/health",
+			response: func(res http.ResponseWriter) { utils.ResponseOK(res, map[string]string{"status": "ok"}) },
+			wantLogs: 0,
+		},
+		{
+			name:     "invalid",
+			path:     "/health",
+			response: func(res http.ResponseWriter) { utils.ResponseOK(res, map[string]string{"state": "ok"}) },
+			wantLogs: 1,
+		},
+		{
+			name:     "stream",
+			path:     "/products/stream",
+			response: func(res http.ResponseWriter) { utils.ResponseOK(res, "not a stream") },
+			wantLogs: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.WarnLevel)
+			router := mux.NewRouter()
+			router.HandleFunc("/health", func(res http.ResponseWriter, req *http.Request) { tc.response(res) }).Name("health")
+			router.HandleFunc("/products/stream", func(res http.ResponseWriter, req *http.Request) { tc.response(res) }).Name("products.stream")
+			router.Use(Validation(zap.New(core), openapi.MustLoad(), true))
+
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest("GET", tc.path, nil))
+
+			require.Equal(t, http.StatusOK, res.Result().StatusCode)
+			require.Equal(t, tc.wantLogs, logs.Len())
+		})
+	}
+}
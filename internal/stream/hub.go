@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/roman-wb/crud-products/internal/models"
+)
+
+const DefaultReplaySize = 1000
+
+// SubscriberBuffer is the number of events a subscriber may lag behind,
+// slower subscribers are dropped and resume with Last-Event-ID
+const SubscriberBuffer = 64
+
+// Hub fans out product events to subscribers of the server instance and
+// keeps the last events to resume streams
+type Hub struct {
+	mu          sync.Mutex
+	replaySize  int
+	replay      []models.ProductEvent
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub(replaySize int) *Hub {
+	return &Hub{
+		replaySize:  replaySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives events of the tenant published after it is
+// created. Events is closed when the subscriber is dropped or the hub is
+// reset.
+type Subscription struct {
+	Events <-chan models.ProductEvent
+
+	hub    *Hub
+	tenant string
+	events chan models.ProductEvent
+}
+
+// accepts checks the tenant of the event, blank tenant accepts every event
+func (s *Subscription) accepts(event models.ProductEvent) bool {
+	return s.tenant == "" || s.tenant == event.TenantId
+}
+
+// Close unsubscribes, it's safe to call it more than once
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// Subscribe returns the subscription to events of the tenant with buffered
+// events published after lastEventID (if it's set). If the event is not in
+// the buffer anymore, events since it are unknown and found is false.
+func (h *Hub) Subscribe(tenant string, lastEventID *int64) (subscription *Subscription, replay []models.ProductEvent, found bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan models.ProductEvent, SubscriberBuffer)
+	subscription = &Subscription{Events: events, hub: h, tenant: tenant, events: events}
+	h.subscribers[subscription] = struct{}{}
+
+	if lastEventID == nil {
+		return subscription, nil, true
+	}
+	for i, event := range h.replay {
+		if event.Id == *lastEventID {
+			for _, next := range h.replay[i+1:] {
+				if subscription.accepts(next) {
+					replay = append(replay, next)
+				}
+			}
+			return subscription, replay, true
+		}
+	}
+	return subscription, nil, false
+}
+
+// Publish buffers the event and sends it to subscribers
+func (h *Hub) Publish(event models.ProductEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.replay = append(h.replay, event)
+	if len(h.replay) > h.replaySize {
+		h.replay = h.replay[len(h.replay)-h.replaySize:]
+	}
+
+	for subscription := range h.subscribers {
+		if !subscription.accepts(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			h.drop(subscription)
+		}
+	}
+}
+
+// Reset forgets buffered events and drops subscribers, it's called when
+// events may be missed (e.g. the listener reconnects)
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.replay = nil
+	for subscription := range h.subscribers {
+		h.drop(subscription)
+	}
+}
+
+func (h *Hub) drop(subscription *Subscription) {
+	if _, ok := h.subscribers[subscription]; ok {
+		delete(h.subscribers, subscription)
+		close(subscription.events)
+	}
+}
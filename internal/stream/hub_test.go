@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(id int64) models.ProductEvent {
+	return models.ProductEvent{Id: id, Type: models.ProductEventUpdated, ProductId: 1, Version: int(id)}
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func Test_Hub_Publish(t *testing.T) {
+	hub := NewHub(DefaultReplaySize)
+
+	first, replay, found := hub.Subscribe("", nil)
+	require.Nil(t, replay)
+	require.True(t, found)
+	second, _, _ := hub.Subscribe("", nil)
+
+	hub.Publish(newEvent(1))
+	require.Equal(t, newEvent(1), <-first.Events)
+	require.Equal(t, newEvent(1), <-second.Events)
+
+	second.Close()
+	second.Close()
+	_, ok := <-second.Events
+	require.False(t, ok)
+
+	hub.Publish(newEvent(2))
+	require.Equal(t, newEvent(2), <-first.Events)
+}
+
+func Test_Hub_Subscribe_Replay(t *testing.T) {
+	hub := NewHub(3)
+	for id := int64(1); id <= 5; id++ {
+		hub.Publish(newEvent(id))
+	}
+
+	testCases := []struct {
+		name        string
+		lastEventID *int64
+		wantReplay  []models.ProductEvent
+		wantFound   bool
+	}{
+		{name: "new stream", lastEventID: nil, wantReplay: nil, wantFound: true},
+		{name: "buffered", lastEventID: int64Ptr(3), wantReplay: []models.ProductEvent{newEvent(4), newEvent(5)}, wantFound: true},
+		{name: "last", lastEventID: int64Ptr(5), wantReplay: nil, wantFound: true},
+		{name: "evicted", lastEventID: int64Ptr(2), wantReplay: nil, wantFound: false},
+		{name: "unknown", lastEventID: int64Ptr(100), wantReplay: nil, wantFound: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			subscription, replay, found := hub.Subscribe("", tc.lastEventID)
+			defer subscription.Close()
+
+			require.Equal(t, tc.wantReplay, replay)
+			require.Equal(t, tc.wantFound, found)
+		})
+	}
+}
+
+func Test_Hub_DropSlowSubscriber(t *testing.T) {
+	hub := NewHub(DefaultReplaySize)
+	subscription, _, _ := hub.Subscribe("", nil)
+
+	for id := int64(1); id <= SubscriberBuffer+1; id++ {
+		hub.Publish(newEvent(id))
+	}
+
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	require.Equal(t, SubscriberBuffer, received)
+}
+
+func Test_Hub_Reset(t *testing.T) {
+	hub := NewHub(DefaultReplaySize)
+	hub.Publish(newEvent(1))
+	subscription, _, _ := hub.Subscribe("", nil)
+
+	hub.Reset()
+
+	_, ok := <-subscription.Events
+	require.False(t, ok)
+	_, _, found := hub.Subscribe("", int64Ptr(1))
+	require.False(t, found)
+}
+
+func Test_Hub_Tenant(t *testing.T) {
+	hub := NewHub(DefaultReplaySize)
+	acme, globex := newEvent(1), newEvent(2)
+	acme.TenantId, globex.TenantId = "acme", "globex"
+	hub.Publish(newEvent(0))
+
+	subscription, _, _ := hub.Subscribe("acme", nil)
+	hub.Publish(globex)
+	hub.Publish(acme)
+	require.Equal(t, acme, <-subscription.Events)
+
+	_, replay, found := hub.Subscribe("globex", int64Ptr(0))
+	require.True(t, found)
+	require.Equal(t, []models.ProductEvent{globex}, replay)
+}
@@ -0,0 +1,57 @@
+//go:generate mockgen -destination mock_stream/repo.go . Repo
+
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"go.uber.org/zap"
+)
+
+const DefaultRetryInterval = time.Second
+
+type Repo interface {
+	Listen(ctx context.Context, handle func(event models.ProductEvent)) error
+}
+
+// Listener publishes product events committed by any server instance to
+// the hub, Postgres notifies it in the transaction of the change
+type Listener struct {
+	logger *zap.Logger
+	repo   Repo
+	hub    *Hub
+
+	RetryInterval time.Duration
+}
+
+func NewListener(logger *zap.Logger, repo Repo, hub *Hub) *Listener {
+	return &Listener{
+		logger:        logger,
+		repo:          repo,
+		hub:           hub,
+		RetryInterval: DefaultRetryInterval,
+	}
+}
+
+// Run listens until ctx is done, the connection is restored after errors.
+// Events committed while it's not listening are lost, so the hub is reset.
+func (l *Listener) Run(ctx context.Context) {
+	defer l.hub.Reset()
+
+	for {
+		l.hub.Reset()
+		err := l.repo.Listen(ctx, l.hub.Publish)
+		if ctx.Err() != nil {
+			return
+		}
+		l.logger.Sugar().Warnw("listen product events failed", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.RetryInterval):
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/stream/mock_stream"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_Listener_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_stream.NewMockRepo(ctrl)
+	hub := NewHub(DefaultReplaySize)
+	listener := NewListener(zaptest.NewLogger(t), repo, hub)
+	listener.RetryInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscription, _, _ := hub.Subscribe("", nil)
+
+	gomock.InOrder(
+		repo.EXPECT().Listen(gomock.Any(), gomock.Any()).Return(errors.New("conn closed")),
+		repo.EXPECT().Listen(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, handle func(event models.ProductEvent)) error {
+				handle(newEvent(1))
+				cancel()
+				return ctx.Err()
+			}),
+	)
+
+	listener.Run(ctx)
+
+	// Subscribers are dropped on reconnect, buffered events are forgotten on stop
+	_, ok := <-subscription.Events
+	require.False(t, ok)
+	_, _, found := hub.Subscribe("", int64Ptr(1))
+	require.False(t, found)
+}
@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkAddress(t *testing.T) {
+	testCases := []struct {
+		address string
+		blocked bool
+	}{
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", false},
+		{"127.0.0.1:80", true},
+		{"10.1.2.3:80", true},
+		{"172.16.0.1:80", true},
+		{"192.168.1.1:80", true},
+		{"169.254.169.254:80", true},
+		{"0.0.0.0:80", true},
+		{"[::1]:80", true},
+		{"[fd00::1]:80", true},
+		{"[fe80::1]:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.address, func(t *testing.T) {
+			err := checkAddress(tc.address)
+			require.Equal(t, tc.blocked, errors.Is(err, ErrBlockedAddress))
+		})
+	}
+}
+
+func Test_NewClient_Blocked(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, receiver.URL, nil)
+	_, err := NewClient().Do(req) //nolint:bodyclose
+	require.True(t, errors.Is(err, ErrBlockedAddress))
+}
+
+func Test_NewClient_Redirect(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	// The receiver is on loopback, so its transport is used
+	client := NewClient()
+	client.Transport = receiver.Client().Transport
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, receiver.URL, nil)
+	res, err := client.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusFound, res.StatusCode)
+}
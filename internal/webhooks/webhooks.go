@@ -0,0 +1,177 @@
+//go:generate mockgen -destination mock_webhooks/repo.go . Repo
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/outbox"
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const HeaderSignature = "X-Webhook-Signature"
+const HeaderDeliveryID = "X-Webhook-Delivery"
+
+const DefaultBatchSize = 50
+const DefaultMaxAttempts = 8
+const DefaultMinBackoff = 10 * time.Second
+const DefaultMaxBackoff = time.Hour
+
+// HTTPTimeout limits a delivery request, a claimed delivery is leased for
+// longer, so it isn't sent twice
+const HTTPTimeout = 10 * time.Second
+const Lease = 2 * HTTPTimeout
+
+type Repo interface {
+	Enqueue(ctx context.Context, event models.ProductEvent) (int64, error)
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+	MarkFailed(ctx context.Context, id int64, statusCode *int, lastError string, nextAttemptAt *time.Time) error
+}
+
+// Fanout is the outbox publisher of webhooks: it saves a delivery of the
+// event for every matching subscription, Dispatcher sends them
+type Fanout struct {
+	repo Repo
+}
+
+func NewFanout(repo Repo) *Fanout {
+	return &Fanout{
+		repo: repo,
+	}
+}
+
+func (f *Fanout) Publish(ctx context.Context, event models.ProductEvent) error {
+	_, err := f.repo.Enqueue(ctx, event)
+	return err
+}
+
+func (f *Fanout) Close() error {
+	return nil
+}
+
+// Dispatcher sends due deliveries to subscribers. Delivery is at least once:
+// a delivery succeeds on 2xx status, other statuses and errors are retried
+// with exponential backoff until MaxAttempts, then the delivery is dead.
+type Dispatcher struct {
+	logger *zap.Logger
+	repo   Repo
+	client *http.Client
+
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+
+	now func() time.Time
+}
+
+func NewDispatcher(logger *zap.Logger, repo Repo, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		logger:      logger,
+		repo:        repo,
+		client:      client,
+		BatchSize:   DefaultBatchSize,
+		MaxAttempts: DefaultMaxAttempts,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		now:         time.Now,
+	}
+}
+
+// Deliver sends a batch of due deliveries concurrently, it is run
+// periodically (see jobs.Every)
+func (d *Dispatcher) Deliver(ctx context.Context) error {
+	deliveries, err := d.repo.Claim(ctx, d.BatchSize, Lease)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(deliveries))
+	for i := range deliveries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = d.deliver(ctx, deliveries[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) error {
+	statusCode, err := d.send(ctx, delivery)
+	if err == nil {
+		return d.repo.MarkDelivered(ctx, delivery.Id, *statusCode)
+	}
+
+	attempts := delivery.Attempts + 1
+	var nextAttemptAt *time.Time
+	if attempts < d.MaxAttempts {
+		next := d.now().Add(outbox.Backoff(attempts, d.MinBackoff, d.MaxBackoff))
+		nextAttemptAt = &next
+	}
+	d.logger.Sugar().Warnw("webhook delivery failed", "delivery_id", delivery.Id, "attempts", attempts,
+		"dead", nextAttemptAt == nil, "error", err)
+	return d.repo.MarkFailed(ctx, delivery.Id, statusCode, err.Error(), nextAttemptAt)
+}
+
+// send posts the event, statusCode is nil if there is no response
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (*int, error) {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(utils.HeaderContentType, utils.ContentTypeJSON)
+	req.Header.Set(HeaderDeliveryID, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(outbox.HeaderEventID, strconv.FormatInt(delivery.EventId, 10))
+	req.Header.Set(outbox.HeaderEventType, delivery.EventType)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, d.now().Unix(), body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16)) //nolint:errcheck
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return &res.StatusCode, nil
+}
+
+// Sign returns the signature header `t=<unix time>,v1=<hex>`, where v1 is
+// HMAC-SHA256 of `<unix time>.<body>` with the subscription secret. Receivers
+// compute it the same way and reject old timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + ".")) //nolint:errcheck
+	mac.Write(body)                                           //nolint:errcheck
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
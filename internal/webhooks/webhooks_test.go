@@ -0,0 +1,175 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/roman-wb/crud-products/internal/models"
+	"github.com/roman-wb/crud-products/internal/outbox"
+	"github.com/roman-wb/crud-products/internal/webhooks/mock_webhooks"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newDelivery(url string) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		Id:             5,
+		SubscriptionId: 1,
+		EventId:        10,
+		EventType:      models.ProductEventUpdated,
+		Payload: models.ProductEvent{
+			Id:        10,
+			Type:      models.ProductEventUpdated,
+			ProductId: 1,
+			Version:   2,
+			Data:      &models.Product{Id: 1, Name: "Name 1", Price: 1, Version: 2},
+		},
+		Status: models.WebhookDeliveryPending,
+		URL:    url,
+		Secret: "0123456789abcdef",
+	}
+}
+
+func Test_Sign(t *testing.T) {
+	require.Equal(t,
+		"t=1627293600,v1=b3757a11bab820544fa456d065443a20fefac32f0d88cefadcb0e6e9b40c4212",
+		Sign("0123456789abcdef", 1627293600, []byte(`{"id":1}`)))
+	require.NotEqual(t, Sign("0123456789abcdef", 1627293600, []byte(`{"id":1}`)), Sign("0123456789abcdef", 1627293601, []byte(`{"id":1}`)))
+	require.NotEqual(t, Sign("0123456789abcdef", 1627293600, []byte(`{"id":1}`)), Sign("fedcba9876543210", 1627293600, []byte(`{"id":1}`)))
+}
+
+func Test_Fanout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_webhooks.NewMockRepo(ctrl)
+	fanout := NewFanout(repo)
+	event := newDelivery("").Payload
+
+	repo.EXPECT().Enqueue(gomock.Any(), event).Return(int64(2), nil)
+	require.Nil(t, fanout.Publish(context.Background(), event))
+
+	repo.EXPECT().Enqueue(gomock.Any(), event).Return(int64(0), errors.New("conn closed"))
+	require.EqualError(t, fanout.Publish(context.Background(), event), "conn closed")
+
+	require.Nil(t, fanout.Close())
+}
+
+func Test_Dispatcher_Deliver_Case1_Succeeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var gotHeader http.Header
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := mock_webhooks.NewMockRepo(ctrl)
+	dispatcher := NewDispatcher(zaptest.NewLogger(t), repo, receiver.Client())
+	now := time.Date(2021, 7, 27, 10, 0, 0, 0, time.UTC)
+	dispatcher.now = func() time.Time { return now }
+	delivery := newDelivery(receiver.URL)
+
+	repo.EXPECT().Claim(gomock.Any(), DefaultBatchSize, Lease).Return([]models.WebhookDelivery{delivery}, nil)
+	repo.EXPECT().MarkDelivered(gomock.Any(), delivery.Id, http.StatusNoContent).Return(nil)
+
+	require.Nil(t, dispatcher.Deliver(context.Background()))
+
+	wantBody, _ := json.Marshal(delivery.Payload)
+	require.Equal(t, wantBody, gotBody)
+	require.Equal(t, "5", gotHeader.Get(HeaderDeliveryID))
+	require.Equal(t, "10", gotHeader.Get(outbox.HeaderEventID))
+	require.Equal(t, models.ProductEventUpdated, gotHeader.Get(outbox.HeaderEventType))
+	require.Equal(t, Sign(delivery.Secret, now.Unix(), wantBody), gotHeader.Get(HeaderSignature))
+}
+
+func Test_Dispatcher_Deliver_Case2_Retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := mock_webhooks.NewMockRepo(ctrl)
+	dispatcher := NewDispatcher(zaptest.NewLogger(t), repo, receiver.Client())
+	now := time.Date(2021, 7, 27, 10, 0, 0, 0, time.UTC)
+	dispatcher.now = func() time.Time { return now }
+
+	retried := newDelivery(receiver.URL)
+	retried.Attempts = 2
+	dead := newDelivery(receiver.URL)
+	dead.Id = 6
+	dead.Attempts = DefaultMaxAttempts - 1
+	unreachable := newDelivery("http://127.0.0.1:0")
+	unreachable.Id = 7
+
+	statusCode := http.StatusInternalServerError
+	nextAttemptAt := now.Add(4 * DefaultMinBackoff)
+	unreachableAt := now.Add(DefaultMinBackoff)
+	repo.EXPECT().Claim(gomock.Any(), DefaultBatchSize, Lease).Return([]models.WebhookDelivery{retried, dead, unreachable}, nil)
+	repo.EXPECT().MarkFailed(gomock.Any(), retried.Id, &statusCode, "webhook responded with status 500", &nextAttemptAt).Return(nil)
+	repo.EXPECT().MarkFailed(gomock.Any(), dead.Id, &statusCode, "webhook responded with status 500", nil).Return(nil)
+	repo.EXPECT().MarkFailed(gomock.Any(), unreachable.Id, nil, gomock.Any(), &unreachableAt).Return(nil)
+
+	require.Nil(t, dispatcher.Deliver(context.Background()))
+}
+
+func Test_Dispatcher_Deliver_Case3_Concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Both requests must be in flight to be answered
+	var wg sync.WaitGroup
+	wg.Add(2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wg.Done()
+		wg.Wait()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	repo := mock_webhooks.NewMockRepo(ctrl)
+	dispatcher := NewDispatcher(zaptest.NewLogger(t), repo, receiver.Client())
+	first := newDelivery(receiver.URL)
+	second := newDelivery(receiver.URL)
+	second.Id = 6
+
+	repo.EXPECT().Claim(gomock.Any(), DefaultBatchSize, Lease).Return([]models.WebhookDelivery{first, second}, nil)
+	repo.EXPECT().MarkDelivered(gomock.Any(), first.Id, http.StatusOK).Return(nil)
+	repo.EXPECT().MarkDelivered(gomock.Any(), second.Id, http.StatusOK).Return(nil)
+
+	require.Nil(t, dispatcher.Deliver(context.Background()))
+}
+
+func Test_Dispatcher_Deliver_Case4_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	repo := mock_webhooks.NewMockRepo(ctrl)
+	dispatcher := NewDispatcher(zaptest.NewLogger(t), repo, receiver.Client())
+	delivery := newDelivery(receiver.URL)
+
+	repo.EXPECT().Claim(gomock.Any(), DefaultBatchSize, Lease).Return(nil, errors.New("conn closed"))
+	require.EqualError(t, dispatcher.Deliver(context.Background()), "conn closed")
+
+	repo.EXPECT().Claim(gomock.Any(), DefaultBatchSize, Lease).Return([]models.WebhookDelivery{delivery}, nil)
+	repo.EXPECT().MarkDelivered(gomock.Any(), delivery.Id, http.StatusOK).Return(errors.New("conn closed"))
+	require.EqualError(t, dispatcher.Deliver(context.Background()), "conn closed")
+}
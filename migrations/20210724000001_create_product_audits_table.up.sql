@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS product_audits (
+  id bigserial PRIMARY KEY,
+  product_id integer NOT NULL,
+  action varchar(16) NOT NULL,
+  actor varchar(255) NOT NULL,
+  request_id varchar(128) NOT NULL DEFAULT '',
+  changes jsonb NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS product_audits_product_id_idx ON product_audits (product_id, id);
+CREATE INDEX IF NOT EXISTS product_audits_created_at_idx ON product_audits (created_at, id);
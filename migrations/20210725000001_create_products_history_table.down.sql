@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS products_history ON products;
+DROP FUNCTION IF EXISTS products_history_trigger();
+DROP TABLE IF EXISTS products_history;
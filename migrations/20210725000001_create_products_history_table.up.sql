@@ -0,0 +1,38 @@
+-- Every version of a product row is valid in [valid_from, valid_to),
+-- the current version has valid_to = 'infinity'
+CREATE TABLE IF NOT EXISTS products_history (
+  id integer NOT NULL,
+  name VARCHAR (250) NOT NULL,
+  price NUMERIC NOT NULL,
+  version integer NOT NULL,
+  deleted_at timestamptz,
+  valid_from timestamptz NOT NULL,
+  valid_to timestamptz NOT NULL DEFAULT 'infinity'
+);
+
+CREATE INDEX IF NOT EXISTS products_history_id_idx ON products_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS products_history_valid_idx ON products_history (valid_from, valid_to);
+
+-- clock_timestamp() follows the order of row locks, so versions of a row
+-- don't overlap even if transactions started in another order
+CREATE OR REPLACE FUNCTION products_history_trigger() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE products_history SET valid_to = clock_timestamp()
+      WHERE id = OLD.id AND valid_to = 'infinity';
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    INSERT INTO products_history (id, name, price, version, deleted_at, valid_from)
+      VALUES (NEW.id, NEW.name, NEW.price, NEW.version, NEW.deleted_at, clock_timestamp());
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_history ON products;
+CREATE TRIGGER products_history AFTER INSERT OR UPDATE OR DELETE ON products
+  FOR EACH ROW EXECUTE FUNCTION products_history_trigger();
+
+-- History of existing products starts now
+INSERT INTO products_history (id, name, price, version, deleted_at, valid_from)
+  SELECT id, name, price, version, deleted_at, now() FROM products;
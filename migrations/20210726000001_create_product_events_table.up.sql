@@ -0,0 +1,18 @@
+-- Outbox of product events, they are written in the transaction of the
+-- change and published by the relay
+CREATE TABLE IF NOT EXISTS product_events (
+  id bigserial PRIMARY KEY,
+  type varchar(32) NOT NULL,
+  product_id integer NOT NULL,
+  version integer NOT NULL,
+  data jsonb,
+  request_id varchar(128) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz NOT NULL DEFAULT now(),
+  last_error text,
+  published_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS product_events_pending_idx ON product_events (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS product_events_published_at_idx ON product_events (published_at) WHERE published_at IS NOT NULL;
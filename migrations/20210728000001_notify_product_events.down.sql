@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS product_events_notify ON product_events;
+DROP FUNCTION IF EXISTS product_events_notify();
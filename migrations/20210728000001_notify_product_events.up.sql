@@ -0,0 +1,19 @@
+-- Product events are sent to listeners of the product_events channel when
+-- the transaction commits, payload is the event JSON (without delivery state)
+CREATE OR REPLACE FUNCTION product_events_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('product_events', json_build_object(
+    'id', NEW.id,
+    'type', NEW.type,
+    'product_id', NEW.product_id,
+    'version', NEW.version,
+    'data', NEW.data,
+    'request_id', NEW.request_id,
+    'created_at', NEW.created_at
+  )::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_events_notify AFTER INSERT ON product_events
+  FOR EACH ROW EXECUTE FUNCTION product_events_notify();
@@ -0,0 +1,16 @@
+CREATE OR REPLACE FUNCTION product_events_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('product_events', json_build_object(
+    'id', NEW.id,
+    'type', NEW.type,
+    'product_id', NEW.product_id,
+    'version', NEW.version,
+    'data', NEW.data,
+    'request_id', NEW.request_id,
+    'created_at', NEW.created_at
+  )::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE product_events DROP COLUMN IF EXISTS changes;
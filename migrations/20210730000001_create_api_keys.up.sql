@@ -0,0 +1,13 @@
+-- Keys are stored as SHA-256 hashes, prefix is the start of the key shown
+-- to tell keys apart
+CREATE TABLE IF NOT EXISTS api_keys (
+  id serial PRIMARY KEY,
+  name varchar(250) NOT NULL,
+  prefix varchar(16) NOT NULL,
+  key_hash char(64) NOT NULL UNIQUE,
+  scopes text[] NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  rotated_at timestamptz,
+  last_used_at timestamptz,
+  revoked_at timestamptz
+);
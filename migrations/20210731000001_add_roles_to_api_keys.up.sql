@@ -0,0 +1,2 @@
+-- Roles are checked against the policy file (POLICY_FILE) besides scopes
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS roles text[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,66 @@
+CREATE OR REPLACE FUNCTION product_events_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('product_events', json_build_object(
+    'id', NEW.id,
+    'type', NEW.type,
+    'product_id', NEW.product_id,
+    'version', NEW.version,
+    'data', NEW.data,
+    'changes', NEW.changes,
+    'request_id', NEW.request_id,
+    'created_at', NEW.created_at
+  )::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION products_history_trigger() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE products_history SET valid_to = clock_timestamp()
+      WHERE id = OLD.id AND valid_to = 'infinity';
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    INSERT INTO products_history (id, name, price, version, deleted_at, valid_from)
+      VALUES (NEW.id, NEW.name, NEW.price, NEW.version, NEW.deleted_at, clock_timestamp());
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP POLICY IF EXISTS tenant_isolation ON products;
+DROP POLICY IF EXISTS tenant_isolation ON products_history;
+DROP POLICY IF EXISTS tenant_isolation ON product_audits;
+DROP POLICY IF EXISTS tenant_isolation ON product_events;
+DROP POLICY IF EXISTS tenant_isolation ON webhook_subscriptions;
+DROP POLICY IF EXISTS tenant_isolation ON webhook_deliveries;
+DROP POLICY IF EXISTS tenant_isolation ON idempotency_keys;
+
+ALTER TABLE products DISABLE ROW LEVEL SECURITY;
+ALTER TABLE products_history DISABLE ROW LEVEL SECURITY;
+ALTER TABLE product_audits DISABLE ROW LEVEL SECURITY;
+ALTER TABLE product_events DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_subscriptions DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries DISABLE ROW LEVEL SECURITY;
+ALTER TABLE idempotency_keys DISABLE ROW LEVEL SECURITY;
+
+-- Keys of tenants may collide, stored responses are only a cache
+TRUNCATE TABLE idempotency_keys;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key);
+
+DROP INDEX IF EXISTS products_tenant_id_idx;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE product_events DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE product_audits DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE products_history DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE products DROP COLUMN IF EXISTS tenant_id;
+
+DROP FUNCTION IF EXISTS current_tenant();
+
+DROP OWNED BY products_tenant;
+DROP ROLE IF EXISTS products_tenant;
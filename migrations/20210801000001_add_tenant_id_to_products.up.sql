@@ -0,0 +1,99 @@
+-- Requests run as products_tenant with app.tenant_id set on the connection
+-- (see repos.SetTenant). Row level security doesn't apply to the owner of
+-- tables, so migrations and background jobs see every tenant.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'products_tenant') THEN
+    CREATE ROLE products_tenant NOLOGIN;
+  END IF;
+END
+$$;
+
+GRANT products_tenant TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO products_tenant;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO products_tenant;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO products_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO products_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO products_tenant;
+
+-- NULL when the connection is not scoped to a tenant
+CREATE OR REPLACE FUNCTION current_tenant() RETURNS text AS $$
+  SELECT NULLIF(current_setting('app.tenant_id', true), '')
+$$ LANGUAGE sql STABLE;
+
+-- Rows are written to the tenant of the connection, existing rows and rows
+-- written by the owner belong to the default tenant
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+ALTER TABLE products_history ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+ALTER TABLE product_audits ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+ALTER TABLE product_events ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS tenant_id varchar(64) NOT NULL DEFAULT COALESCE(current_tenant(), 'default');
+
+CREATE INDEX IF NOT EXISTS products_tenant_id_idx ON products (tenant_id, id);
+
+-- Tenants may reuse idempotency keys
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, key);
+
+-- Keys without tenant may choose it with X-Tenant-ID
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id varchar(64);
+
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE products_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_audits ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE idempotency_keys ENABLE ROW LEVEL SECURITY;
+
+-- USING is the check of written rows too, so rows of another tenant can't
+-- be inserted or moved to it
+DROP POLICY IF EXISTS tenant_isolation ON products;
+CREATE POLICY tenant_isolation ON products USING (tenant_id = current_tenant());
+DROP POLICY IF EXISTS tenant_isolation ON products_history;
+CREATE POLICY tenant_isolation ON products_history USING (tenant_id = current_tenant());
+DROP POLICY IF EXISTS tenant_isolation ON product_audits;
+CREATE POLICY tenant_isolation ON product_audits USING (tenant_id = current_tenant());
+DROP POLICY IF EXISTS tenant_isolation ON product_events;
+CREATE POLICY tenant_isolation ON product_events USING (tenant_id = current_tenant());
+DROP POLICY IF EXISTS tenant_isolation ON webhook_subscriptions;
+CREATE POLICY tenant_isolation ON webhook_subscriptions USING (tenant_id = current_tenant());
+DROP POLICY IF EXISTS tenant_isolation ON webhook_deliveries;
+CREATE POLICY tenant_isolation ON webhook_deliveries USING (tenant_id = current_tenant());
+DROP POLICY IF EXISTS tenant_isolation ON idempotency_keys;
+CREATE POLICY tenant_isolation ON idempotency_keys USING (tenant_id = current_tenant());
+
+-- History rows keep the tenant of the product
+CREATE OR REPLACE FUNCTION products_history_trigger() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE products_history SET valid_to = clock_timestamp()
+      WHERE id = OLD.id AND valid_to = 'infinity';
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    INSERT INTO products_history (id, name, price, version, deleted_at, valid_from, tenant_id)
+      VALUES (NEW.id, NEW.name, NEW.price, NEW.version, NEW.deleted_at, clock_timestamp(), NEW.tenant_id);
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Listeners send events only to subscribers of the tenant
+CREATE OR REPLACE FUNCTION product_events_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('product_events', json_build_object(
+    'id', NEW.id,
+    'type', NEW.type,
+    'product_id', NEW.product_id,
+    'version', NEW.version,
+    'data', NEW.data,
+    'changes', NEW.changes,
+    'request_id', NEW.request_id,
+    'created_at', NEW.created_at,
+    'tenant_id', NEW.tenant_id
+  )::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS rate_limit_take(text, double precision, double precision);
+DROP TABLE IF EXISTS rate_limits;
@@ -0,0 +1,35 @@
+-- Token buckets shared by server instances (see ratelimit.Limit), rows
+-- not updated for the period of their limit are full and may be deleted
+CREATE TABLE IF NOT EXISTS rate_limits (
+  key varchar(512) PRIMARY KEY,
+  tokens double precision NOT NULL,
+  updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_updated_at_idx ON rate_limits (updated_at);
+
+-- Refills the bucket for the time since its last request and takes a token
+-- if there is one. The row lock serializes concurrent requests of the key.
+CREATE OR REPLACE FUNCTION rate_limit_take(bucket_key text, burst double precision, rate double precision,
+  OUT allowed boolean, OUT tokens double precision) AS $$
+DECLARE
+  last_tokens double precision;
+  last_updated_at timestamptz;
+  taken_at timestamptz;
+BEGIN
+  INSERT INTO rate_limits (key, tokens, updated_at) VALUES (bucket_key, burst, clock_timestamp())
+    ON CONFLICT (key) DO NOTHING;
+  SELECT r.tokens, r.updated_at INTO last_tokens, last_updated_at
+    FROM rate_limits r WHERE r.key = bucket_key FOR UPDATE;
+  -- Read the time after the lock, a request which waited for it must not
+  -- refill the time already refilled by the request holding it
+  taken_at := clock_timestamp();
+  rate_limit_take.tokens := LEAST(burst, last_tokens + GREATEST(EXTRACT(EPOCH FROM taken_at - last_updated_at), 0) * rate);
+  allowed := rate_limit_take.tokens >= 1;
+  IF allowed THEN
+    rate_limit_take.tokens := rate_limit_take.tokens - 1;
+  END IF;
+  UPDATE rate_limits SET tokens = rate_limit_take.tokens, updated_at = GREATEST(last_updated_at, taken_at)
+    WHERE key = bucket_key;
+END;
+$$ LANGUAGE plpgsql;
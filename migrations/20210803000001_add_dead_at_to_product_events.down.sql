@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS product_events_pending_product_idx;
+DROP INDEX IF EXISTS product_events_pending_idx;
+CREATE INDEX IF NOT EXISTS product_events_pending_idx ON product_events (id) WHERE published_at IS NULL;
+
+ALTER TABLE product_events DROP COLUMN IF EXISTS dead_at;
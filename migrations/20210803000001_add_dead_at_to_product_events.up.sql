@@ -0,0 +1,10 @@
+-- Events failing MaxAttempts times are dead: they are kept for inspection
+-- and no longer hold back later events of the product
+ALTER TABLE product_events ADD COLUMN IF NOT EXISTS dead_at timestamptz;
+
+DROP INDEX IF EXISTS product_events_pending_idx;
+CREATE INDEX IF NOT EXISTS product_events_pending_idx ON product_events (id)
+  WHERE published_at IS NULL AND dead_at IS NULL;
+-- Finds earlier pending events of the product which hold back an event
+CREATE INDEX IF NOT EXISTS product_events_pending_product_idx ON product_events (product_id, id)
+  WHERE published_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,10 @@
+-- Keys are unique per principal, keys of existing rows belong to no one
+-- and expire
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS actor varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ALTER COLUMN actor DROP DEFAULT;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, actor, key);
+
+-- In flight keys are reserved until locked_until, then a retry takes over
+-- the key of a crashed request
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamptz;
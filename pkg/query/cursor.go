@@ -0,0 +1,137 @@
+package query
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const ParamAfter = "after"
+
+// KeyField is an unique field which ends every sort to make order stable
+const KeyField = "id"
+
+// Cursor points to the last row of a page in keyset pagination. Values are
+// the row values of Keys(Sort) in the same order.
+type Cursor struct {
+	Sort   []Sort
+	Values []interface{}
+}
+
+type CursorMeta struct {
+	Limit      int     `json:"limit"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type rawCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// Keys returns sort with KeyField appended as a tie-breaker
+func Keys(sort []Sort) []Sort {
+	for _, s := range sort {
+		if s.Field == KeyField {
+			return sort
+		}
+	}
+	keys := make([]Sort, 0, len(sort)+1)
+	keys = append(keys, sort...)
+	return append(keys, Sort{Field: KeyField})
+}
+
+func FormatSort(sort []Sort) string {
+	parts := []string{}
+	for _, s := range sort {
+		if s.Desc {
+			parts = append(parts, "-"+s.Field)
+		} else {
+			parts = append(parts, s.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// NewCursor builds an opaque cursor from the JSON representation of row
+func NewCursor(sort []Sort, row interface{}) (string, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return "", err
+	}
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return "", err
+	}
+
+	values := []interface{}{}
+	for _, key := range Keys(sort) {
+		value, ok := fields[key.Field]
+		if !ok {
+			return "", fmt.Errorf("cursor field %q not found", key.Field)
+		}
+		values = append(values, value)
+	}
+
+	data, err = json.Marshal(rawCursor{
+		Sort:   FormatSort(sort),
+		Values: values,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func DecodeCursor(raw string, schema Schema) (*Cursor, *Error) {
+	invalid := &Error{ParamAfter, CodeInvalid, "invalid cursor"}
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var cursor rawCursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, invalid
+	}
+	sort, sortErr := ParseSort(cursor.Sort, schema)
+	if sortErr != nil {
+		return nil, invalid
+	}
+
+	keys := Keys(sort)
+	if len(keys) != len(cursor.Values) {
+		return nil, invalid
+	}
+	values := []interface{}{}
+	for i, key := range keys {
+		field, ok := schema.Field(key.Field)
+		if !ok {
+			return nil, invalid
+		}
+		var raw string
+		switch v := cursor.Values[i].(type) {
+		case json.Number:
+			raw = v.String()
+		case string:
+			raw = v
+		default:
+			return nil, invalid
+		}
+		value, err := parseValue(raw, field.Type)
+		if err != nil {
+			return nil, invalid
+		}
+		values = append(values, value)
+	}
+
+	return &Cursor{
+		Sort:   sort,
+		Values: values,
+	}, nil
+}
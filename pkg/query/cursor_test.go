@@ -0,0 +1,80 @@
+package query
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Keys(t *testing.T) {
+	require.Equal(t, []Sort{{Field: "id"}}, Keys(nil))
+	require.Equal(t, []Sort{{Field: "name", Desc: true}, {Field: "id"}}, Keys([]Sort{{Field: "name", Desc: true}}))
+	require.Equal(t, []Sort{{Field: "id", Desc: true}, {Field: "name"}}, Keys([]Sort{{Field: "id", Desc: true}, {Field: "name"}}))
+}
+
+func Test_FormatSort(t *testing.T) {
+	require.Equal(t, "", FormatSort(nil))
+	require.Equal(t, "-price,name", FormatSort([]Sort{{Field: "price", Desc: true}, {Field: "name"}}))
+}
+
+func Test_Cursor_EncodeDecode(t *testing.T) {
+	row := struct {
+		Id    int     `json:"id"`
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}{
+		Id:    12,
+		Name:  "Name 12",
+		Price: 100.99,
+	}
+	sort := []Sort{{Field: "price", Desc: true}, {Field: "name"}}
+
+	raw, err := NewCursor(sort, row)
+	require.Nil(t, err)
+
+	cursor, decodeErr := DecodeCursor(raw, testSchema)
+	require.Nil(t, decodeErr)
+	require.Equal(t, &Cursor{
+		Sort:   sort,
+		Values: []interface{}{100.99, "Name 12", 12},
+	}, cursor)
+}
+
+func Test_NewCursor_UnknownField(t *testing.T) {
+	_, err := NewCursor([]Sort{{Field: "price"}}, struct {
+		Id int `json:"id"`
+	}{Id: 1})
+
+	require.Error(t, err)
+}
+
+func Test_DecodeCursor_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		raw  string
+	}{
+		{name: "not base64", raw: "%%%"},
+		{name: "not json", raw: "bm90IGpzb24"},
+		{name: "unknown sort", raw: encode(`{"s":"secret","v":["a",1]}`)},
+		{name: "values count", raw: encode(`{"s":"price","v":[1]}`)},
+		{name: "value type", raw: encode(`{"s":"price","v":[1,"abc"]}`)},
+		{name: "value object", raw: encode(`{"s":"price","v":[{},1]}`)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cursor, err := DecodeCursor(tc.raw, testSchema)
+
+			require.Nil(t, cursor)
+			require.Equal(t, &Error{ParamAfter, CodeInvalid, "invalid cursor"}, err)
+		})
+	}
+}
+
+func encode(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
@@ -0,0 +1,278 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const DefaultPerPage = 20
+const MaxPerPage = 100
+
+const ParamPage = "page"
+const ParamPerPage = "per_page"
+const ParamLimit = "limit"
+const ParamOffset = "offset"
+const ParamSort = "sort"
+
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeInt
+	TypeFloat
+)
+
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpContains Op = "contains"
+	OpMin      Op = "min"
+	OpMax      Op = "max"
+)
+
+// Field describes a column which can be sorted or filtered by clients
+type Field struct {
+	Name     string
+	Type     Type
+	Sortable bool
+	Filters  []Op
+}
+
+// Schema is a whitelist of fields allowed in list queries
+type Schema struct {
+	Fields      []Field
+	DefaultSort []Sort
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+type Filter struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+type List struct {
+	Sort    []Sort
+	Filters []Filter
+	Limit   int
+	Offset  int
+	After   *Cursor
+}
+
+type Meta struct {
+	Total      int     `json:"total"`
+	Page       int     `json:"page"`
+	PerPage    int     `json:"per_page"`
+	TotalPages int     `json:"total_pages"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Error codes
+const (
+	CodeInvalid    = "invalid"
+	CodeOutOfRange = "out_of_range"
+	CodeUnknown    = "unknown"
+	CodeDuplicate  = "duplicate"
+	CodeConflict   = "conflict"
+)
+
+type Error struct {
+	Param   string
+	Code    string
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+// Parse reads pagination, sort and filter params. Filter params are named
+// `<field>` for equality and `<field>_<op>` for other operators.
+func Parse(values url.Values, schema Schema) (*List, []Error) {
+	list := &List{
+		Sort:  schema.DefaultSort,
+		Limit: DefaultPerPage,
+	}
+	errs := []Error{}
+
+	// Pagination
+	if values.Get(ParamPage) != "" || values.Get(ParamPerPage) != "" {
+		if values.Get(ParamLimit) != "" || values.Get(ParamOffset) != "" {
+			errs = append(errs, Error{ParamPage, CodeConflict, "cannot be combined with limit/offset"})
+		}
+		page, err := parseInt(values, ParamPage, 1, 1, math.MaxInt32)
+		if err != nil {
+			errs = append(errs, *err)
+		}
+		perPage, err := parseInt(values, ParamPerPage, DefaultPerPage, 1, MaxPerPage)
+		if err != nil {
+			errs = append(errs, *err)
+		}
+		list.Limit = perPage
+		list.Offset = (page - 1) * perPage
+	} else {
+		limit, limitErr := parseInt(values, ParamLimit, DefaultPerPage, 1, MaxPerPage)
+		if limitErr != nil {
+			errs = append(errs, *limitErr)
+		}
+		offset, err := parseInt(values, ParamOffset, 0, 0, math.MaxInt32)
+		if err != nil {
+			errs = append(errs, *err)
+		}
+		// Offset is a page boundary, so the meta describes a page
+		if limitErr == nil && err == nil && offset%limit != 0 {
+			errs = append(errs, Error{ParamOffset, CodeInvalid, "must be a multiple of limit"})
+		}
+		list.Limit = limit
+		list.Offset = offset
+	}
+
+	// Sort
+	if raw := values.Get(ParamSort); raw != "" {
+		sort, err := ParseSort(raw, schema)
+		if err != nil {
+			errs = append(errs, *err)
+		}
+		list.Sort = sort
+	}
+
+	// Keyset pagination, sort is taken from the cursor
+	if raw := values.Get(ParamAfter); raw != "" {
+		if values.Get(ParamPage) != "" || values.Get(ParamOffset) != "" {
+			errs = append(errs, Error{ParamAfter, CodeConflict, "cannot be combined with page/offset"})
+		}
+		cursor, err := DecodeCursor(raw, schema)
+		if err != nil {
+			errs = append(errs, *err)
+		} else {
+			if values.Get(ParamSort) != "" && FormatSort(list.Sort) != FormatSort(cursor.Sort) {
+				errs = append(errs, Error{ParamSort, CodeConflict, "does not match the cursor"})
+			}
+			list.Sort = cursor.Sort
+			list.After = cursor
+		}
+	}
+
+	// Filters
+	for _, field := range schema.Fields {
+		for _, op := range field.Filters {
+			param := FilterParam(field.Name, op)
+			raw := values.Get(param)
+			if raw == "" {
+				continue
+			}
+			value, err := parseValue(raw, field.Type)
+			if err != nil {
+				errs = append(errs, Error{param, CodeInvalid, err.Error()})
+				continue
+			}
+			list.Filters = append(list.Filters, Filter{
+				Field: field.Name,
+				Op:    op,
+				Value: value,
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return list, nil
+}
+
+// ParseSort reads comma separated fields, `-` prefix means descending order
+func ParseSort(raw string, schema Schema) ([]Sort, *Error) {
+	sort := []Sort{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+
+		field, ok := schema.Field(name)
+		if !ok || !field.Sortable {
+			return nil, &Error{ParamSort, CodeUnknown, fmt.Sprintf("unknown sort field %q", name)}
+		}
+		if seen[name] {
+			return nil, &Error{ParamSort, CodeDuplicate, fmt.Sprintf("duplicate sort field %q", name)}
+		}
+		seen[name] = true
+		sort = append(sort, Sort{Field: name, Desc: desc})
+	}
+	return sort, nil
+}
+
+func (s Schema) Field(name string) (Field, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+func FilterParam(field string, op Op) string {
+	if op == OpEq {
+		return field
+	}
+	return field + "_" + string(op)
+}
+
+func (l *List) Meta(total int, nextCursor *string) Meta {
+	return Meta{
+		Total:      total,
+		Page:       l.Offset/l.Limit + 1,
+		PerPage:    l.Limit,
+		TotalPages: (total + l.Limit - 1) / l.Limit,
+		NextCursor: nextCursor,
+	}
+}
+
+func (l *List) CursorMeta(nextCursor *string) CursorMeta {
+	return CursorMeta{
+		Limit:      l.Limit,
+		NextCursor: nextCursor,
+	}
+}
+
+func parseInt(values url.Values, param string, def, min, max int) (int, *Error) {
+	raw := values.Get(param)
+	if raw == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return def, &Error{param, CodeInvalid, "must be an integer"}
+	}
+	if value < min || value > max {
+		return def, &Error{param, CodeOutOfRange, fmt.Sprintf("must be between %d and %d", min, max)}
+	}
+	return value, nil
+}
+
+func parseValue(raw string, typ Type) (interface{}, error) {
+	switch typ {
+	case TypeInt:
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return value, nil
+	case TypeFloat:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
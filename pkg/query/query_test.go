@@ -0,0 +1,190 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = Schema{
+	Fields: []Field{
+		{Name: "id", Type: TypeInt, Sortable: true, Filters: []Op{OpEq}},
+		{Name: "name", Type: TypeString, Sortable: true, Filters: []Op{OpEq, OpContains}},
+		{Name: "price", Type: TypeFloat, Sortable: true, Filters: []Op{OpMin, OpMax}},
+		{Name: "secret", Type: TypeString},
+	},
+	DefaultSort: []Sort{{Field: "id"}},
+}
+
+// testCursor is {"s":"-price","v":[10.5,3]}
+const testCursor = "eyJzIjoiLXByaWNlIiwidiI6WzEwLjUsM119"
+
+func Test_Parse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		wantList *List
+		wantErrs []Error
+	}{
+		{
+			name:     "defaults",
+			query:    "",
+			wantList: &List{Sort: []Sort{{Field: "id"}}, Limit: DefaultPerPage},
+		},
+		{
+			name:     "page and per_page",
+			query:    "page=3&per_page=10",
+			wantList: &List{Sort: []Sort{{Field: "id"}}, Limit: 10, Offset: 20},
+		},
+		{
+			name:     "limit and offset",
+			query:    "limit=5&offset=10",
+			wantList: &List{Sort: []Sort{{Field: "id"}}, Limit: 5, Offset: 10},
+		},
+		{
+			name:     "offset not on a page boundary",
+			query:    "offset=5&limit=10",
+			wantErrs: []Error{{ParamOffset, CodeInvalid, "must be a multiple of limit"}},
+		},
+		{
+			name:  "sort and filters",
+			query: "sort=-price,name&name_contains=app&price_min=1.5&price_max=10&id=4",
+			wantList: &List{
+				Sort: []Sort{{Field: "price", Desc: true}, {Field: "name"}},
+				Filters: []Filter{
+					{Field: "id", Op: OpEq, Value: 4},
+					{Field: "name", Op: OpContains, Value: "app"},
+					{Field: "price", Op: OpMin, Value: 1.5},
+					{Field: "price", Op: OpMax, Value: 10.0},
+				},
+				Limit: DefaultPerPage,
+			},
+		},
+		{
+			name:  "not whitelisted params are ignored",
+			query: "secret=1&price=10",
+			wantList: &List{
+				Sort:  []Sort{{Field: "id"}},
+				Limit: DefaultPerPage,
+			},
+		},
+		{
+			name:  "invalid pagination",
+			query: "page=0&per_page=abc&offset=1",
+			wantErrs: []Error{
+				{ParamPage, CodeConflict, "cannot be combined with limit/offset"},
+				{ParamPage, CodeOutOfRange, "must be between 1 and 2147483647"},
+				{ParamPerPage, CodeInvalid, "must be an integer"},
+			},
+		},
+		{
+			name:  "invalid limit",
+			query: "limit=101&offset=-1",
+			wantErrs: []Error{
+				{ParamLimit, CodeOutOfRange, "must be between 1 and 100"},
+				{ParamOffset, CodeOutOfRange, "must be between 0 and 2147483647"},
+			},
+		},
+		{
+			name:  "invalid sort and filters",
+			query: "sort=secret&id=abc&price_min=NaN",
+			wantErrs: []Error{
+				{ParamSort, CodeUnknown, `unknown sort field "secret"`},
+				{"id", CodeInvalid, "must be an integer"},
+				{"price_min", CodeInvalid, "must be a number"},
+			},
+		},
+		{
+			name:  "after cursor",
+			query: "after=" + testCursor + "&limit=5",
+			wantList: &List{
+				Sort:  []Sort{{Field: "price", Desc: true}},
+				Limit: 5,
+				After: &Cursor{
+					Sort:   []Sort{{Field: "price", Desc: true}},
+					Values: []interface{}{10.5, 3},
+				},
+			},
+		},
+		{
+			name:  "after cursor with page and another sort",
+			query: "after=" + testCursor + "&page=2&sort=name",
+			wantErrs: []Error{
+				{ParamAfter, CodeConflict, "cannot be combined with page/offset"},
+				{ParamSort, CodeConflict, "does not match the cursor"},
+			},
+		},
+		{
+			name:     "after invalid cursor",
+			query:    "after=abc",
+			wantErrs: []Error{{ParamAfter, CodeInvalid, "invalid cursor"}},
+		},
+		{
+			name:     "duplicate sort",
+			query:    "sort=name,-name",
+			wantErrs: []Error{{ParamSort, CodeDuplicate, `duplicate sort field "name"`}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			values, err := url.ParseQuery(tc.query)
+			require.Nil(t, err)
+
+			gotList, gotErrs := Parse(values, testSchema)
+
+			require.Equal(t, tc.wantList, gotList)
+			require.Equal(t, tc.wantErrs, gotErrs)
+		})
+	}
+}
+
+func Test_List_Meta(t *testing.T) {
+	next := "cursor"
+	testCases := []struct {
+		name  string
+		list  List
+		total int
+		next  *string
+		want  Meta
+	}{
+		{
+			name:  "blank",
+			list:  List{Limit: 10},
+			total: 0,
+			want:  Meta{Total: 0, Page: 1, PerPage: 10, TotalPages: 0},
+		},
+		{
+			name:  "with next cursor",
+			list:  List{Limit: 10},
+			total: 21,
+			next:  &next,
+			want:  Meta{Total: 21, Page: 1, PerPage: 10, TotalPages: 3, NextCursor: &next},
+		},
+		{
+			name:  "last page",
+			list:  List{Limit: 10, Offset: 20},
+			total: 21,
+			want:  Meta{Total: 21, Page: 3, PerPage: 10, TotalPages: 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, tc.list.Meta(tc.total, tc.next))
+		})
+	}
+}
+
+func Test_FilterParam(t *testing.T) {
+	require.Equal(t, "name", FilterParam("name", OpEq))
+	require.Equal(t, "name_contains", FilterParam("name", OpContains))
+	require.Equal(t, "price_min", FilterParam("price", OpMin))
+}
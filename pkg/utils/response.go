@@ -7,14 +7,79 @@ import (
 
 const HeaderLocation = "Location"
 const HeaderContentType = "Content-Type"
+const HeaderAcceptPatch = "Accept-Patch"
+const HeaderETag = "ETag"
+const HeaderIfMatch = "If-Match"
+const HeaderIfNoneMatch = "If-None-Match"
+const HeaderRetryAfter = "Retry-After"
 const ContentTypeJSON = "application/json"
+const ContentTypeProblemJSON = "application/problem+json"
+const ContentTypeMergePatchJSON = "application/merge-patch+json"
+const ContentTypeJSONPatchJSON = "application/json-patch+json"
+const MessageBadRequest = "Bad request"
+const MessageInvalid = "Validation failed"
 const MessageInternalError = "Internal error"
+const MessageUnauthorized = "Unauthorized"
+const MessageForbidden = "Forbidden"
 const MessageNotFound = "Not found"
+const MessageConflict = "Conflict"
+const MessageUnavailable = "Service unavailable"
+const MessageTimeout = "Timeout"
+const MessageUnsupportedMediaType = "Unsupported media type"
+const MessagePreconditionFailed = "Precondition failed"
+const MessagePreconditionRequired = "Precondition required"
+const MessageTooManyRequests = "Too many requests"
 
-type ResponseMessage struct {
+// ProblemTypePrefix prefixes problem codes to build the problem type URI
+const ProblemTypePrefix = "urn:crud-products:problem:"
+
+// Machine-readable problem codes
+const CodeBadRequest = "bad_request"
+const CodeInvalidJSON = "invalid_json"
+const CodeInvalid = "validation_failed"
+const CodeUnauthorized = "unauthorized"
+const CodeForbidden = "forbidden"
+const CodeNotFound = "not_found"
+const CodeConflict = "conflict"
+const CodeUnavailable = "unavailable"
+const CodeTimeout = "timeout"
+const CodeUnsupportedMediaType = "unsupported_media_type"
+const CodePreconditionFailed = "precondition_failed"
+const CodePreconditionRequired = "precondition_required"
+const CodeTooManyRequests = "too_many_requests"
+const CodeInternalError = "internal_error"
+
+// Problem is a RFC 7807 error response
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes an invalid field of a request body or query
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
 }
 
+func NewProblem(req *http.Request, status int, code string, title string) Problem {
+	problem := Problem{
+		Type:   ProblemTypePrefix + code,
+		Title:  title,
+		Status: status,
+		Code:   code,
+	}
+	if req != nil && req.URL != nil {
+		problem.Instance = req.URL.Path
+	}
+	return problem
+}
+
 func ResponseOK(res http.ResponseWriter, data interface{}) {
 	res.Header().Set(HeaderContentType, ContentTypeJSON)
 	res.WriteHeader(http.StatusOK)
@@ -29,33 +94,106 @@ func ResponseCreate(res http.ResponseWriter, data interface{}) {
 	json.NewEncoder(res).Encode(data)
 }
 
-func ResponseNoContent(res http.ResponseWriter) {
+func ResponseAccepted(res http.ResponseWriter, data interface{}) {
 	res.Header().Set(HeaderContentType, ContentTypeJSON)
+	res.WriteHeader(http.StatusAccepted)
 	//nolint:errcheck
-	res.WriteHeader(http.StatusNoContent)
+	json.NewEncoder(res).Encode(data)
 }
 
-func ResponseInvalid(res http.ResponseWriter, data interface{}) {
+func ResponseMultiStatus(res http.ResponseWriter, data interface{}) {
 	res.Header().Set(HeaderContentType, ContentTypeJSON)
-	res.WriteHeader(http.StatusUnprocessableEntity)
+	res.WriteHeader(http.StatusMultiStatus)
 	//nolint:errcheck
 	json.NewEncoder(res).Encode(data)
 }
 
-func ResponseInternalError(res http.ResponseWriter) {
+func ResponseNoContent(res http.ResponseWriter) {
 	res.Header().Set(HeaderContentType, ContentTypeJSON)
-	res.WriteHeader(http.StatusInternalServerError)
 	//nolint:errcheck
-	json.NewEncoder(res).Encode(ResponseMessage{
-		Message: MessageInternalError,
-	})
+	res.WriteHeader(http.StatusNoContent)
 }
 
-func ResponseNotFound(res http.ResponseWriter) {
-	res.Header().Set(HeaderContentType, ContentTypeJSON)
-	res.WriteHeader(http.StatusNotFound)
+func ResponseNotModified(res http.ResponseWriter) {
+	res.WriteHeader(http.StatusNotModified)
+}
+
+func ResponseProblem(res http.ResponseWriter, problem Problem) {
+	res.Header().Set(HeaderContentType, ContentTypeProblemJSON)
+	res.WriteHeader(problem.Status)
 	//nolint:errcheck
-	json.NewEncoder(res).Encode(ResponseMessage{
-		Message: MessageNotFound,
-	})
+	json.NewEncoder(res).Encode(problem)
+}
+
+func ResponseBadRequest(res http.ResponseWriter, req *http.Request, errors []FieldError) {
+	problem := NewProblem(req, http.StatusBadRequest, CodeBadRequest, MessageBadRequest)
+	problem.Errors = errors
+	ResponseProblem(res, problem)
+}
+
+func ResponseInvalidJSON(res http.ResponseWriter, req *http.Request, detail string) {
+	problem := NewProblem(req, http.StatusBadRequest, CodeInvalidJSON, MessageBadRequest)
+	problem.Detail = detail
+	ResponseProblem(res, problem)
+}
+
+func ResponseInvalid(res http.ResponseWriter, req *http.Request, errors []FieldError) {
+	problem := NewProblem(req, http.StatusUnprocessableEntity, CodeInvalid, MessageInvalid)
+	problem.Errors = errors
+	ResponseProblem(res, problem)
+}
+
+func ResponseInternalError(res http.ResponseWriter, req *http.Request) {
+	ResponseProblem(res, NewProblem(req, http.StatusInternalServerError, CodeInternalError, MessageInternalError))
+}
+
+func ResponseUnauthorized(res http.ResponseWriter, req *http.Request, detail string) {
+	problem := NewProblem(req, http.StatusUnauthorized, CodeUnauthorized, MessageUnauthorized)
+	problem.Detail = detail
+	ResponseProblem(res, problem)
+}
+
+func ResponseForbidden(res http.ResponseWriter, req *http.Request, detail string) {
+	problem := NewProblem(req, http.StatusForbidden, CodeForbidden, MessageForbidden)
+	problem.Detail = detail
+	ResponseProblem(res, problem)
+}
+
+func ResponseNotFound(res http.ResponseWriter, req *http.Request) {
+	ResponseProblem(res, NewProblem(req, http.StatusNotFound, CodeNotFound, MessageNotFound))
+}
+
+func ResponseConflict(res http.ResponseWriter, req *http.Request) {
+	ResponseProblem(res, NewProblem(req, http.StatusConflict, CodeConflict, MessageConflict))
+}
+
+func ResponseUnavailable(res http.ResponseWriter, req *http.Request) {
+	ResponseProblem(res, NewProblem(req, http.StatusServiceUnavailable, CodeUnavailable, MessageUnavailable))
+}
+
+func ResponseTimeout(res http.ResponseWriter, req *http.Request) {
+	ResponseProblem(res, NewProblem(req, http.StatusGatewayTimeout, CodeTimeout, MessageTimeout))
+}
+
+func ResponseUnsupportedMediaType(res http.ResponseWriter, req *http.Request, detail string) {
+	problem := NewProblem(req, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, MessageUnsupportedMediaType)
+	problem.Detail = detail
+	ResponseProblem(res, problem)
+}
+
+func ResponsePreconditionFailed(res http.ResponseWriter, req *http.Request) {
+	ResponseProblem(res, NewProblem(req, http.StatusPreconditionFailed, CodePreconditionFailed, MessagePreconditionFailed))
+}
+
+func ResponsePreconditionRequired(res http.ResponseWriter, req *http.Request) {
+	problem := NewProblem(req, http.StatusPreconditionRequired, CodePreconditionRequired, MessagePreconditionRequired)
+	problem.Detail = HeaderIfMatch + " header is required"
+	ResponseProblem(res, problem)
+}
+
+// ResponseTooManyRequests responds 429, Retry-After header is set by the caller
+func ResponseTooManyRequests(res http.ResponseWriter, req *http.Request, detail string) {
+	problem := NewProblem(req, http.StatusTooManyRequests, CodeTooManyRequests, MessageTooManyRequests)
+	problem.Detail = detail
+	ResponseProblem(res, problem)
 }
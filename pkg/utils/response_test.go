@@ -12,8 +12,27 @@ func Test_Response_Const(t *testing.T) {
 	require.Equal(t, "Location", HeaderLocation)
 	require.Equal(t, "Content-Type", HeaderContentType)
 	require.Equal(t, "application/json", ContentTypeJSON)
+	require.Equal(t, "application/problem+json", ContentTypeProblemJSON)
+	require.Equal(t, "application/merge-patch+json", ContentTypeMergePatchJSON)
+	require.Equal(t, "application/json-patch+json", ContentTypeJSONPatchJSON)
+	require.Equal(t, "Accept-Patch", HeaderAcceptPatch)
+	require.Equal(t, "ETag", HeaderETag)
+	require.Equal(t, "If-Match", HeaderIfMatch)
+	require.Equal(t, "If-None-Match", HeaderIfNoneMatch)
+	require.Equal(t, "Retry-After", HeaderRetryAfter)
+	require.Equal(t, "Bad request", MessageBadRequest)
+	require.Equal(t, "Validation failed", MessageInvalid)
 	require.Equal(t, "Internal error", MessageInternalError)
+	require.Equal(t, "Unauthorized", MessageUnauthorized)
+	require.Equal(t, "Forbidden", MessageForbidden)
 	require.Equal(t, "Not found", MessageNotFound)
+	require.Equal(t, "Conflict", MessageConflict)
+	require.Equal(t, "Service unavailable", MessageUnavailable)
+	require.Equal(t, "Timeout", MessageTimeout)
+	require.Equal(t, "Unsupported media type", MessageUnsupportedMediaType)
+	require.Equal(t, "Precondition failed", MessagePreconditionFailed)
+	require.Equal(t, "Precondition required", MessagePreconditionRequired)
+	require.Equal(t, "Too many requests", MessageTooManyRequests)
 }
 
 func Test_ResponseOK(t *testing.T) {
@@ -52,20 +71,25 @@ func Test_ResponseCreate(t *testing.T) {
 	require.Equal(t, DataToJson(data), BodyToString(res.Body))
 }
 
-func Test_ResponseNoContent(t *testing.T) {
+func Test_ResponseAccepted(t *testing.T) {
 	// given
+	data := struct {
+		Status string
+	}{
+		Status: "ok",
+	}
 	res := httptest.NewRecorder()
 
 	// when
-	ResponseNoContent(res)
+	ResponseAccepted(res, data)
 
 	// then
 	require.Equal(t, ContentTypeJSON, res.Header().Values(HeaderContentType)[0])
-	require.Equal(t, http.StatusNoContent, res.Result().StatusCode)
-	require.Equal(t, "", BodyToString(res.Body))
+	require.Equal(t, http.StatusAccepted, res.Result().StatusCode)
+	require.Equal(t, DataToJson(data), BodyToString(res.Body))
 }
 
-func Test_ResponseInvalid(t *testing.T) {
+func Test_ResponseMultiStatus(t *testing.T) {
 	// given
 	data := struct {
 		Status string
@@ -75,40 +99,182 @@ func Test_ResponseInvalid(t *testing.T) {
 	res := httptest.NewRecorder()
 
 	// when
-	ResponseInvalid(res, data)
+	ResponseMultiStatus(res, data)
 
 	// then
 	require.Equal(t, ContentTypeJSON, res.Header().Values(HeaderContentType)[0])
-	require.Equal(t, http.StatusUnprocessableEntity, res.Result().StatusCode)
+	require.Equal(t, http.StatusMultiStatus, res.Result().StatusCode)
 	require.Equal(t, DataToJson(data), BodyToString(res.Body))
 }
 
-func Test_ResponseInternalError(t *testing.T) {
+func Test_ResponseNoContent(t *testing.T) {
 	// given
 	res := httptest.NewRecorder()
 
 	// when
-	ResponseInternalError(res)
+	ResponseNoContent(res)
 
 	// then
 	require.Equal(t, ContentTypeJSON, res.Header().Values(HeaderContentType)[0])
-	require.Equal(t, http.StatusInternalServerError, res.Result().StatusCode)
-	require.Equal(t, DataToJson(ResponseMessage{
-		Message: MessageInternalError,
-	}), BodyToString(res.Body))
+	require.Equal(t, http.StatusNoContent, res.Result().StatusCode)
+	require.Equal(t, "", BodyToString(res.Body))
 }
 
-func Test_ResponseNotFound(t *testing.T) {
+func Test_ResponseNotModified(t *testing.T) {
 	// given
 	res := httptest.NewRecorder()
 
 	// when
-	ResponseNotFound(res)
+	ResponseNotModified(res)
 
 	// then
-	require.Equal(t, ContentTypeJSON, res.Header().Values(HeaderContentType)[0])
-	require.Equal(t, http.StatusNotFound, res.Result().StatusCode)
-	require.Equal(t, DataToJson(ResponseMessage{
-		Message: MessageNotFound,
-	}), BodyToString(res.Body))
+	require.Equal(t, http.StatusNotModified, res.Result().StatusCode)
+	require.Equal(t, "", BodyToString(res.Body))
+}
+
+func Test_NewProblem(t *testing.T) {
+	req := httptest.NewRequest("GET", "/products/1?a=b", nil)
+
+	problem := NewProblem(req, http.StatusNotFound, CodeNotFound, MessageNotFound)
+
+	require.Equal(t, Problem{
+		Type:     "urn:crud-products:problem:not_found",
+		Title:    "Not found",
+		Status:   http.StatusNotFound,
+		Instance: "/products/1",
+		Code:     "not_found",
+	}, problem)
+	require.Equal(t, `{"type":"urn:crud-products:problem:not_found","title":"Not found","status":404,"instance":"/products/1","code":"not_found"}`, DataToJson(problem))
+
+	require.Equal(t, "", NewProblem(nil, http.StatusNotFound, CodeNotFound, MessageNotFound).Instance)
+}
+
+func Test_ResponseProblem(t *testing.T) {
+	// given
+	req := httptest.NewRequest("GET", "/products", nil)
+	problem := NewProblem(req, http.StatusTeapot, "teapot", "I'm a teapot")
+	problem.Detail = "Short and stout"
+	res := httptest.NewRecorder()
+
+	// when
+	ResponseProblem(res, problem)
+
+	// then
+	require.Equal(t, ContentTypeProblemJSON, res.Header().Values(HeaderContentType)[0])
+	require.Equal(t, http.StatusTeapot, res.Result().StatusCode)
+	require.Equal(t, DataToJson(problem), BodyToString(res.Body))
+}
+
+func Test_ResponseProblems(t *testing.T) {
+	req := httptest.NewRequest("GET", "/products", nil)
+	fieldErrors := []FieldError{{Field: "name", Code: "required", Message: "The Name field is required."}}
+
+	invalid := NewProblem(req, http.StatusUnprocessableEntity, CodeInvalid, MessageInvalid)
+	invalid.Errors = fieldErrors
+	badRequest := NewProblem(req, http.StatusBadRequest, CodeBadRequest, MessageBadRequest)
+	badRequest.Errors = fieldErrors
+	invalidJSON := NewProblem(req, http.StatusBadRequest, CodeInvalidJSON, MessageBadRequest)
+	invalidJSON.Detail = "unexpected EOF"
+	unsupported := NewProblem(req, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, MessageUnsupportedMediaType)
+	unsupported.Detail = "text/plain"
+	unauthorized := NewProblem(req, http.StatusUnauthorized, CodeUnauthorized, MessageUnauthorized)
+	unauthorized.Detail = "Token is invalid"
+	forbidden := NewProblem(req, http.StatusForbidden, CodeForbidden, MessageForbidden)
+	forbidden.Detail = "Purge is disabled"
+	preconditionRequired := NewProblem(req, http.StatusPreconditionRequired, CodePreconditionRequired, MessagePreconditionRequired)
+	preconditionRequired.Detail = "If-Match header is required"
+	tooManyRequests := NewProblem(req, http.StatusTooManyRequests, CodeTooManyRequests, MessageTooManyRequests)
+	tooManyRequests.Detail = "Limit is 60 requests per 1m0s"
+
+	testCases := []struct {
+		name        string
+		response    func(res http.ResponseWriter)
+		wantProblem Problem
+	}{
+		{
+			name:        "bad request",
+			response:    func(res http.ResponseWriter) { ResponseBadRequest(res, req, fieldErrors) },
+			wantProblem: badRequest,
+		},
+		{
+			name:        "invalid json",
+			response:    func(res http.ResponseWriter) { ResponseInvalidJSON(res, req, "unexpected EOF") },
+			wantProblem: invalidJSON,
+		},
+		{
+			name:        "invalid",
+			response:    func(res http.ResponseWriter) { ResponseInvalid(res, req, fieldErrors) },
+			wantProblem: invalid,
+		},
+		{
+			name:        "internal error",
+			response:    func(res http.ResponseWriter) { ResponseInternalError(res, req) },
+			wantProblem: NewProblem(req, http.StatusInternalServerError, CodeInternalError, MessageInternalError),
+		},
+		{
+			name:        "unauthorized",
+			response:    func(res http.ResponseWriter) { ResponseUnauthorized(res, req, "Token is invalid") },
+			wantProblem: unauthorized,
+		},
+		{
+			name:        "forbidden",
+			response:    func(res http.ResponseWriter) { ResponseForbidden(res, req, "Purge is disabled") },
+			wantProblem: forbidden,
+		},
+		{
+			name:        "not found",
+			response:    func(res http.ResponseWriter) { ResponseNotFound(res, req) },
+			wantProblem: NewProblem(req, http.StatusNotFound, CodeNotFound, MessageNotFound),
+		},
+		{
+			name:        "conflict",
+			response:    func(res http.ResponseWriter) { ResponseConflict(res, req) },
+			wantProblem: NewProblem(req, http.StatusConflict, CodeConflict, MessageConflict),
+		},
+		{
+			name:        "unavailable",
+			response:    func(res http.ResponseWriter) { ResponseUnavailable(res, req) },
+			wantProblem: NewProblem(req, http.StatusServiceUnavailable, CodeUnavailable, MessageUnavailable),
+		},
+		{
+			name:        "timeout",
+			response:    func(res http.ResponseWriter) { ResponseTimeout(res, req) },
+			wantProblem: NewProblem(req, http.StatusGatewayTimeout, CodeTimeout, MessageTimeout),
+		},
+		{
+			name:        "unsupported media type",
+			response:    func(res http.ResponseWriter) { ResponseUnsupportedMediaType(res, req, "text/plain") },
+			wantProblem: unsupported,
+		},
+		{
+			name:        "precondition failed",
+			response:    func(res http.ResponseWriter) { ResponsePreconditionFailed(res, req) },
+			wantProblem: NewProblem(req, http.StatusPreconditionFailed, CodePreconditionFailed, MessagePreconditionFailed),
+		},
+		{
+			name:        "precondition required",
+			response:    func(res http.ResponseWriter) { ResponsePreconditionRequired(res, req) },
+			wantProblem: preconditionRequired,
+		},
+		{
+			name:        "too many requests",
+			response:    func(res http.ResponseWriter) { ResponseTooManyRequests(res, req, "Limit is 60 requests per 1m0s") },
+			wantProblem: tooManyRequests,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+
+			tc.response(res)
+
+			require.Equal(t, ContentTypeProblemJSON, res.Header().Values(HeaderContentType)[0])
+			require.Equal(t, tc.wantProblem.Status, res.Result().StatusCode)
+			require.Equal(t, DataToJson(tc.wantProblem), BodyToString(res.Body))
+		})
+	}
 }
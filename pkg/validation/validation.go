@@ -0,0 +1,209 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+)
+
+// Stable error codes of built-in rules
+const (
+	CodeRequired  = "required"
+	CodeMinLength = "min_length"
+	CodeMaxLength = "max_length"
+	CodeMin       = "min"
+	CodeMax       = "max"
+	CodeDecimal   = "decimal"
+	CodePattern   = "pattern"
+)
+
+// Rule checks a single value. Rules except Required skip nil values, so
+// optional fields are validated only when present.
+type Rule struct {
+	Code     string
+	Message  string
+	Check    func(value interface{}) bool
+	required bool
+}
+
+// WithMessage replaces the default message of the rule
+func (r Rule) WithMessage(message string) Rule {
+	r.Message = message
+	return r
+}
+
+type FieldRules struct {
+	Name  string
+	Value interface{}
+	Rules []Rule
+}
+
+// Field declares rules of a field, name is the JSON name used in errors
+func Field(name string, value interface{}, rules ...Rule) FieldRules {
+	return FieldRules{
+		Name:  name,
+		Value: value,
+		Rules: rules,
+	}
+}
+
+// Validate runs rules of every field and stops on the first violation of a
+// field, so each field has at most one error
+func Validate(fields ...FieldRules) []utils.FieldError {
+	errors := []utils.FieldError{}
+	for _, field := range fields {
+		value := indirect(field.Value)
+		for _, rule := range field.Rules {
+			if value == nil && !rule.required {
+				continue
+			}
+			if !rule.Check(value) {
+				errors = append(errors, utils.FieldError{
+					Field:   field.Name,
+					Code:    rule.Code,
+					Message: rule.Message,
+				})
+				break
+			}
+		}
+	}
+	return errors
+}
+
+func Required() Rule {
+	return Rule{
+		Code:     CodeRequired,
+		Message:  "is required",
+		required: true,
+		Check: func(value interface{}) bool {
+			if value == nil {
+				return false
+			}
+			if str, ok := value.(string); ok {
+				return strings.TrimSpace(str) != ""
+			}
+			return true
+		},
+	}
+}
+
+func MinLength(min int) Rule {
+	return Rule{
+		Code:    CodeMinLength,
+		Message: fmt.Sprintf("must be at least %d characters", min),
+		Check: func(value interface{}) bool {
+			str, ok := value.(string)
+			return ok && utf8.RuneCountInString(str) >= min
+		},
+	}
+}
+
+func MaxLength(max int) Rule {
+	return Rule{
+		Code:    CodeMaxLength,
+		Message: fmt.Sprintf("must be at most %d characters", max),
+		Check: func(value interface{}) bool {
+			str, ok := value.(string)
+			return ok && utf8.RuneCountInString(str) <= max
+		},
+	}
+}
+
+func Min(min float64) Rule {
+	return Rule{
+		Code:    CodeMin,
+		Message: fmt.Sprintf("must be greater than or equal %v", min),
+		Check: func(value interface{}) bool {
+			number, ok := toFloat(value)
+			return ok && number >= min
+		},
+	}
+}
+
+func Max(max float64) Rule {
+	return Rule{
+		Code:    CodeMax,
+		Message: fmt.Sprintf("must be less than or equal %v", max),
+		Check: func(value interface{}) bool {
+			number, ok := toFloat(value)
+			return ok && number <= max
+		},
+	}
+}
+
+// Decimal limits number of digits after the decimal point
+func Decimal(scale int) Rule {
+	return Rule{
+		Code:    CodeDecimal,
+		Message: fmt.Sprintf("must have at most %d decimal places", scale),
+		Check: func(value interface{}) bool {
+			number, ok := toFloat(value)
+			if !ok {
+				return false
+			}
+			str := strconv.FormatFloat(number, 'f', -1, 64)
+			dot := strings.IndexByte(str, '.')
+			return dot == -1 || len(str)-dot-1 <= scale
+		},
+	}
+}
+
+func Match(re *regexp.Regexp) Rule {
+	return Rule{
+		Code:    CodePattern,
+		Message: fmt.Sprintf("must match %s", re.String()),
+		Check: func(value interface{}) bool {
+			str, ok := value.(string)
+			return ok && re.MatchString(str)
+		},
+	}
+}
+
+// Func makes a custom rule
+func Func(code string, message string, check func(value interface{}) bool) Rule {
+	return Rule{
+		Code:    code,
+		Message: message,
+		Check:   check,
+	}
+}
+
+// indirect dereferences pointers, nil pointers become nil
+func indirect(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
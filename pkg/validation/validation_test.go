@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/roman-wb/crud-products/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Rules(t *testing.T) {
+	name := "Name"
+	var nilName *string
+
+	testCases := []struct {
+		name  string
+		rule  Rule
+		value interface{}
+		want  bool
+	}{
+		{name: "required string", rule: Required(), value: "a", want: true},
+		{name: "required blank string", rule: Required(), value: " \t", want: false},
+		{name: "required nil", rule: Required(), value: nil, want: false},
+		{name: "required zero number", rule: Required(), value: 0, want: true},
+		{name: "min length", rule: MinLength(2), value: "ab", want: true},
+		{name: "min length short", rule: MinLength(2), value: "a", want: false},
+		{name: "max length runes", rule: MaxLength(2), value: "яя", want: true},
+		{name: "max length long", rule: MaxLength(2), value: "abc", want: false},
+		{name: "max length not string", rule: MaxLength(2), value: 1, want: false},
+		{name: "min", rule: Min(0), value: 0.0, want: true},
+		{name: "min less", rule: Min(0), value: -0.01, want: false},
+		{name: "min int", rule: Min(1), value: 1, want: true},
+		{name: "max json number", rule: Max(10), value: json.Number("10"), want: true},
+		{name: "max greater", rule: Max(10), value: int64(11), want: false},
+		{name: "min not number", rule: Min(0), value: "1", want: false},
+		{name: "decimal integer", rule: Decimal(2), value: 100.0, want: true},
+		{name: "decimal cents", rule: Decimal(2), value: 100.99, want: true},
+		{name: "decimal too precise", rule: Decimal(2), value: 0.001, want: false},
+		{name: "decimal zero scale", rule: Decimal(0), value: 1.5, want: false},
+		{name: "match", rule: Match(regexp.MustCompile(`^[a-z]+$`)), value: "abc", want: true},
+		{name: "match fails", rule: Match(regexp.MustCompile(`^[a-z]+$`)), value: "ABC", want: false},
+		{name: "func", rule: Func("even", "must be even", func(v interface{}) bool { return v.(int)%2 == 0 }), value: 2, want: true},
+		{name: "pointer", rule: MaxLength(4), value: &name, want: true},
+		{name: "nil pointer is skipped", rule: MaxLength(1), value: nilName, want: true},
+		{name: "nil pointer is required", rule: Required(), value: nilName, want: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			errors := Validate(Field("field", tc.value, tc.rule))
+
+			require.Equal(t, tc.want, len(errors) == 0)
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	errors := Validate(
+		Field("name", "", Required(), MaxLength(3)),
+		Field("price", 10.555, Min(0), Decimal(2).WithMessage("Use cents")),
+		Field("code", "abcd", Required(), MaxLength(3), Match(regexp.MustCompile(`^\d+$`))),
+		Field("valid", 1, Required()),
+	)
+
+	require.Equal(t, []utils.FieldError{
+		{Field: "name", Code: CodeRequired, Message: "is required"},
+		{Field: "price", Code: CodeDecimal, Message: "Use cents"},
+		{Field: "code", Code: CodeMaxLength, Message: "must be at most 3 characters"},
+	}, errors)
+}
+
+func Test_Validate_Valid(t *testing.T) {
+	require.Equal(t, []utils.FieldError{}, Validate(Field("name", "Name", Required())))
+}